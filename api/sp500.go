@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"net/http"
@@ -10,20 +11,24 @@ const sp500URL = "https://raw.githubusercontent.com/datasets/s-and-p-500-compani
 
 // HTTPClient defines the interface for an HTTP client.
 type HTTPClient interface {
-	Get(url string) (*http.Response, error)
+	Get(ctx context.Context, url string) (*http.Response, error)
 }
 
 // DefaultHTTPClient is an implementation of HTTPClient using the standard library.
 type DefaultHTTPClient struct{}
 
-func (c *DefaultHTTPClient) Get(url string) (*http.Response, error) {
-	return http.Get(url)
+func (c *DefaultHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
 }
 
 // GetSP500Symbols fetches the S&P 500 symbols using the provided HTTP client.
-func GetSP500Symbols(client HTTPClient) ([]string, error) {
+func GetSP500Symbols(ctx context.Context, client HTTPClient) ([]string, error) {
 	fmt.Println("Downloading: " + sp500URL)
-	resp, err := client.Get(sp500URL)
+	resp, err := client.Get(ctx, sp500URL)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,7 +15,7 @@ type MockHTTPClient struct {
 	Err      error
 }
 
-func (m *MockHTTPClient) Get(url string) (*http.Response, error) {
+func (m *MockHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
 	return m.Response, m.Err
 }
 
@@ -37,7 +38,7 @@ FB,Meta Platforms Inc Class A,Communication Services`
 		Err:      nil,
 	}
 
-	symbols, err := GetSP500Symbols(mockClient)
+	symbols, err := GetSP500Symbols(context.Background(), mockClient)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -61,7 +62,7 @@ func TestGetSP500Symbols_HTTPError(t *testing.T) {
 		Err:      fmt.Errorf("network error"),
 	}
 
-	_, err := GetSP500Symbols(mockClient)
+	_, err := GetSP500Symbols(context.Background(), mockClient)
 	if err == nil {
 		t.Fatal("Expected an error, got nil")
 	}
@@ -2,9 +2,14 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"github.com/fcopulgar/stock-manager-go/cmd/cli/output"
+	"github.com/fcopulgar/stock-manager-go/repositories"
 	"github.com/fcopulgar/stock-manager-go/services"
 	"io"
+	"os"
+	"os/signal"
 	"strings"
 )
 
@@ -12,17 +17,52 @@ type CLI struct {
 	portfolioService services.PortfolioServiceInterface
 	reader           *bufio.Reader
 	writer           io.Writer
+	errWriter        io.Writer
+	format           output.Format
+	priceCache       services.PriceCacheInterface
+	manualPrices     services.ManualPriceInterface
+	migrator         repositories.Migrator
 }
 
-func NewCLI(portfolioService services.PortfolioServiceInterface, input io.Reader, output io.Writer) *CLI {
+func NewCLI(portfolioService services.PortfolioServiceInterface, input io.Reader, writer io.Writer) *CLI {
 	return &CLI{
 		portfolioService: portfolioService,
 		reader:           bufio.NewReader(input),
-		writer:           output,
+		writer:           writer,
+		errWriter:        writer,
+		format:           output.Table,
 	}
 }
 
-func (cli *CLI) Run() {
+// SetErrorOutput sets where Execute reports errors and usage, by default the regular output.
+func (cli *CLI) SetErrorOutput(writer io.Writer) {
+	cli.errWriter = writer
+}
+
+// SetPriceCache enables the cache command, which warms and invalidates stored prices.
+func (cli *CLI) SetPriceCache(priceCache services.PriceCacheInterface) {
+	cli.priceCache = priceCache
+}
+
+// SetManualPrices enables the manual-price command, which manages the prices entered by hand.
+func (cli *CLI) SetManualPrices(manualPrices services.ManualPriceInterface) {
+	cli.manualPrices = manualPrices
+}
+
+// SetMigrator enables the db command, which migrates the database schema.
+func (cli *CLI) SetMigrator(migrator repositories.Migrator) {
+	cli.migrator = migrator
+}
+
+// PromptFallback returns a price fallback that asks the user through the
+// input and output of the CLI.
+func (cli *CLI) PromptFallback() *services.PromptFallback {
+	return services.NewPromptFallback(cli.reader, cli.writer)
+}
+
+// Run shows the interactive menu until the user exits. Ctrl-C cancels the
+// selected option and returns to the menu.
+func (cli *CLI) Run(ctx context.Context) {
 	for {
 		fmt.Fprintln(cli.writer, "\nSelect an option:")
 		fmt.Fprintln(cli.writer, "1. View portfolios")
@@ -38,18 +78,27 @@ func (cli *CLI) Run() {
 		}
 		input = strings.TrimSpace(input)
 
+		if input == "4" {
+			fmt.Fprintln(cli.writer, "Exiting...")
+			return
+		}
+		optionCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
 		switch input {
 		case "1":
-			cli.viewPortfolios()
+			cli.viewPortfolios(optionCtx)
 		case "2":
-			cli.createPortfolioManual()
+			cli.createPortfolioManual(optionCtx)
 		case "3":
-			cli.createPortfolioRandom()
-		case "4":
-			fmt.Fprintln(cli.writer, "Exiting...")
-			return
+			cli.createPortfolioRandom(optionCtx)
 		default:
 			fmt.Fprintln(cli.writer, "Invalid option.")
 		}
+		if optionCtx.Err() != nil && ctx.Err() == nil {
+			fmt.Fprintln(cli.writer, "Interrupted.")
+		}
+		stop()
+		if ctx.Err() != nil {
+			return
+		}
 	}
 }
@@ -2,11 +2,14 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/services"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -14,43 +17,142 @@ type MockPortfolioService struct {
 	mock.Mock
 }
 
-func (m *MockPortfolioService) GetAllPortfolios() ([]models.Portfolio, error) {
+func (m *MockPortfolioService) GetAllPortfolios(ctx context.Context) ([]models.Portfolio, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Portfolio), args.Error(1)
 }
 
-func (m *MockPortfolioService) GetPortfolioByID(id int) (*models.Portfolio, error) {
+func (m *MockPortfolioService) ListPortfolios(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error) {
+	args := m.Called(query)
+	return args.Get(0).([]models.Portfolio), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetPortfolioByID(ctx context.Context, id int) (*models.Portfolio, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Portfolio), args.Error(1)
 }
 
-func (m *MockPortfolioService) CreatePortfolioManual(portfolio *models.Portfolio) error {
+func (m *MockPortfolioService) CreatePortfolioManual(ctx context.Context, portfolio *models.Portfolio) error {
 	args := m.Called(portfolio)
 	return args.Error(0)
 }
 
-func (m *MockPortfolioService) DeletePortfolio(id int) error {
+func (m *MockPortfolioService) UpdatePortfolio(ctx context.Context, portfolio *models.Portfolio) error {
+	args := m.Called(portfolio)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioService) DeletePortfolio(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockPortfolioService) CalculateAPR(portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
+func (m *MockPortfolioService) CalculateAPR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
+	args := m.Called(portfolio, startDate, endDate)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPortfolioService) CalculateXIRR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
 	args := m.Called(portfolio, startDate, endDate)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockPortfolioService) GetPriceClose(symbol string, date time.Time) (float64, error) {
+func (m *MockPortfolioService) CalculateTWR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (*models.TWRReport, error) {
+	args := m.Called(portfolio, startDate, endDate)
+	return args.Get(0).(*models.TWRReport), args.Error(1)
+}
+
+func (m *MockPortfolioService) CompareToBenchmark(ctx context.Context, portfolio *models.Portfolio, symbol string, startDate, endDate time.Time) (*models.BenchmarkComparison, error) {
+	args := m.Called(portfolio, symbol, startDate, endDate)
+	return args.Get(0).(*models.BenchmarkComparison), args.Error(1)
+}
+
+func (m *MockPortfolioService) CalculateRisk(ctx context.Context, portfolio *models.Portfolio, benchmark string, riskFreeRate float64, startDate, endDate time.Time) (*models.RiskReport, error) {
+	args := m.Called(portfolio, benchmark, riskFreeRate, startDate, endDate)
+	return args.Get(0).(*models.RiskReport), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetTransactions(ctx context.Context, portfolio *models.Portfolio) ([]models.Transaction, error) {
+	args := m.Called(portfolio)
+	return args.Get(0).([]models.Transaction), args.Error(1)
+}
+
+func (m *MockPortfolioService) RecordTransaction(ctx context.Context, transaction *models.Transaction) error {
+	args := m.Called(transaction)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioService) CalculateGains(ctx context.Context, portfolio *models.Portfolio, date time.Time) (*models.Gains, error) {
+	args := m.Called(portfolio, date)
+	return args.Get(0).(*models.Gains), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetOpenLots(ctx context.Context, portfolio *models.Portfolio, date time.Time) ([]models.Lot, error) {
+	args := m.Called(portfolio, date)
+	return args.Get(0).([]models.Lot), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetRealizedGains(ctx context.Context, portfolio *models.Portfolio, from, to time.Time) (*models.RealizedGainsReport, error) {
+	args := m.Called(portfolio, from, to)
+	return args.Get(0).(*models.RealizedGainsReport), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetCashEntries(ctx context.Context, portfolio *models.Portfolio) ([]models.CashEntry, error) {
+	args := m.Called(portfolio)
+	return args.Get(0).([]models.CashEntry), args.Error(1)
+}
+
+func (m *MockPortfolioService) RecordCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioService) GetCashBalance(ctx context.Context, portfolio *models.Portfolio, date time.Time) (float64, error) {
+	args := m.Called(portfolio, date)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
 	args := m.Called(symbol, date)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockPortfolioService) GetSP500Symbols() ([]string, error) {
+// GetPriceCloses prices every request with the expectations of GetPriceClose.
+func (m *MockPortfolioService) GetPriceCloses(ctx context.Context, requests []services.PriceRequest) (map[services.PriceRequest]float64, error) {
+	prices := make(map[services.PriceRequest]float64, len(requests))
+	errs := services.PriceErrors{}
+	for _, request := range requests {
+		price, err := m.GetPriceClose(ctx, request.Symbol, request.Date)
+		if err != nil {
+			errs[request.Symbol] = err
+			continue
+		}
+		prices[services.NewPriceRequest(request.Symbol, request.Date)] = price
+	}
+	if len(errs) > 0 {
+		return prices, errs
+	}
+	return prices, nil
+}
+
+func (m *MockPortfolioService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	args := m.Called(symbol, date)
+	return args.Get(0).(*models.ResolvedPrice), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	args := m.Called(symbol, from, to)
+	return args.Get(0).([]models.PriceBar), args.Error(1)
+}
+
+func (m *MockPortfolioService) GetSP500Symbols(ctx context.Context) ([]string, error) {
 	args := m.Called()
 	return args.Get(0).([]string), args.Error(1)
 }
 
 // TestCLI_Run tests that the menu prints and that option 4 exits without errors.
 func TestCLI_Run(t *testing.T) {
+	ctx := context.Background()
 	mockService := new(MockPortfolioService)
 
 	// Simulate user login: select option 4 (Exit)
@@ -59,7 +161,7 @@ func TestCLI_Run(t *testing.T) {
 	var outputBuffer bytes.Buffer
 
 	cli := NewCLI(mockService, inputReader, &outputBuffer)
-	cli.Run()
+	cli.Run(ctx)
 
 	output := outputBuffer.String()
 
@@ -87,6 +189,7 @@ func TestCLI_Run(t *testing.T) {
 // TestCLI_ViewPortfolios verifies that when option 1 is selected, GetAllPortfolios() is called.
 // and the case of no portfolios is handled.
 func TestCLI_ViewPortfolios_NoPortfolios(t *testing.T) {
+	ctx := context.Background()
 	mockService := new(MockPortfolioService)
 	// Configure the mock so that GetAllPortfolios returns an empty list
 	mockService.On("GetAllPortfolios").Return([]models.Portfolio{}, nil)
@@ -97,7 +200,7 @@ func TestCLI_ViewPortfolios_NoPortfolios(t *testing.T) {
 	var outputBuffer bytes.Buffer
 
 	cli := NewCLI(mockService, inputReader, &outputBuffer)
-	cli.Run()
+	cli.Run(ctx)
 
 	output := outputBuffer.String()
 
@@ -109,3 +212,82 @@ func TestCLI_ViewPortfolios_NoPortfolios(t *testing.T) {
 		t.Errorf("Expected output to contain 'No portfolios available.', got '%s'", output)
 	}
 }
+
+// TestCLI_CreatePortfolioRandom_NameTaken verifies that a random portfolio whose name is taken is saved with another one.
+func TestCLI_CreatePortfolioRandom_NameTaken(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetSP500Symbols").Return([]string{"AAPL"}, nil)
+	day := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetResolvedPrice", "AAPL", mock.Anything).Return(&models.ResolvedPrice{RequestedDate: day, PriceBar: models.PriceBar{Date: day, Close: 127.14}}, nil)
+
+	var names []string
+	mockService.On("CreatePortfolioManual", mock.AnythingOfType("*models.Portfolio")).Return(fmt.Errorf("duplicate name: %w", models.ErrConflict)).Once().Run(func(args mock.Arguments) {
+		names = append(names, args.Get(0).(*models.Portfolio).Name)
+	})
+	mockService.On("CreatePortfolioManual", mock.AnythingOfType("*models.Portfolio")).Return(nil).Once().Run(func(args mock.Arguments) {
+		names = append(names, args.Get(0).(*models.Portfolio).Name)
+	})
+	mockService.On("CalculateAPR", mock.Anything, mock.Anything, mock.Anything).Return(0.1, nil)
+
+	var outputBuffer bytes.Buffer
+	cli := NewCLI(mockService, strings.NewReader(""), &outputBuffer)
+	cli.createPortfolioRandom(ctx)
+
+	if !strings.Contains(outputBuffer.String(), "Random portfolio created successfully.") {
+		t.Fatalf("Expected the portfolio to be created, got '%s'", outputBuffer.String())
+	}
+	if len(names) != 2 || names[1] != names[0]+" (2)" {
+		t.Errorf("Expected the taken name to be numbered, got %v", names)
+	}
+}
+
+// MockManualPrices is a mock of ManualPriceInterface
+type MockManualPrices struct {
+	mock.Mock
+}
+
+func (m *MockManualPrices) SetManualPrice(ctx context.Context, symbol string, date time.Time, price float64) error {
+	args := m.Called(symbol, date, price)
+	return args.Error(0)
+}
+
+func (m *MockManualPrices) GetManualPrices(ctx context.Context, symbol string) ([]models.ManualPrice, error) {
+	args := m.Called(symbol)
+	return args.Get(0).([]models.ManualPrice), args.Error(1)
+}
+
+func (m *MockManualPrices) DeleteManualPrice(ctx context.Context, symbol string, date time.Time) error {
+	args := m.Called(symbol, date)
+	return args.Error(0)
+}
+
+// MockPriceCache is a mock of PriceCacheInterface
+type MockPriceCache struct {
+	mock.Mock
+}
+
+func (m *MockPriceCache) WarmPrices(ctx context.Context, symbols []string, from, to time.Time) (int, error) {
+	args := m.Called(symbols, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPriceCache) InvalidatePrices(ctx context.Context, symbols []string) error {
+	args := m.Called(symbols)
+	return args.Error(0)
+}
+
+// MockMigrator is a mock of Migrator
+type MockMigrator struct {
+	mock.Mock
+}
+
+func (m *MockMigrator) Migrate(ctx context.Context) ([]models.SchemaMigration, error) {
+	args := m.Called()
+	return args.Get(0).([]models.SchemaMigration), args.Error(1)
+}
+
+func (m *MockMigrator) MigrationStatus(ctx context.Context) ([]models.SchemaMigration, error) {
+	args := m.Called()
+	return args.Get(0).([]models.SchemaMigration), args.Error(1)
+}
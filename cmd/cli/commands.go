@@ -0,0 +1,1164 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/cmd/cli/output"
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// Exit codes returned by Execute.
+const (
+	ExitOK          = 0
+	ExitError       = 1
+	ExitUsage       = 2
+	ExitNotFound    = 3
+	ExitConflict    = 4
+	ExitInvalid     = 5
+	ExitUnavailable = 6
+	ExitInterrupted = 130
+)
+
+// errUsage marks errors caused by invalid arguments rather than failed operations.
+var errUsage = errors.New("usage error")
+
+// errorExits maps the domain errors to the exit code of Execute and a hint on
+// what to do, checked in order since an error may wrap more than one.
+var errorExits = []struct {
+	err  error
+	code int
+	hint string
+}{
+	{models.ErrNotFound, ExitNotFound, ""},
+	{models.ErrConflict, ExitConflict, ""},
+	{models.ErrValidation, ExitInvalid, ""},
+	{models.ErrRateLimited, ExitUnavailable, "The price provider refused more requests, try again later."},
+	{models.ErrProviderConfig, ExitUnavailable, "Check the API key of the price provider or choose another PRICE_PROVIDER."},
+	{models.ErrPriceUnavailable, ExitUnavailable, "Enter the missing price with \"stock-manager manual-price set\" or choose another PRICE_FALLBACK."},
+}
+
+const usage = `Usage:
+  stock-manager                                   Start the interactive menu
+  stock-manager [--output table|json|csv|yaml] <command>
+  stock-manager portfolio list [--name <text>] [--sort id|name] [--desc] [--limit <n>] [--offset <n>]
+                                                  List the portfolios, optionally a page of the ones matching a name
+  stock-manager portfolio show <id>               Show a portfolio and its positions
+  stock-manager portfolio create --name <name> [--cost-basis <method>] [--stock SYMBOL:QTY:YYYY-MM-DD[:PRICE]]...
+  stock-manager portfolio set-cost-basis <id> fifo|lifo|hifo|average|specific
+  stock-manager portfolio delete <id>             Delete a portfolio
+  stock-manager portfolio add-position <id> --symbol <symbol> --quantity <n> --date <YYYY-MM-DD> [--price <price>]
+  stock-manager price <symbol> [--date <YYYY-MM-DD>]
+  stock-manager price <symbol> --from <YYYY-MM-DD> [--to <YYYY-MM-DD>]
+                                                  Show the daily open, high, low, close and volume
+  stock-manager apr <id> [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+  stock-manager twr <id> [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+                                                  Show the time-weighted return and daily valuations
+  stock-manager benchmark <id> [--symbol SPY] [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+                                                  Compare a portfolio with the same cash flows invested in a benchmark
+  stock-manager risk <id> [--benchmark SPY] [--risk-free <rate>] [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+                                                  Show volatility, Sharpe, Sortino, drawdown, beta and alpha
+  stock-manager transaction list <id>             Show the ledger of a portfolio
+  stock-manager transaction add <id> --type buy|sell|dividend|fee|split [--symbol <symbol>]
+                [--quantity <n>] [--price <price>] [--commission <amount>] [--date <YYYY-MM-DD>]
+                [--lot LOT:QTY]...
+  stock-manager gains <id> [--date <YYYY-MM-DD>]  Show realized and unrealized gains
+  stock-manager lots <id> [--date <YYYY-MM-DD>]   Show the open lots of a portfolio
+  stock-manager cash list <id> [--date <YYYY-MM-DD>]
+                                                  Show the cash entries and balance of a portfolio
+  stock-manager cash add <id> --type deposit|withdrawal|interest|fee --amount <amount>
+                [--date <YYYY-MM-DD>] [--description <text>]
+  stock-manager realized <id> [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+                                                  Report realized gains by holding period
+  stock-manager cache warm [<symbol>...] [--from <YYYY-MM-DD>] [--to <YYYY-MM-DD>]
+                                                  Store prices, by default of every symbol held since its purchase
+  stock-manager cache clear [<symbol>...]         Remove stored prices, by default of every symbol
+  stock-manager manual-price list [<symbol>]      Show the prices entered by hand
+  stock-manager manual-price set <symbol> --date <YYYY-MM-DD> --price <price>
+  stock-manager manual-price delete <symbol> --date <YYYY-MM-DD>
+  stock-manager db migrate                        Apply the pending migrations of the database schema
+  stock-manager db status                         Show the applied and pending migrations
+
+The --output (-o) option can also be given after any command.
+`
+
+// Execute runs a single non-interactive command and returns the process exit
+// code. The work in progress stops when ctx is canceled.
+func (cli *CLI) Execute(ctx context.Context, args []string) int {
+	global := cli.newFlagSet("stock-manager")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(cli.writer, usage)
+			return ExitOK
+		}
+		fmt.Fprintf(cli.errWriter, "Error: %v\n", err)
+		fmt.Fprint(cli.errWriter, usage)
+		return ExitUsage
+	}
+	args = global.Args()
+
+	if len(args) == 0 {
+		fmt.Fprint(cli.errWriter, usage)
+		return ExitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "portfolio":
+		err = cli.runPortfolioCommand(ctx, args[1:])
+	case "price":
+		err = cli.runPriceCommand(ctx, args[1:])
+	case "apr":
+		err = cli.runAPRCommand(ctx, args[1:])
+	case "twr":
+		err = cli.runTWRCommand(ctx, args[1:])
+	case "benchmark":
+		err = cli.runBenchmarkCommand(ctx, args[1:])
+	case "risk":
+		err = cli.runRiskCommand(ctx, args[1:])
+	case "transaction":
+		err = cli.runTransactionCommand(ctx, args[1:])
+	case "gains":
+		err = cli.runGainsCommand(ctx, args[1:])
+	case "lots":
+		err = cli.runLotsCommand(ctx, args[1:])
+	case "cash":
+		err = cli.runCashCommand(ctx, args[1:])
+	case "realized":
+		err = cli.runRealizedCommand(ctx, args[1:])
+	case "cache":
+		err = cli.runCacheCommand(ctx, args[1:])
+	case "manual-price":
+		err = cli.runManualPriceCommand(ctx, args[1:])
+	case "db":
+		err = cli.runDBCommand(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(cli.writer, usage)
+		return ExitOK
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(cli.errWriter, "Interrupted.")
+			return ExitInterrupted
+		}
+		fmt.Fprintf(cli.errWriter, "Error: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(cli.errWriter, usage)
+			return ExitUsage
+		}
+		for _, exit := range errorExits {
+			if errors.Is(err, exit.err) {
+				if exit.hint != "" {
+					fmt.Fprintln(cli.errWriter, exit.hint)
+				}
+				return exit.code
+			}
+		}
+		return ExitError
+	}
+	return ExitOK
+}
+
+// CommandName returns the command the arguments of Execute run, or an empty
+// string when they name none.
+func CommandName(args []string) string {
+	global := (&CLI{}).newFlagSet("stock-manager")
+	if err := global.Parse(args); err != nil {
+		return ""
+	}
+	return global.Arg(0)
+}
+
+func (cli *CLI) runPortfolioCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing portfolio subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return cli.runPortfolioList(ctx, args[1:])
+	case "show":
+		return cli.runPortfolioShow(ctx, args[1:])
+	case "create":
+		return cli.runPortfolioCreate(ctx, args[1:])
+	case "delete":
+		return cli.runPortfolioDelete(ctx, args[1:])
+	case "add-position":
+		return cli.runPortfolioAddPosition(ctx, args[1:])
+	case "set-cost-basis":
+		return cli.runPortfolioSetCostBasis(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown portfolio subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runPortfolioList(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio list")
+	name := fs.String("name", "", "only the portfolios whose name contains the text")
+	sortBy := fs.String("sort", string(models.PortfolioSortID), "order of the portfolios: id or name")
+	descending := fs.Bool("desc", false, "sort in descending order")
+	limit := fs.Int("limit", 0, "maximum number of portfolios, all of them when 0")
+	offset := fs.Int("offset", 0, "number of portfolios to skip")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *limit < 0 || *offset < 0 {
+		return fmt.Errorf("%w: --limit and --offset cannot be negative", errUsage)
+	}
+	portfolioSort, err := parsePortfolioSort(*sortBy)
+	if err != nil {
+		return err
+	}
+
+	portfolios, err := cli.portfolioService.ListPortfolios(ctx, models.PortfolioQuery{
+		Name:       *name,
+		Sort:       portfolioSort,
+		Descending: *descending,
+		Limit:      *limit,
+		Offset:     *offset,
+	})
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewPortfolioSummaries(portfolios))
+}
+
+func (cli *CLI) runPortfolioShow(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio show")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	return output.Render(cli.writer, cli.format, output.NewPortfolioView(*portfolio))
+}
+
+func (cli *CLI) runPortfolioCreate(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio create")
+	name := fs.String("name", "", "name of the portfolio")
+	var stocks stockFlags
+	fs.Var(&stocks, "stock", "position as SYMBOL:QTY:YYYY-MM-DD[:PRICE], can be repeated")
+	costBasis := fs.String("cost-basis", string(models.CostBasisAverage), "cost basis method: fifo, lifo, hifo, average or specific")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("%w: --name is required", errUsage)
+	}
+	method, err := parseCostBasisMethod(*costBasis)
+	if err != nil {
+		return err
+	}
+
+	portfolio := &models.Portfolio{Name: *name, CostBasisMethod: method}
+	for _, stock := range stocks {
+		if err := cli.fillBuyPrice(ctx, &stock); err != nil {
+			return err
+		}
+		portfolio.Stocks = append(portfolio.Stocks, stock)
+	}
+
+	if err := cli.portfolioService.CreatePortfolioManual(ctx, portfolio); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Portfolio created successfully.")
+	return nil
+}
+
+func (cli *CLI) runPortfolioDelete(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio delete")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	if err := cli.portfolioService.DeletePortfolio(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Portfolio deleted successfully.")
+	return nil
+}
+
+func (cli *CLI) runPortfolioAddPosition(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio add-position")
+	symbol := fs.String("symbol", "", "stock symbol")
+	quantity := fs.Int("quantity", 0, "number of shares")
+	date := fs.String("date", "", "purchase date (YYYY-MM-DD)")
+	price := fs.Float64("price", 0, "purchase price, defaults to the close price of the purchase date")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *symbol == "" {
+		return fmt.Errorf("%w: --symbol is required", errUsage)
+	}
+	if *quantity <= 0 {
+		return fmt.Errorf("%w: --quantity must be greater than zero", errUsage)
+	}
+	buyDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	stock := models.Stock{
+		Symbol:   strings.ToUpper(*symbol),
+		Quantity: *quantity,
+		BuyDate:  buyDate,
+		BuyPrice: *price,
+	}
+	if err := cli.fillBuyPrice(ctx, &stock); err != nil {
+		return err
+	}
+	portfolio.Stocks = append(portfolio.Stocks, stock)
+
+	if err := cli.portfolioService.UpdatePortfolio(ctx, portfolio); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.writer, "Added %s to portfolio %d.\n", describeStock(stock), portfolio.ID)
+	return nil
+}
+
+func (cli *CLI) runPortfolioSetCostBasis(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("portfolio set-cost-basis")
+	positional, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	method, err := parseCostBasisMethod(positional[1])
+	if err != nil {
+		return err
+	}
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	portfolio.CostBasisMethod = method
+	if err := cli.portfolioService.UpdatePortfolio(ctx, portfolio); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.writer, "Portfolio %d now uses the %s cost basis method.\n", portfolio.ID, method)
+	return nil
+}
+
+func (cli *CLI) runPriceCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("price")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "price date (YYYY-MM-DD)")
+	from := fs.String("from", "", "start date of the price history (YYYY-MM-DD)")
+	to := fs.String("to", time.Now().Format("2006-01-02"), "end date of the price history (YYYY-MM-DD)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	symbol := strings.ToUpper(positional[0])
+
+	if *from != "" {
+		startDate, err := parseDate(*from)
+		if err != nil {
+			return err
+		}
+		endDate, err := parseDate(*to)
+		if err != nil {
+			return err
+		}
+		bars, err := cli.portfolioService.GetPriceHistory(ctx, symbol, startDate, endDate)
+		if err != nil {
+			return err
+		}
+		return output.Render(cli.writer, cli.format, output.NewPriceBarViews(symbol, bars))
+	}
+
+	priceDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	price, err := cli.portfolioService.GetResolvedPrice(ctx, symbol, priceDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewPriceView(symbol, price))
+}
+
+func (cli *CLI) runAPRCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("apr")
+	from := fs.String("from", "", "start date (YYYY-MM-DD), defaults to the earliest purchase date")
+	to := fs.String("to", "", "end date (YYYY-MM-DD), defaults to today")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	startDate, endDate, err := parsePeriod(*portfolio, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	apr, err := cli.portfolioService.CalculateAPR(ctx, portfolio, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	view := output.NewAPRView(portfolio.ID, startDate, endDate, apr)
+
+	// The money-weighted return may have no solution, which does not invalidate the APR
+	xirr, err := cli.portfolioService.CalculateXIRR(ctx, portfolio, startDate, endDate)
+	if err != nil {
+		view.XIRRError = err.Error()
+	} else {
+		view.XIRR = &xirr
+	}
+	return output.Render(cli.writer, cli.format, view)
+}
+
+func (cli *CLI) runTWRCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("twr")
+	from := fs.String("from", "", "start date (YYYY-MM-DD), defaults to the earliest purchase date")
+	to := fs.String("to", "", "end date (YYYY-MM-DD), defaults to today")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	startDate, endDate, err := parsePeriod(*portfolio, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	report, err := cli.portfolioService.CalculateTWR(ctx, portfolio, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewTWRView(portfolio.ID, report))
+}
+
+func (cli *CLI) runBenchmarkCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("benchmark")
+	symbol := fs.String("symbol", "SPY", "benchmark symbol")
+	from := fs.String("from", "", "start date (YYYY-MM-DD), defaults to the earliest purchase date")
+	to := fs.String("to", "", "end date (YYYY-MM-DD), defaults to today")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *symbol == "" {
+		return fmt.Errorf("%w: --symbol is required", errUsage)
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	startDate, endDate, err := parsePeriod(*portfolio, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	comparison, err := cli.portfolioService.CompareToBenchmark(ctx, portfolio, strings.ToUpper(*symbol), startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewBenchmarkView(portfolio.ID, comparison))
+}
+
+func (cli *CLI) runRiskCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("risk")
+	benchmark := fs.String("benchmark", "SPY", "benchmark symbol for beta and alpha")
+	riskFree := fs.Float64("risk-free", 0, "annual risk-free rate as a fraction, e.g. 0.04")
+	from := fs.String("from", "", "start date (YYYY-MM-DD), defaults to the earliest purchase date")
+	to := fs.String("to", "", "end date (YYYY-MM-DD), defaults to today")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *benchmark == "" {
+		return fmt.Errorf("%w: --benchmark is required", errUsage)
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	startDate, endDate, err := parsePeriod(*portfolio, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	report, err := cli.portfolioService.CalculateRisk(ctx, portfolio, strings.ToUpper(*benchmark), *riskFree, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewRiskView(portfolio.ID, report))
+}
+
+func (cli *CLI) runTransactionCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing transaction subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return cli.runTransactionList(ctx, args[1:])
+	case "add":
+		return cli.runTransactionAdd(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown transaction subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runTransactionList(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("transaction list")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	transactions, err := cli.portfolioService.GetTransactions(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewTransactionViews(transactions))
+}
+
+func (cli *CLI) runTransactionAdd(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("transaction add")
+	transactionType := fs.String("type", "", "buy, sell, dividend, fee or split")
+	symbol := fs.String("symbol", "", "stock symbol")
+	quantity := fs.Float64("quantity", 0, "number of shares, or the ratio of a split")
+	price := fs.Float64("price", 0, "price per share, or the total amount of a dividend or fee")
+	commission := fs.Float64("commission", 0, "commission paid")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "transaction date (YYYY-MM-DD)")
+	var lots lotFlags
+	fs.Var(&lots, "lot", "lot sold as LOT:QTY, can be repeated")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	transactionDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	transaction := &models.Transaction{
+		PortfolioID: id,
+		Symbol:      strings.ToUpper(*symbol),
+		Type:        models.TransactionType(strings.ToLower(*transactionType)),
+		Date:        transactionDate,
+		Quantity:    *quantity,
+		Price:       *price,
+		Commission:  *commission,
+		Lots:        lots,
+	}
+	if err := cli.portfolioService.RecordTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Transaction recorded successfully.")
+	return nil
+}
+
+func (cli *CLI) runGainsCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("gains")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "valuation date (YYYY-MM-DD)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	valuationDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	gains, err := cli.portfolioService.CalculateGains(ctx, portfolio, valuationDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewGainsView(portfolio.ID, valuationDate, gains))
+}
+
+func (cli *CLI) runLotsCommand(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("lots")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "date of the holdings (YYYY-MM-DD)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	lotsDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	lots, err := cli.portfolioService.GetOpenLots(ctx, portfolio, lotsDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewLotViews(lots))
+}
+
+func (cli *CLI) runRealizedCommand(ctx context.Context, args []string) error {
+	now := time.Now()
+	fs := cli.newFlagSet("realized")
+	from := fs.String("from", time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), "start date (YYYY-MM-DD), defaults to the start of the year")
+	to := fs.String("to", now.Format("2006-01-02"), "end date (YYYY-MM-DD), defaults to today")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	startDate, err := parseDate(*from)
+	if err != nil {
+		return err
+	}
+	endDate, err := parseDate(*to)
+	if err != nil {
+		return err
+	}
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	report, err := cli.portfolioService.GetRealizedGains(ctx, portfolio, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewRealizedGainsView(portfolio, report))
+}
+
+func (cli *CLI) runCashCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing cash subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return cli.runCashList(ctx, args[1:])
+	case "add":
+		return cli.runCashAdd(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown cash subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runCashList(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("cash list")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "balance date (YYYY-MM-DD)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	balanceDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+	portfolio, err := cli.portfolioByArg(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	entries, err := cli.portfolioService.GetCashEntries(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+	balance, err := cli.portfolioService.GetCashBalance(ctx, portfolio, balanceDate)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewCashView(portfolio.ID, entries, balanceDate, balance))
+}
+
+func (cli *CLI) runCashAdd(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("cash add")
+	entryType := fs.String("type", "", "deposit, withdrawal, interest or fee")
+	amount := fs.Float64("amount", 0, "amount of the entry")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "entry date (YYYY-MM-DD)")
+	description := fs.String("description", "", "optional description")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+	entryDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	entry := &models.CashEntry{
+		PortfolioID: id,
+		Type:        models.CashEntryType(strings.ToLower(*entryType)),
+		Date:        entryDate,
+		Amount:      *amount,
+		Description: *description,
+	}
+	if err := cli.portfolioService.RecordCashEntry(ctx, entry); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Cash entry recorded successfully.")
+	return nil
+}
+
+func (cli *CLI) runCacheCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing cache subcommand", errUsage)
+	}
+	if cli.priceCache == nil {
+		return fmt.Errorf("the price cache is not enabled")
+	}
+
+	switch args[0] {
+	case "warm":
+		return cli.runCacheWarm(ctx, args[1:])
+	case "clear":
+		return cli.runCacheClear(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown cache subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runCacheWarm(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("cache warm")
+	from := fs.String("from", "", "start date (YYYY-MM-DD), defaults to the earliest purchase of each symbol")
+	to := fs.String("to", time.Now().Format("2006-01-02"), "end date (YYYY-MM-DD), defaults to today")
+	symbols, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+
+	endDate, err := parseDate(*to)
+	if err != nil {
+		return err
+	}
+	var startDate time.Time
+	if *from != "" {
+		if startDate, err = parseDate(*from); err != nil {
+			return err
+		}
+	}
+
+	// Without symbols, every symbol held is warmed from its earliest purchase
+	periods := make(map[string]time.Time)
+	var order []string
+	if len(symbols) == 0 {
+		portfolios, err := cli.portfolioService.GetAllPortfolios(ctx)
+		if err != nil {
+			return err
+		}
+		for _, portfolio := range portfolios {
+			for _, stock := range portfolio.Stocks {
+				start, seen := periods[stock.Symbol]
+				if !seen {
+					order = append(order, stock.Symbol)
+				}
+				if !seen || stock.BuyDate.Before(start) {
+					periods[stock.Symbol] = stock.BuyDate
+				}
+			}
+		}
+	} else {
+		if startDate.IsZero() {
+			return fmt.Errorf("%w: --from is required when symbols are given", errUsage)
+		}
+		for _, symbol := range symbols {
+			symbol = strings.ToUpper(symbol)
+			if _, seen := periods[symbol]; !seen {
+				order = append(order, symbol)
+			}
+			periods[symbol] = startDate
+		}
+	}
+
+	total := 0
+	for _, symbol := range order {
+		start := periods[symbol]
+		if !startDate.IsZero() {
+			start = startDate
+		}
+		count, err := cli.priceCache.WarmPrices(ctx, []string{symbol}, start, endDate)
+		if err != nil {
+			return err
+		}
+		total += count
+	}
+	fmt.Fprintf(cli.writer, "%d prices of %d symbols cached.\n", total, len(order))
+	return nil
+}
+
+func (cli *CLI) runCacheClear(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("cache clear")
+	symbols, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+
+	for i := range symbols {
+		symbols[i] = strings.ToUpper(symbols[i])
+	}
+	if err := cli.priceCache.InvalidatePrices(ctx, symbols); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Price cache cleared.")
+	return nil
+}
+
+func (cli *CLI) runManualPriceCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing manual-price subcommand", errUsage)
+	}
+	if cli.manualPrices == nil {
+		return fmt.Errorf("manual prices are not enabled")
+	}
+
+	switch args[0] {
+	case "list":
+		return cli.runManualPriceList(ctx, args[1:])
+	case "set":
+		return cli.runManualPriceSet(ctx, args[1:])
+	case "delete":
+		return cli.runManualPriceDelete(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown manual-price subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runManualPriceList(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("manual-price list")
+	positional, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("%w: expected at most one symbol", errUsage)
+	}
+
+	symbol := ""
+	if len(positional) == 1 {
+		symbol = strings.ToUpper(positional[0])
+	}
+	prices, err := cli.manualPrices.GetManualPrices(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewManualPriceViews(prices))
+}
+
+func (cli *CLI) runManualPriceSet(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("manual-price set")
+	date := fs.String("date", "", "date of the price (YYYY-MM-DD)")
+	price := fs.Float64("price", 0, "price of the symbol on the date")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *date == "" {
+		return fmt.Errorf("%w: --date is required", errUsage)
+	}
+	if *price <= 0 {
+		return fmt.Errorf("%w: --price must be positive", errUsage)
+	}
+	priceDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	if err := cli.manualPrices.SetManualPrice(ctx, strings.ToUpper(positional[0]), priceDate, *price); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Manual price saved successfully.")
+	return nil
+}
+
+func (cli *CLI) runManualPriceDelete(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("manual-price delete")
+	date := fs.String("date", "", "date of the price (YYYY-MM-DD)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *date == "" {
+		return fmt.Errorf("%w: --date is required", errUsage)
+	}
+	priceDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	if err := cli.manualPrices.DeleteManualPrice(ctx, strings.ToUpper(positional[0]), priceDate); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.writer, "Manual price deleted successfully.")
+	return nil
+}
+
+func (cli *CLI) runDBCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing db subcommand", errUsage)
+	}
+	if cli.migrator == nil {
+		return fmt.Errorf("schema migrations are not enabled")
+	}
+
+	switch args[0] {
+	case "migrate":
+		return cli.runDBMigrate(ctx, args[1:])
+	case "status":
+		return cli.runDBStatus(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown db subcommand %q", errUsage, args[0])
+	}
+}
+
+func (cli *CLI) runDBMigrate(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("db migrate")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	applied, err := cli.migrator.Migrate(ctx)
+	for _, m := range applied {
+		fmt.Fprintf(cli.writer, "Applied migration %04d %s.\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(cli.writer, "The database schema is up to date.")
+	}
+	return nil
+}
+
+func (cli *CLI) runDBStatus(ctx context.Context, args []string) error {
+	fs := cli.newFlagSet("db status")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	status, err := cli.migrator.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return output.Render(cli.writer, cli.format, output.NewMigrationViews(status))
+}
+
+func (cli *CLI) portfolioByArg(ctx context.Context, arg string) (*models.Portfolio, error) {
+	id, err := parseID(arg)
+	if err != nil {
+		return nil, err
+	}
+	return cli.portfolioService.GetPortfolioByID(ctx, id)
+}
+
+// fillBuyPrice looks up the close price of the purchase date when no price was given.
+func (cli *CLI) fillBuyPrice(ctx context.Context, stock *models.Stock) error {
+	if stock.BuyPrice > 0 {
+		return nil
+	}
+	price, err := cli.portfolioService.GetPriceClose(ctx, stock.Symbol, stock.BuyDate)
+	if err != nil {
+		return fmt.Errorf("getting price for %s on %s: %w", stock.Symbol, stock.BuyDate.Format("2006-01-02"), err)
+	}
+	stock.BuyPrice = price
+	return nil
+}
+
+// newFlagSet creates a flag set that also accepts the --output option.
+func (cli *CLI) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&cli.format, "output", "output format: table, json, csv or yaml")
+	fs.Var(&cli.format, "o", "shorthand for --output")
+	return fs
+}
+
+// parseArgs parses flags that may appear before or after the positional
+// arguments and checks that exactly want positional arguments were given. A
+// negative want accepts any number of them.
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errUsage, fs.Name(), err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if want >= 0 && len(positional) != want {
+		return nil, fmt.Errorf("%w: %s expects %d argument(s), got %d", errUsage, fs.Name(), want, len(positional))
+	}
+	return positional, nil
+}
+
+func parseID(input string) (int, error) {
+	id, err := strconv.Atoi(input)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid portfolio ID %q", errUsage, input)
+	}
+	return id, nil
+}
+
+func parseDate(input string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", input)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid date %q, expected YYYY-MM-DD", errUsage, input)
+	}
+	return date, nil
+}
+
+// parsePeriod parses the --from and --to flags of a return calculation, which
+// default to the earliest purchase date of the portfolio and today.
+func parsePeriod(portfolio models.Portfolio, from, to string) (time.Time, time.Time, error) {
+	var err error
+	startDate := earliestBuyDate(portfolio)
+	if from != "" {
+		if startDate, err = parseDate(from); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	endDate := time.Now()
+	if to != "" {
+		if endDate, err = parseDate(to); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return startDate, endDate, nil
+}
+
+func parseCostBasisMethod(input string) (models.CostBasisMethod, error) {
+	for _, method := range models.CostBasisMethods {
+		if strings.EqualFold(input, string(method)) {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown cost basis method %q", errUsage, input)
+}
+
+func parsePortfolioSort(input string) (models.PortfolioSort, error) {
+	for _, option := range models.PortfolioSorts {
+		if strings.EqualFold(input, string(option)) {
+			return option, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown portfolio sort %q", errUsage, input)
+}
+
+// lotFlags collects repeated --lot LOT:QTY values.
+type lotFlags []models.LotSelection
+
+func (l *lotFlags) String() string {
+	return fmt.Sprint(len(*l))
+}
+
+func (l *lotFlags) Set(value string) error {
+	id, qty, ok := strings.Cut(value, ":")
+	if !ok || id == "" {
+		return fmt.Errorf("expected LOT:QTY, got %q", value)
+	}
+	quantity, err := strconv.ParseFloat(qty, 64)
+	if err != nil || quantity <= 0 {
+		return fmt.Errorf("invalid quantity %q", qty)
+	}
+	*l = append(*l, models.LotSelection{LotID: strings.ToUpper(id), Quantity: quantity})
+	return nil
+}
+
+// stockFlags collects repeated --stock SYMBOL:QTY:YYYY-MM-DD[:PRICE] values.
+type stockFlags []models.Stock
+
+func (s *stockFlags) String() string {
+	return fmt.Sprint(len(*s))
+}
+
+func (s *stockFlags) Set(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 && len(parts) != 4 {
+		return fmt.Errorf("expected SYMBOL:QTY:YYYY-MM-DD[:PRICE], got %q", value)
+	}
+
+	quantity, err := strconv.Atoi(parts[1])
+	if err != nil || quantity <= 0 {
+		return fmt.Errorf("invalid quantity %q", parts[1])
+	}
+	buyDate, err := time.Parse("2006-01-02", parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid date %q", parts[2])
+	}
+
+	var buyPrice float64
+	if len(parts) == 4 {
+		buyPrice, err = strconv.ParseFloat(parts[3], 64)
+		if err != nil || buyPrice <= 0 {
+			return fmt.Errorf("invalid price %q", parts[3])
+		}
+	}
+
+	*s = append(*s, models.Stock{
+		Symbol:   strings.ToUpper(parts[0]),
+		Quantity: quantity,
+		BuyDate:  buyDate,
+		BuyPrice: buyPrice,
+	})
+	return nil
+}
@@ -0,0 +1,538 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCommandCLI(service *MockPortfolioService) (*CLI, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	cli := NewCLI(service, strings.NewReader(""), &stdout)
+	cli.SetErrorOutput(&stderr)
+	return cli, &stdout, &stderr
+}
+
+func TestExecute_PortfolioList(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("ListPortfolios", models.PortfolioQuery{Sort: models.PortfolioSortID}).Return([]models.Portfolio{
+		{ID: 1, Name: "Growth", Stocks: []models.Stock{{Symbol: "AAPL"}}},
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "list"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "ID  NAME    POSITIONS\n1   Growth  1\n", stdout.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestExecute_PortfolioListPage(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	query := models.PortfolioQuery{Name: "tech", Sort: models.PortfolioSortName, Descending: true, Limit: 10, Offset: 20}
+	mockService.On("ListPortfolios", query).Return([]models.Portfolio{}, nil)
+
+	cli, _, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "list", "--name", "tech", "--sort", "name", "--desc", "--limit", "10", "--offset", "20"})
+	require.Equal(t, ExitOK, code)
+	mockService.AssertExpectations(t)
+
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"portfolio", "list", "--sort", "size"}))
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"portfolio", "list", "--limit", "-1"}))
+}
+
+func TestExecute_PortfolioShowNotFound(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetPortfolioByID", 7).Return((*models.Portfolio)(nil), fmt.Errorf("portfolio 7 %w", models.ErrNotFound))
+
+	cli, _, stderr := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "show", "7"})
+
+	require.Equal(t, ExitNotFound, code)
+	require.Equal(t, "Error: portfolio 7 not found\n", stderr.String())
+}
+
+func TestExecute_ErrorExitCodes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		err  error
+		code int
+		hint string
+	}{
+		{"conflict", fmt.Errorf("duplicate name: %w", models.ErrConflict), ExitConflict, ""},
+		{"validation", fmt.Errorf("%w: the amount must be positive", models.ErrValidation), ExitInvalid, ""},
+		{"price", fmt.Errorf("%w for AAPL", models.ErrPriceUnavailable), ExitUnavailable, "manual-price set"},
+		{"rate limit", fmt.Errorf("%w: %w", models.ErrPriceUnavailable, models.ErrRateLimited), ExitUnavailable, "try again later"},
+		{"provider config", fmt.Errorf("%w: %w", models.ErrPriceUnavailable, models.ErrProviderConfig), ExitUnavailable, "API key"},
+		{"other", errors.New("disk full"), ExitError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockPortfolioService)
+			mockService.On("DeletePortfolio", 1).Return(tt.err)
+
+			cli, _, stderr := newTestCommandCLI(mockService)
+			require.Equal(t, tt.code, cli.Execute(ctx, []string{"portfolio", "delete", "1"}))
+			require.True(t, strings.HasPrefix(stderr.String(), "Error: "+tt.err.Error()+"\n"))
+			require.Contains(t, stderr.String(), tt.hint)
+		})
+	}
+}
+
+func TestExecute_Interrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockService := new(MockPortfolioService)
+	mockService.On("ListPortfolios", models.PortfolioQuery{Sort: models.PortfolioSortID}).Return([]models.Portfolio(nil), ctx.Err())
+
+	cli, _, stderr := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "list"})
+
+	require.Equal(t, ExitInterrupted, code)
+	require.Equal(t, "Interrupted.\n", stderr.String())
+}
+
+func TestExecute_PortfolioCreate(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetPriceClose", "MSFT", time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC)).Return(170.0, nil)
+	mockService.On("CreatePortfolioManual", mock.AnythingOfType("*models.Portfolio")).Return(nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "create", "--name", "Tech",
+		"--stock", "aapl:10:2020-01-15:300", "--stock", "MSFT:5:2020-02-03"})
+
+	require.Equal(t, ExitOK, code)
+	require.Contains(t, stdout.String(), "Portfolio created successfully.")
+	mockService.AssertCalled(t, "CreatePortfolioManual", mock.MatchedBy(func(p *models.Portfolio) bool {
+		return p.Name == "Tech" && len(p.Stocks) == 2 &&
+			p.Stocks[0].Symbol == "AAPL" && p.Stocks[0].BuyPrice == 300.0 &&
+			p.Stocks[1].Symbol == "MSFT" && p.Stocks[1].BuyPrice == 170.0
+	}))
+}
+
+func TestExecute_PortfolioAddPosition(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetPortfolioByID", 1).Return(&models.Portfolio{ID: 1, Name: "Tech"}, nil)
+	mockService.On("UpdatePortfolio", mock.AnythingOfType("*models.Portfolio")).Return(nil)
+
+	cli, _, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"portfolio", "add-position", "1",
+		"--symbol", "goog", "--quantity", "3", "--date", "2021-03-01", "--price", "2000"})
+
+	require.Equal(t, ExitOK, code)
+	mockService.AssertCalled(t, "UpdatePortfolio", mock.MatchedBy(func(p *models.Portfolio) bool {
+		return len(p.Stocks) == 1 && p.Stocks[0].Symbol == "GOOG" && p.Stocks[0].Quantity == 3
+	}))
+}
+
+func TestExecute_Price(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetResolvedPrice", "AAPL", date).Return(&models.ResolvedPrice{
+		RequestedDate: date,
+		PriceBar:      models.PriceBar{Date: date, Close: 305.0, Source: "csv"},
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"--output", "csv", "price", "AAPL", "--date", "2020-01-15"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "SYMBOL,DATE,TRADING DATE,CLOSE,SOURCE\nAAPL,2020-01-15,2020-01-15,305.00,csv\n", stdout.String())
+}
+
+func TestExecute_APR(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	from := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CalculateAPR", portfolio, from, to).Return(0.1, nil)
+	mockService.On("CalculateXIRR", portfolio, from, to).Return(0.12, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"apr", "--from", "2020-01-15", "1", "--to", "2021-01-15", "-o", "json"})
+
+	require.Equal(t, ExitOK, code)
+	require.JSONEq(t, `{"portfolio_id":1,"from":"2020-01-15","to":"2021-01-15","apr":0.1,"xirr":0.12}`, stdout.String())
+}
+
+func TestExecute_APRWithoutXIRR(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	from := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CalculateAPR", portfolio, from, to).Return(0.1, nil)
+	mockService.On("CalculateXIRR", portfolio, from, to).Return(0.0, errors.New("the money-weighted return did not converge"))
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"apr", "1", "--from", "2020-01-15", "--to", "2021-01-15", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "PORTFOLIO,FROM,TO,APR (%),XIRR (%)\n1,2020-01-15,2021-01-15,10.00,n/a\n", stdout.String())
+}
+
+func TestExecute_Errors(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("DeletePortfolio", 3).Return(errors.New("database is locked"))
+
+	cli, _, stderr := newTestCommandCLI(mockService)
+
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"unknown"}))
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"--output", "xml", "portfolio", "list"}))
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"portfolio", "show"}))
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"price", "AAPL", "--date", "15/01/2020"}))
+	require.Equal(t, ExitError, cli.Execute(ctx, []string{"portfolio", "delete", "3"}))
+	require.Contains(t, stderr.String(), "database is locked")
+}
+
+func TestExecute_TransactionAdd(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("RecordTransaction", mock.AnythingOfType("*models.Transaction")).Return(nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"transaction", "add", "1", "--type", "SELL", "--symbol", "aapl",
+		"--quantity", "4", "--price", "120", "--commission", "1.5", "--date", "2020-06-01"})
+
+	require.Equal(t, ExitOK, code)
+	require.Contains(t, stdout.String(), "Transaction recorded successfully.")
+	mockService.AssertCalled(t, "RecordTransaction", mock.MatchedBy(func(tx *models.Transaction) bool {
+		return tx.PortfolioID == 1 && tx.Type == models.TransactionSell && tx.Symbol == "AAPL" &&
+			tx.Quantity == 4 && tx.Price == 120 && tx.Commission == 1.5 &&
+			tx.Date.Equal(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	}))
+}
+
+func TestExecute_Gains(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	date := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CalculateGains", portfolio, date).Return(&models.Gains{
+		Positions:     []models.Position{{Symbol: "AAPL", Quantity: 5, CostBasis: 500, MarketValue: 600, RealizedGain: 100, UnrealizedGain: 100}},
+		Realized:      100,
+		Unrealized:    100,
+		Interest:      5,
+		Cash:          250,
+		HoldingsValue: 600,
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"gains", "1", "--date", "2021-06-01", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "SYMBOL,QUANTITY,COST BASIS,MARKET VALUE,REALIZED,UNREALIZED,INCOME,FEES\n"+
+		"AAPL,5,500.00,600.00,100.00,100.00,0.00,0.00\n"+
+		"CASH,,,250.00,,,5.00,\n"+
+		"TOTAL,,,850.00,100.00,100.00,5.00,0.00\n", stdout.String())
+}
+
+func TestExecute_SetCostBasis(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetPortfolioByID", 1).Return(&models.Portfolio{ID: 1, Name: "Tech"}, nil)
+	mockService.On("UpdatePortfolio", mock.AnythingOfType("*models.Portfolio")).Return(nil)
+
+	cli, _, _ := newTestCommandCLI(mockService)
+
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"portfolio", "set-cost-basis", "1", "HIFO"}))
+	mockService.AssertCalled(t, "UpdatePortfolio", mock.MatchedBy(func(p *models.Portfolio) bool {
+		return p.CostBasisMethod == models.CostBasisHIFO
+	}))
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"portfolio", "set-cost-basis", "1", "random"}))
+}
+
+func TestExecute_Realized(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech", CostBasisMethod: models.CostBasisFIFO}
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("GetRealizedGains", portfolio, from, to).Return(&models.RealizedGainsReport{
+		From: from,
+		To:   to,
+		Sales: []models.RealizedSale{{
+			Symbol: "AAPL", LotID: "S7", BuyDate: time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC), SellDate: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+			Quantity: 8, Proceeds: 1600, CostBasis: 800, LongTerm: true,
+		}},
+		LongTerm: 800,
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"realized", "1", "--from", "2020-01-01", "--to", "2020-12-31", "-o", "json"})
+
+	require.Equal(t, ExitOK, code)
+	require.JSONEq(t, `{"portfolio_id":1,"method":"fifo","from":"2020-01-01","to":"2020-12-31",
+		"sales":[{"symbol":"AAPL","lot_id":"S7","buy_date":"2019-01-15","sell_date":"2020-06-01","quantity":8,
+		"proceeds":1600,"cost_basis":800,"gain":800,"term":"long"}],
+		"short_term":0,"long_term":800,"total":800}`, stdout.String())
+}
+
+func TestExecute_CashAddAndList(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	date := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	mockService.On("RecordCashEntry", mock.AnythingOfType("*models.CashEntry")).Return(nil)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("GetCashEntries", portfolio).Return([]models.CashEntry{
+		{ID: 1, PortfolioID: 1, Type: models.CashDeposit, Date: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), Amount: 1000},
+		{ID: 2, PortfolioID: 1, Type: models.CashWithdrawal, Date: time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC), Amount: 200, Description: "rent"},
+	}, nil)
+	mockService.On("GetCashBalance", portfolio, date).Return(300.0, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+
+	code := cli.Execute(ctx, []string{"cash", "add", "1", "--type", "Deposit", "--amount", "1000", "--date", "2021-01-04"})
+	require.Equal(t, ExitOK, code)
+	mockService.AssertCalled(t, "RecordCashEntry", mock.MatchedBy(func(e *models.CashEntry) bool {
+		return e.PortfolioID == 1 && e.Type == models.CashDeposit && e.Amount == 1000
+	}))
+
+	stdout.Reset()
+	code = cli.Execute(ctx, []string{"cash", "list", "1", "--date", "2021-01-31", "-o", "csv"})
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "ID,DATE,TYPE,AMOUNT,DESCRIPTION\n"+
+		"1,2021-01-04,deposit,1000.00,\n"+
+		"2,2021-01-20,withdrawal,-200.00,rent\n"+
+		"BALANCE,2021-01-31,,300.00,\n", stdout.String())
+}
+
+func TestExecute_TWR(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	from := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CalculateTWR", portfolio, from, to).Return(&models.TWRReport{
+		From: from,
+		To:   to,
+		Points: []models.ValuationPoint{
+			{Date: from, HoldingsValue: 1000, Value: 1000},
+			{Date: to, Cash: 500, HoldingsValue: 1100, Value: 1600, NetFlow: 500, Return: 0.1, CumulativeReturn: 0.1},
+		},
+		Cumulative: 0.1,
+		Annualized: 0.5,
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"twr", "1", "--from", "2021-01-04", "--to", "2021-01-05", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "DATE,CASH,HOLDINGS,VALUE,NET FLOW,RETURN (%),CUMULATIVE (%)\n"+
+		"2021-01-04,0.00,1000.00,1000.00,0.00,0.00,0.00\n"+
+		"2021-01-05,500.00,1100.00,1600.00,500.00,10.00,10.00\n"+
+		"CUMULATIVE,,,,,,10.00\n"+
+		"ANNUALIZED,,,,,,50.00\n", stdout.String())
+}
+
+func TestExecute_Benchmark(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	from := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CompareToBenchmark", portfolio, "QQQ", from, to).Return(&models.BenchmarkComparison{
+		Symbol: "QQQ",
+		From:   from,
+		To:     to,
+		Points: []models.BenchmarkPoint{
+			{Date: from, PortfolioValue: 1000, BenchmarkValue: 1000},
+			{Date: to, PortfolioValue: 1100, BenchmarkValue: 1050, PortfolioReturn: 0.1, BenchmarkReturn: 0.05},
+		},
+		PortfolioReturn:  0.1,
+		BenchmarkReturn:  0.05,
+		TrackingError:    0.2,
+		InformationRatio: 1.5,
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"benchmark", "1", "--symbol", "qqq", "--from", "2021-01-04", "--to", "2021-01-05", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "DATE,PORTFOLIO,QQQ,PORTFOLIO (%),QQQ (%)\n"+
+		"2021-01-04,1000.00,1000.00,0.00,0.00\n"+
+		"2021-01-05,1100.00,1050.00,10.00,5.00\n"+
+		"RETURN (%),,,10.00,5.00\n"+
+		"EXCESS RETURN (%),,,5.00,\n"+
+		"TRACKING ERROR (%),,,20.00,\n"+
+		"INFORMATION RATIO,,,1.50,\n", stdout.String())
+}
+
+func TestExecute_Risk(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	portfolio := &models.Portfolio{ID: 1, Name: "Tech"}
+	from := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPortfolioByID", 1).Return(portfolio, nil)
+	mockService.On("CalculateRisk", portfolio, "SPY", 0.04, from, to).Return(&models.RiskReport{
+		From:           from,
+		To:             to,
+		Benchmark:      "SPY",
+		RiskFreeRate:   0.04,
+		Volatility:     0.25,
+		Sharpe:         1.2,
+		Sortino:        1.8,
+		MaxDrawdown:    -0.1,
+		DrawdownPeak:   time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC),
+		DrawdownTrough: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+		Beta:           1.1,
+		Alpha:          0.02,
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"risk", "1", "--risk-free", "0.04", "--from", "2021-01-04", "--to", "2021-03-31", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "METRIC,VALUE\n"+
+		"PERIOD,2021-01-04 - 2021-03-31\n"+
+		"RISK-FREE RATE (%),4.00\n"+
+		"VOLATILITY (%),25.00\n"+
+		"SHARPE RATIO,1.20\n"+
+		"SORTINO RATIO,1.80\n"+
+		"MAX DRAWDOWN (%),-10.00\n"+
+		"DRAWDOWN PEAK,2021-02-12\n"+
+		"DRAWDOWN TROUGH,2021-03-08\n"+
+		"BETA (SPY),1.10\n"+
+		"ALPHA (%),2.00\n", stdout.String())
+}
+
+func TestExecute_PriceHistory(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	from := time.Date(2020, 1, 14, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPriceHistory", "AAPL", from, to).Return([]models.PriceBar{
+		{Date: from, Open: 316.7, High: 317.57, Low: 312.17, Close: 312.68, Volume: 40653457, Source: "fmp"},
+		{Date: to, Open: 311.85, High: 315.5, Low: 309.55, Close: 311.34, Volume: 30480900, Source: "fmp"},
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	code := cli.Execute(ctx, []string{"price", "aapl", "--from", "2020-01-14", "--to", "2020-01-15", "-o", "csv"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "SYMBOL,DATE,OPEN,HIGH,LOW,CLOSE,VOLUME,SOURCE\n"+
+		"AAPL,2020-01-14,316.70,317.57,312.17,312.68,40653457,fmp\n"+
+		"AAPL,2020-01-15,311.85,315.50,309.55,311.34,30480900,fmp\n", stdout.String())
+}
+
+func TestExecute_CacheWarm(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetAllPortfolios").Return([]models.Portfolio{
+		{ID: 1, Stocks: []models.Stock{
+			{Symbol: "AAPL", BuyDate: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC)},
+			{Symbol: "MSFT", BuyDate: time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC)},
+		}},
+		{ID: 2, Stocks: []models.Stock{
+			{Symbol: "AAPL", BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)},
+		}},
+	}, nil)
+	to := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockCache := new(MockPriceCache)
+	mockCache.On("WarmPrices", []string{"AAPL"}, time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), to).Return(250, nil)
+	mockCache.On("WarmPrices", []string{"MSFT"}, time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC), to).Return(175, nil)
+
+	cli, stdout, _ := newTestCommandCLI(mockService)
+	cli.SetPriceCache(mockCache)
+	code := cli.Execute(ctx, []string{"cache", "warm", "--to", "2021-01-15"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "425 prices of 2 symbols cached.\n", stdout.String())
+	mockCache.AssertExpectations(t)
+
+	// Symbols given explicitly need a start date.
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"cache", "warm", "SPY"}))
+}
+
+func TestExecute_CacheClear(t *testing.T) {
+	ctx := context.Background()
+	mockCache := new(MockPriceCache)
+	mockCache.On("InvalidatePrices", []string{"AAPL", "MSFT"}).Return(nil)
+
+	cli, stdout, _ := newTestCommandCLI(new(MockPortfolioService))
+	require.Equal(t, ExitError, cli.Execute(ctx, []string{"cache", "clear"}))
+
+	cli.SetPriceCache(mockCache)
+	code := cli.Execute(ctx, []string{"cache", "clear", "aapl", "msft"})
+
+	require.Equal(t, ExitOK, code)
+	require.Equal(t, "Price cache cleared.\n", stdout.String())
+}
+
+func TestExecute_ManualPrice(t *testing.T) {
+	ctx := context.Background()
+	mockPrices := new(MockManualPrices)
+	date := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	mockPrices.On("SetManualPrice", "AAPL", date, 127.14).Return(nil)
+	mockPrices.On("GetManualPrices", "").Return([]models.ManualPrice{{Symbol: "AAPL", Date: date, Price: 127.14}}, nil)
+	mockPrices.On("DeleteManualPrice", "AAPL", date).Return(nil)
+
+	cli, stdout, _ := newTestCommandCLI(new(MockPortfolioService))
+	require.Equal(t, ExitError, cli.Execute(ctx, []string{"manual-price", "list"}))
+
+	cli.SetManualPrices(mockPrices)
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"manual-price", "set", "aapl", "--price", "127.14"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"manual-price", "set", "aapl", "--date", "2021-01-15", "--price", "127.14"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"manual-price", "list", "-o", "csv"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"manual-price", "delete", "aapl", "--date", "2021-01-15"}))
+
+	require.Equal(t, "Manual price saved successfully.\nSYMBOL,DATE,PRICE\nAAPL,2021-01-15,127.14\nManual price deleted successfully.\n", stdout.String())
+	mockPrices.AssertExpectations(t)
+}
+
+func TestExecute_DB(t *testing.T) {
+	ctx := context.Background()
+	appliedAt := time.Date(2021, 1, 15, 10, 30, 0, 0, time.UTC)
+	mockMigrator := new(MockMigrator)
+	mockMigrator.On("Migrate").Return([]models.SchemaMigration{{Version: 2, Name: "add_currency", AppliedAt: &appliedAt}}, nil).Once()
+	mockMigrator.On("Migrate").Return([]models.SchemaMigration(nil), nil).Once()
+	mockMigrator.On("MigrationStatus").Return([]models.SchemaMigration{
+		{Version: 1, Name: "initial_schema", AppliedAt: &appliedAt},
+		{Version: 2, Name: "add_currency"},
+	}, nil)
+
+	cli, stdout, _ := newTestCommandCLI(new(MockPortfolioService))
+	require.Equal(t, ExitError, cli.Execute(ctx, []string{"db", "status"}))
+
+	cli.SetMigrator(mockMigrator)
+	require.Equal(t, ExitUsage, cli.Execute(ctx, []string{"db", "rollback"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"db", "status", "-o", "csv"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"db", "migrate"}))
+	require.Equal(t, ExitOK, cli.Execute(ctx, []string{"db", "migrate"}))
+
+	require.Equal(t, "VERSION,NAME,STATUS,APPLIED AT\n"+
+		"1,initial_schema,applied,2021-01-15T10:30:00Z\n"+
+		"2,add_currency,pending,\n"+
+		"Applied migration 0002 add_currency.\n"+
+		"The database schema is up to date.\n", stdout.String())
+	mockMigrator.AssertExpectations(t)
+
+	require.Equal(t, "db", CommandName([]string{"-o", "json", "db", "status"}))
+	require.Equal(t, "", CommandName(nil))
+}
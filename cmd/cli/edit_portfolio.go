@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// editPortfolio lets the user modify a working copy of the portfolio and only
+// persists it once the changes are confirmed.
+func (cli *CLI) editPortfolio(ctx context.Context, portfolio *models.Portfolio) {
+	edited := copyPortfolio(portfolio)
+
+	for {
+		fmt.Fprintf(cli.writer, "\nEditing portfolio: %s\n", edited.Name)
+		cli.printPositions(edited)
+		fmt.Fprintln(cli.writer, "Select an option:")
+		fmt.Fprintln(cli.writer, "1. Rename portfolio")
+		fmt.Fprintln(cli.writer, "2. Add position")
+		fmt.Fprintln(cli.writer, "3. Remove position")
+		fmt.Fprintln(cli.writer, "4. Edit position")
+		fmt.Fprintln(cli.writer, "5. Preview changes")
+		fmt.Fprintln(cli.writer, "6. Save changes")
+		fmt.Fprintln(cli.writer, "7. Cancel")
+
+		input, err := cli.prompt("Option: ")
+		if err != nil {
+			fmt.Fprintf(cli.writer, "Error reading input: %v\n", err)
+			return
+		}
+
+		switch input {
+		case "1":
+			cli.renamePortfolio(edited)
+		case "2":
+			cli.addPosition(ctx, edited)
+		case "3":
+			cli.removePosition(edited)
+		case "4":
+			cli.editPosition(edited)
+		case "5":
+			cli.printChanges(portfolio, edited)
+		case "6":
+			changes := diffPortfolios(portfolio, edited)
+			if len(changes) == 0 {
+				fmt.Fprintln(cli.writer, "No changes to save.")
+				return
+			}
+			cli.printChanges(portfolio, edited)
+
+			confirm, err := cli.prompt("Save these changes? (y/N): ")
+			if err != nil {
+				fmt.Fprintf(cli.writer, "Error reading input: %v\n", err)
+				return
+			}
+			if !strings.EqualFold(confirm, "y") {
+				fmt.Fprintln(cli.writer, "Changes not saved.")
+				continue
+			}
+
+			if err := cli.portfolioService.UpdatePortfolio(ctx, edited); err != nil {
+				fmt.Fprintf(cli.writer, "Error updating portfolio: %v\n", err)
+				return
+			}
+			fmt.Fprintln(cli.writer, "Portfolio updated successfully.")
+			return
+		case "7":
+			fmt.Fprintln(cli.writer, "Edit cancelled, no changes were saved.")
+			return
+		default:
+			fmt.Fprintln(cli.writer, "Invalid option.")
+		}
+	}
+}
+
+func (cli *CLI) renamePortfolio(portfolio *models.Portfolio) {
+	name, err := cli.prompt("Enter the new name of the portfolio: ")
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading portfolio name: %v\n", err)
+		return
+	}
+	if name == "" {
+		fmt.Fprintln(cli.writer, "The portfolio name cannot be empty.")
+		return
+	}
+	portfolio.Name = name
+}
+
+func (cli *CLI) addPosition(ctx context.Context, portfolio *models.Portfolio) {
+	symbol, err := cli.prompt("Enter the stock symbol: ")
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading symbol: %v\n", err)
+		return
+	}
+	symbol = strings.ToUpper(symbol)
+	if symbol == "" {
+		fmt.Fprintln(cli.writer, "Invalid symbol.")
+		return
+	}
+
+	quantity, ok := cli.promptQuantity(fmt.Sprintf("Enter the quantity of shares for %s: ", symbol))
+	if !ok {
+		return
+	}
+
+	buyDate, ok := cli.promptDate(fmt.Sprintf("Enter the purchase date (YYYY-MM-DD) for %s: ", symbol))
+	if !ok {
+		return
+	}
+
+	priceInput, err := cli.prompt(fmt.Sprintf("Enter the purchase price for %s (leave empty to use the close price): ", symbol))
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading price: %v\n", err)
+		return
+	}
+
+	var buyPrice float64
+	if priceInput == "" {
+		buyPrice, err = cli.portfolioService.GetPriceClose(ctx, symbol, buyDate)
+		if err != nil {
+			fmt.Fprintf(cli.writer, "Error getting price for %s on %s: %v\n", symbol, buyDate.Format("2006-01-02"), err)
+			return
+		}
+	} else {
+		buyPrice, err = strconv.ParseFloat(priceInput, 64)
+		if err != nil || buyPrice < 0 {
+			fmt.Fprintln(cli.writer, "Invalid price.")
+			return
+		}
+	}
+
+	portfolio.Stocks = append(portfolio.Stocks, models.Stock{
+		Symbol:   symbol,
+		Quantity: quantity,
+		BuyDate:  buyDate,
+		BuyPrice: buyPrice,
+	})
+}
+
+func (cli *CLI) removePosition(portfolio *models.Portfolio) {
+	index, ok := cli.promptPosition(portfolio, "Enter the number of the position to remove: ")
+	if !ok {
+		return
+	}
+	portfolio.Stocks = append(portfolio.Stocks[:index], portfolio.Stocks[index+1:]...)
+}
+
+func (cli *CLI) editPosition(portfolio *models.Portfolio) {
+	index, ok := cli.promptPosition(portfolio, "Enter the number of the position to edit: ")
+	if !ok {
+		return
+	}
+	stock := &portfolio.Stocks[index]
+
+	// An empty answer keeps the current value of the field.
+	qtyInput, err := cli.prompt(fmt.Sprintf("Quantity [%d]: ", stock.Quantity))
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading quantity: %v\n", err)
+		return
+	}
+	quantity := stock.Quantity
+	if qtyInput != "" {
+		quantity, err = strconv.Atoi(qtyInput)
+		if err != nil || quantity <= 0 {
+			fmt.Fprintln(cli.writer, "Invalid quantity.")
+			return
+		}
+	}
+
+	dateInput, err := cli.prompt(fmt.Sprintf("Purchase date (YYYY-MM-DD) [%s]: ", stock.BuyDate.Format("2006-01-02")))
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading date: %v\n", err)
+		return
+	}
+	buyDate := stock.BuyDate
+	if dateInput != "" {
+		buyDate, err = time.Parse("2006-01-02", dateInput)
+		if err != nil {
+			fmt.Fprintln(cli.writer, "Invalid date.")
+			return
+		}
+	}
+
+	priceInput, err := cli.prompt(fmt.Sprintf("Purchase price [%.2f]: ", stock.BuyPrice))
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading price: %v\n", err)
+		return
+	}
+	buyPrice := stock.BuyPrice
+	if priceInput != "" {
+		buyPrice, err = strconv.ParseFloat(priceInput, 64)
+		if err != nil || buyPrice < 0 {
+			fmt.Fprintln(cli.writer, "Invalid price.")
+			return
+		}
+	}
+
+	stock.Quantity = quantity
+	stock.BuyDate = buyDate
+	stock.BuyPrice = buyPrice
+}
+
+func (cli *CLI) printPositions(portfolio *models.Portfolio) {
+	if len(portfolio.Stocks) == 0 {
+		fmt.Fprintln(cli.writer, "No positions.")
+		return
+	}
+	for i, stock := range portfolio.Stocks {
+		fmt.Fprintf(cli.writer, "%d. %s\n", i+1, describeStock(stock))
+	}
+}
+
+func (cli *CLI) printChanges(original, edited *models.Portfolio) {
+	changes := diffPortfolios(original, edited)
+	if len(changes) == 0 {
+		fmt.Fprintln(cli.writer, "No changes.")
+		return
+	}
+	fmt.Fprintln(cli.writer, "Changes:")
+	for _, change := range changes {
+		fmt.Fprintln(cli.writer, change)
+	}
+}
+
+func (cli *CLI) prompt(label string) (string, error) {
+	fmt.Fprint(cli.writer, label)
+	input, err := cli.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(input), nil
+}
+
+func (cli *CLI) promptQuantity(label string) (int, bool) {
+	input, err := cli.prompt(label)
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading quantity: %v\n", err)
+		return 0, false
+	}
+	quantity, err := strconv.Atoi(input)
+	if err != nil || quantity <= 0 {
+		fmt.Fprintln(cli.writer, "Invalid quantity.")
+		return 0, false
+	}
+	return quantity, true
+}
+
+func (cli *CLI) promptDate(label string) (time.Time, bool) {
+	input, err := cli.prompt(label)
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading date: %v\n", err)
+		return time.Time{}, false
+	}
+	date, err := time.Parse("2006-01-02", input)
+	if err != nil {
+		fmt.Fprintln(cli.writer, "Invalid date.")
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+func (cli *CLI) promptPosition(portfolio *models.Portfolio, label string) (int, bool) {
+	if len(portfolio.Stocks) == 0 {
+		fmt.Fprintln(cli.writer, "The portfolio has no positions.")
+		return 0, false
+	}
+	input, err := cli.prompt(label)
+	if err != nil {
+		fmt.Fprintf(cli.writer, "Error reading input: %v\n", err)
+		return 0, false
+	}
+	index, err := strconv.Atoi(input)
+	if err != nil || index < 1 || index > len(portfolio.Stocks) {
+		fmt.Fprintln(cli.writer, "Invalid position.")
+		return 0, false
+	}
+	return index - 1, true
+}
+
+func copyPortfolio(portfolio *models.Portfolio) *models.Portfolio {
+	stocks := make([]models.Stock, len(portfolio.Stocks))
+	copy(stocks, portfolio.Stocks)
+	return &models.Portfolio{
+		ID:     portfolio.ID,
+		Name:   portfolio.Name,
+		Stocks: stocks,
+	}
+}
+
+func describeStock(stock models.Stock) string {
+	return fmt.Sprintf("%s: %d shares bought on %s at $%.2f",
+		stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"), stock.BuyPrice)
+}
+
+// diffPortfolios describes the differences between two versions of a portfolio.
+// Stored positions are matched by ID; positions without an ID are new.
+func diffPortfolios(original, edited *models.Portfolio) []string {
+	var changes []string
+
+	if original.Name != edited.Name {
+		changes = append(changes, fmt.Sprintf("~ Name: %q -> %q", original.Name, edited.Name))
+	}
+
+	editedByID := make(map[int]models.Stock)
+	for _, stock := range edited.Stocks {
+		if stock.ID != 0 {
+			editedByID[stock.ID] = stock
+		}
+	}
+
+	for _, before := range original.Stocks {
+		after, ok := editedByID[before.ID]
+		if !ok {
+			changes = append(changes, "- "+describeStock(before))
+			continue
+		}
+
+		var fields []string
+		if before.Quantity != after.Quantity {
+			fields = append(fields, fmt.Sprintf("quantity %d -> %d", before.Quantity, after.Quantity))
+		}
+		if !before.BuyDate.Equal(after.BuyDate) {
+			fields = append(fields, fmt.Sprintf("buy date %s -> %s", before.BuyDate.Format("2006-01-02"), after.BuyDate.Format("2006-01-02")))
+		}
+		if before.BuyPrice != after.BuyPrice {
+			fields = append(fields, fmt.Sprintf("buy price $%.2f -> $%.2f", before.BuyPrice, after.BuyPrice))
+		}
+		if len(fields) > 0 {
+			changes = append(changes, fmt.Sprintf("~ %s: %s", before.Symbol, strings.Join(fields, ", ")))
+		}
+	}
+
+	for _, stock := range edited.Stocks {
+		if stock.ID == 0 {
+			changes = append(changes, "+ "+describeStock(stock))
+		}
+	}
+
+	return changes
+}
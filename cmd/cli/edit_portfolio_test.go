@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/mock"
+)
+
+func editablePortfolio() *models.Portfolio {
+	return &models.Portfolio{
+		ID:   1,
+		Name: "My Portfolio",
+		Stocks: []models.Stock{
+			{ID: 10, Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 300.0},
+			{ID: 11, Symbol: "MSFT", Quantity: 5, BuyDate: time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC), BuyPrice: 170.0},
+		},
+	}
+}
+
+// TestEditPortfolio_SaveChanges renames the portfolio, edits and removes positions and saves the result.
+func TestEditPortfolio_SaveChanges(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("UpdatePortfolio", mock.AnythingOfType("*models.Portfolio")).Return(nil)
+
+	// Simulate entry:
+	// Rename to "Renamed"
+	// Edit position 1: quantity 20, keep date, price 310
+	// Remove position 2
+	// Add GOOG: 3 shares on 2021-03-01 at 2000
+	// Save and confirm
+	input := "1\nRenamed\n4\n1\n20\n\n310\n3\n2\n2\ngoog\n3\n2021-03-01\n2000\n6\ny\n"
+	var outputBuffer bytes.Buffer
+
+	cli := CLI{
+		portfolioService: mockService,
+		reader:           bufio.NewReader(strings.NewReader(input)),
+		writer:           &outputBuffer,
+	}
+
+	original := editablePortfolio()
+	cli.editPortfolio(ctx, original)
+
+	output := outputBuffer.String()
+
+	expectedLines := []string{
+		`~ Name: "My Portfolio" -> "Renamed"`,
+		"~ AAPL: quantity 10 -> 20, buy price $300.00 -> $310.00",
+		"- MSFT: 5 shares bought on 2020-02-03 at $170.00",
+		"+ GOOG: 3 shares bought on 2021-03-01 at $2000.00",
+		"Portfolio updated successfully.",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output to contain '%s', got '%s'", line, output)
+		}
+	}
+
+	mockService.AssertCalled(t, "UpdatePortfolio", mock.MatchedBy(func(p *models.Portfolio) bool {
+		if p.ID != 1 || p.Name != "Renamed" || len(p.Stocks) != 2 {
+			return false
+		}
+		aapl, goog := p.Stocks[0], p.Stocks[1]
+		return aapl.ID == 10 && aapl.Quantity == 20 && aapl.BuyPrice == 310.0 &&
+			goog.ID == 0 && goog.Symbol == "GOOG" && goog.Quantity == 3 && goog.BuyPrice == 2000.0
+	}))
+
+	// The original portfolio must remain untouched.
+	if original.Name != "My Portfolio" || len(original.Stocks) != 2 || original.Stocks[0].Quantity != 10 {
+		t.Errorf("Expected the original portfolio to be unchanged, got %+v", original)
+	}
+}
+
+// TestEditPortfolio_Cancel verifies that cancelling discards the changes.
+func TestEditPortfolio_Cancel(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+
+	input := "1\nRenamed\n7\n"
+	var outputBuffer bytes.Buffer
+
+	cli := CLI{
+		portfolioService: mockService,
+		reader:           bufio.NewReader(strings.NewReader(input)),
+		writer:           &outputBuffer,
+	}
+
+	cli.editPortfolio(ctx, editablePortfolio())
+
+	if !strings.Contains(outputBuffer.String(), "Edit cancelled, no changes were saved.") {
+		t.Errorf("Expected cancellation message, got '%s'", outputBuffer.String())
+	}
+	mockService.AssertNotCalled(t, "UpdatePortfolio", mock.Anything)
+}
+
+// TestEditPortfolio_AddPositionWithClosePrice checks that an empty price falls back to the close price.
+func TestEditPortfolio_AddPositionWithClosePrice(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	buyDate := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetPriceClose", "GOOG", buyDate).Return(2050.0, nil)
+
+	input := "2\nGOOG\n3\n2021-03-01\n\n5\n7\n"
+	var outputBuffer bytes.Buffer
+
+	cli := CLI{
+		portfolioService: mockService,
+		reader:           bufio.NewReader(strings.NewReader(input)),
+		writer:           &outputBuffer,
+	}
+
+	cli.editPortfolio(ctx, editablePortfolio())
+
+	if !strings.Contains(outputBuffer.String(), "+ GOOG: 3 shares bought on 2021-03-01 at $2050.00") {
+		t.Errorf("Expected preview of the new position, got '%s'", outputBuffer.String())
+	}
+	mockService.AssertExpectations(t)
+}
@@ -1,16 +1,20 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/fcopulgar/stock-manager-go/cmd/cli/output"
 	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/services"
 	"math/rand"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func (cli *CLI) viewPortfolios() {
-	portfolios, err := cli.portfolioService.GetAllPortfolios()
+func (cli *CLI) viewPortfolios(ctx context.Context) {
+	portfolios, err := cli.portfolioService.GetAllPortfolios(ctx)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error retrieving portfolios: %v\n", err)
 		return
@@ -21,32 +25,15 @@ func (cli *CLI) viewPortfolios() {
 		return
 	}
 
+	views := make(output.PortfolioViews, 0, len(portfolios))
 	for _, p := range portfolios {
-		fmt.Fprintf(cli.writer, "ID: %d, Name: %s\n", p.ID, p.Name)
-		fmt.Fprintln(cli.writer, "Stocks:")
-		for _, stock := range p.Stocks {
-			fmt.Fprintf(cli.writer, "- %s: %d shares bought on %s\n", stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"))
-
-			// Obtener el precio de cierre de la acción en la fecha de compra
-			price, err := cli.portfolioService.GetPriceClose(stock.Symbol, stock.BuyDate)
-			if err != nil {
-				fmt.Fprintf(cli.writer, "  Price on %s: Could not retrieve price (%v)\n", stock.BuyDate.Format("2006-01-02"), err)
-			} else {
-				fmt.Fprintf(cli.writer, "  Price on %s: $%.2f\n", stock.BuyDate.Format("2006-01-02"), price)
-			}
-		}
-
-		// Calcular APR usando la fecha de compra más temprana y hoy
-		startDate := earliestBuyDate(p)
-		endDate := time.Now()
-		apr, err := cli.portfolioService.CalculateAPR(&p, startDate, endDate)
-		if err != nil {
-			fmt.Fprintf(cli.writer, "APR: Could not calculate APR (%v)\n", err)
-		} else {
-			fmt.Fprintf(cli.writer, "APR: %.2f%%\n", apr*100)
-		}
-		fmt.Println()
+		views = append(views, cli.portfolioView(ctx, p))
+	}
+	if err := output.Render(cli.writer, cli.format, views); err != nil {
+		fmt.Fprintf(cli.writer, "Error rendering portfolios: %v\n", err)
+		return
 	}
+	fmt.Fprintln(cli.writer)
 
 	fmt.Fprint(cli.writer, "Enter the ID of the portfolio to edit/delete (or press Enter to return): ")
 	input, err := cli.reader.ReadString('\n')
@@ -66,13 +53,51 @@ func (cli *CLI) viewPortfolios() {
 		return
 	}
 
-	portfolio, err := cli.portfolioService.GetPortfolioByID(id)
+	portfolio, err := cli.portfolioService.GetPortfolioByID(ctx, id)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error retrieving portfolio: %v\n", err)
 		return
 	}
 
-	cli.managePortfolio(portfolio)
+	cli.managePortfolio(ctx, portfolio)
+}
+
+// portfolioView builds the view of a portfolio, including the close price on
+// each purchase date and the APR and XIRR from the earliest purchase until today.
+func (cli *CLI) portfolioView(ctx context.Context, p models.Portfolio) output.PortfolioView {
+	view := output.NewPortfolioView(p)
+	requests := make([]services.PriceRequest, 0, len(p.Stocks))
+	for _, stock := range p.Stocks {
+		requests = append(requests, services.NewPriceRequest(stock.Symbol, stock.BuyDate))
+	}
+	prices, err := cli.portfolioService.GetPriceCloses(ctx, requests)
+	var priceErrors services.PriceErrors
+	errors.As(err, &priceErrors)
+	for i, request := range requests {
+		if price, ok := prices[request]; ok {
+			view.Positions[i].ClosePrice = &price
+		} else if symbolErr, ok := priceErrors[request.Symbol]; ok {
+			view.Positions[i].PriceError = symbolErr.Error()
+		} else if err != nil {
+			view.Positions[i].PriceError = err.Error()
+		}
+	}
+
+	startDate := earliestBuyDate(p)
+	endDate := time.Now()
+	apr, err := cli.portfolioService.CalculateAPR(ctx, &p, startDate, endDate)
+	if err != nil {
+		view.APRError = err.Error()
+	} else {
+		view.APR = &apr
+	}
+	xirr, err := cli.portfolioService.CalculateXIRR(ctx, &p, startDate, endDate)
+	if err != nil {
+		view.XIRRError = err.Error()
+	} else {
+		view.XIRR = &xirr
+	}
+	return view
 }
 
 func earliestBuyDate(portfolio models.Portfolio) time.Time {
@@ -89,7 +114,7 @@ func earliestBuyDate(portfolio models.Portfolio) time.Time {
 	return earliest
 }
 
-func (cli *CLI) managePortfolio(portfolio *models.Portfolio) {
+func (cli *CLI) managePortfolio(ctx context.Context, portfolio *models.Portfolio) {
 	fmt.Fprintf(cli.writer, "Portfolio: %s\n", portfolio.Name)
 	fmt.Fprintln(cli.writer, "Select an option:")
 	fmt.Fprintln(cli.writer, "1. Edit portfolio")
@@ -106,9 +131,9 @@ func (cli *CLI) managePortfolio(portfolio *models.Portfolio) {
 
 	switch input {
 	case "1":
-		cli.editPortfolio(portfolio)
+		cli.editPortfolio(ctx, portfolio)
 	case "2":
-		err := cli.portfolioService.DeletePortfolio(portfolio.ID)
+		err := cli.portfolioService.DeletePortfolio(ctx, portfolio.ID)
 		if err != nil {
 			fmt.Fprintf(cli.writer, "Error deleting portfolio: %v\n", err)
 		} else {
@@ -121,12 +146,7 @@ func (cli *CLI) managePortfolio(portfolio *models.Portfolio) {
 	}
 }
 
-func (cli *CLI) editPortfolio(portfolio *models.Portfolio) {
-	// TODO: Implementar la lógica de edición de cartera
-	fmt.Fprintln(cli.writer, "Edit portfolio functionality is not implemented yet.")
-}
-
-func (cli *CLI) createPortfolioManual() {
+func (cli *CLI) createPortfolioManual(ctx context.Context) {
 	fmt.Fprint(cli.writer, "Enter the name of the portfolio: ")
 	name, err := cli.reader.ReadString('\n')
 	if err != nil {
@@ -135,7 +155,7 @@ func (cli *CLI) createPortfolioManual() {
 	}
 	name = strings.TrimSpace(name)
 
-	symbols, err := cli.portfolioService.GetSP500Symbols()
+	symbols, err := cli.portfolioService.GetSP500Symbols(ctx)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error retrieving S&P 500 symbols: %v\n", err)
 		return
@@ -195,17 +215,20 @@ func (cli *CLI) createPortfolioManual() {
 			continue
 		}
 
-		buyPrice, err := cli.portfolioService.GetPriceClose(symbol, buyDate)
+		price, err := cli.portfolioService.GetResolvedPrice(ctx, symbol, buyDate)
 		if err != nil {
 			fmt.Fprintf(cli.writer, "Error getting price for %s on %s: %v... continuing with the next...\n", symbol, buyDate.Format("2006-01-02"), err)
 			continue
 		}
+		if price.Adjusted() {
+			fmt.Fprintf(cli.writer, "%s did not trade on %s, using the close of %s.\n", symbol, buyDate.Format("2006-01-02"), price.Date.Format("2006-01-02"))
+		}
 
 		stock := models.Stock{
 			Symbol:   symbol,
 			Quantity: quantity,
 			BuyDate:  buyDate,
-			BuyPrice: buyPrice,
+			BuyPrice: price.Close,
 		}
 
 		stocks = append(stocks, stock)
@@ -221,7 +244,7 @@ func (cli *CLI) createPortfolioManual() {
 		Stocks: stocks,
 	}
 
-	err = cli.portfolioService.CreatePortfolioManual(portfolio)
+	err = cli.portfolioService.CreatePortfolioManual(ctx, portfolio)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error saving portfolio: %v\n", err)
 		return
@@ -230,8 +253,12 @@ func (cli *CLI) createPortfolioManual() {
 	fmt.Fprintln(cli.writer, "Portfolio created successfully.")
 }
 
-func (cli *CLI) createPortfolioRandom() {
-	symbols, err := cli.portfolioService.GetSP500Symbols()
+// maxRandomNameAttempts is how many names a random portfolio tries before
+// giving up on a conflict.
+const maxRandomNameAttempts = 10
+
+func (cli *CLI) createPortfolioRandom(ctx context.Context) {
+	symbols, err := cli.portfolioService.GetSP500Symbols(ctx)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error retrieving S&P 500 symbols: %v\n", err)
 		return
@@ -251,28 +278,36 @@ func (cli *CLI) createPortfolioRandom() {
 		timestamp := rand.Int63n(end-start) + start
 		buyDate := time.Unix(timestamp, 0)
 
-		buyPrice, err := cli.portfolioService.GetPriceClose(symbol, buyDate)
+		price, err := cli.portfolioService.GetResolvedPrice(ctx, symbol, buyDate)
 		if err != nil {
 			fmt.Fprintf(cli.writer, "Error getting price for %s on %s: %v... continuing with the next...\n", symbol, buyDate.Format("2006-01-02"), err)
 			continue
 		}
 
+		// Random dates often fall on weekends, buy on the trading day that was priced
 		stock := models.Stock{
 			Symbol:   symbol,
 			Quantity: quantity,
-			BuyDate:  buyDate,
-			BuyPrice: buyPrice,
+			BuyDate:  price.Date,
+			BuyPrice: price.Close,
 		}
 
 		stocks = append(stocks, stock)
 	}
 
+	// Portfolio names are unique, so the name carries the time of creation and
+	// a number when another portfolio was created in the same second
+	name := "Random Portfolio " + time.Now().Format("2006-01-02 15:04:05")
 	portfolio := &models.Portfolio{
-		Name:   fmt.Sprintf("Random Portfolio %d", rand.Intn(1000)),
+		Name:   name,
 		Stocks: stocks,
 	}
 
-	err = cli.portfolioService.CreatePortfolioManual(portfolio)
+	err = cli.portfolioService.CreatePortfolioManual(ctx, portfolio)
+	for attempt := 2; errors.Is(err, models.ErrConflict) && attempt <= maxRandomNameAttempts; attempt++ {
+		portfolio.Name = fmt.Sprintf("%s (%d)", name, attempt)
+		err = cli.portfolioService.CreatePortfolioManual(ctx, portfolio)
+	}
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error saving portfolio: %v\n", err)
 		return
@@ -281,7 +316,7 @@ func (cli *CLI) createPortfolioRandom() {
 	// Calcular APR
 	startDate := earliestBuyDate(*portfolio)
 	endDate := time.Now()
-	apr, err := cli.portfolioService.CalculateAPR(portfolio, startDate, endDate)
+	apr, err := cli.portfolioService.CalculateAPR(ctx, portfolio, startDate, endDate)
 	if err != nil {
 		fmt.Fprintf(cli.writer, "Error calculating APR: %v\n", err)
 		return
@@ -3,14 +3,18 @@ package cli
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"fmt"
 	"github.com/fcopulgar/stock-manager-go/models"
 	"github.com/stretchr/testify/mock"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestViewPortfolios_NoPortfolios checks the behavior of viewPortfolios() when there are no portfolios.
 func TestViewPortfolios_NoPortfolios(t *testing.T) {
+	ctx := context.Background()
 	mockService := new(MockPortfolioService)
 
 	// Configure the mock so that GetAllPortfolios returns an empty list
@@ -31,7 +35,7 @@ func TestViewPortfolios_NoPortfolios(t *testing.T) {
 	}
 
 	// Directly call viewPortfolios()
-	cli.viewPortfolios()
+	cli.viewPortfolios(ctx)
 
 	output := outputBuffer.String()
 
@@ -45,6 +49,7 @@ func TestViewPortfolios_NoPortfolios(t *testing.T) {
 
 // TestCreatePortfolioManual_NoInput tests that if the user does not enter actions, the corresponding message is displayed.
 func TestCreatePortfolioManual_NoInput(t *testing.T) {
+	ctx := context.Background()
 	mockService := new(MockPortfolioService)
 
 	// Configure the mock for GetSP500Symbols
@@ -61,7 +66,7 @@ func TestCreatePortfolioManual_NoInput(t *testing.T) {
 		writer:           &outputBuffer,
 	}
 
-	cli.createPortfolioManual()
+	cli.createPortfolioManual(ctx)
 
 	output := outputBuffer.String()
 
@@ -77,12 +82,16 @@ func TestCreatePortfolioManual_NoInput(t *testing.T) {
 
 // TestCreatePortfolioManual_SingleStock tests that if the user adds a stock and a valid date, the portfolio is created.
 func TestCreatePortfolioManual_SingleStock(t *testing.T) {
+	ctx := context.Background()
 	mockService := new(MockPortfolioService)
 
 	// Configure the mocks
 	mockService.On("GetSP500Symbols").Return([]string{"AAPL"}, nil)
-	// When GetPriceClose is called with AAPL and a date, we will return a fixed price.
-	mockService.On("GetPriceClose", "AAPL", mock.AnythingOfType("time.Time")).Return(300.0, nil)
+	// When GetResolvedPrice is called with AAPL and a date, we will return a fixed price.
+	mockService.On("GetResolvedPrice", "AAPL", mock.AnythingOfType("time.Time")).Return(&models.ResolvedPrice{
+		RequestedDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC),
+		PriceBar:      models.PriceBar{Date: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), Close: 300.0},
+	}, nil)
 	mockService.On("CreatePortfolioManual", mock.AnythingOfType("*models.Portfolio")).Return(nil)
 
 	// Simulate entry:
@@ -101,7 +110,7 @@ func TestCreatePortfolioManual_SingleStock(t *testing.T) {
 		writer:           &outputBuffer,
 	}
 
-	cli.createPortfolioManual()
+	cli.createPortfolioManual(ctx)
 
 	output := outputBuffer.String()
 
@@ -127,3 +136,23 @@ func TestCreatePortfolioManual_SingleStock(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+// TestViewPortfolios_NotFound checks that an ID without a portfolio is reported instead of managed.
+func TestViewPortfolios_NotFound(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockPortfolioService)
+	mockService.On("GetAllPortfolios").Return([]models.Portfolio{{ID: 1, Name: "Growth"}}, nil)
+	mockService.On("CalculateAPR", mock.Anything, mock.Anything, mock.Anything).Return(0.0, nil)
+	mockService.On("CalculateXIRR", mock.Anything, mock.Anything, mock.Anything).Return(0.0, nil)
+	mockService.On("GetPortfolioByID", 7).Return((*models.Portfolio)(nil), fmt.Errorf("portfolio 7 %w", models.ErrNotFound))
+
+	var outputBuffer bytes.Buffer
+	cli := NewCLI(mockService, strings.NewReader("7\n"), &outputBuffer)
+
+	cli.viewPortfolios(ctx)
+
+	if !strings.Contains(outputBuffer.String(), "Error retrieving portfolio: portfolio 7 not found") {
+		t.Errorf("Expected the missing portfolio to be reported, got '%s'", outputBuffer.String())
+	}
+	mockService.AssertExpectations(t)
+}
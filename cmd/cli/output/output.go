@@ -0,0 +1,92 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the representation used to render a view.
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	CSV   Format = "csv"
+	YAML  Format = "yaml"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{Table, JSON, CSV, YAML}
+
+// ParseFormat converts a user supplied value into a Format.
+func ParseFormat(value string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(value, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q (expected table, json, csv or yaml)", value)
+}
+
+// String implements flag.Value.
+func (f *Format) String() string {
+	return string(*f)
+}
+
+// Set implements flag.Value.
+func (f *Format) Set(value string) error {
+	format, err := ParseFormat(value)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// Tabular is implemented by views that can be rendered as rows and columns.
+type Tabular interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Render writes the view to w using the given format. JSON and YAML use the
+// struct tags of the view, while table and CSV use its Tabular representation.
+func Render(w io.Writer, format Format, view Tabular) error {
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(view)
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(view); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case CSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(view.Header()); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(view.Rows()); err != nil {
+			return err
+		}
+		return writer.Error()
+	case Table, "":
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.Join(view.Header(), "\t"))
+		for _, row := range view.Rows() {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+func testPortfolioViews() PortfolioViews {
+	apr := 0.1
+	closePrice := 305.0
+	view := NewPortfolioView(models.Portfolio{
+		ID:   1,
+		Name: "Tech",
+		Stocks: []models.Stock{
+			{Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 300.0},
+			{Symbol: "MSFT", Quantity: 5, BuyDate: time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC), BuyPrice: 170.0},
+		},
+	})
+	view.Positions[0].ClosePrice = &closePrice
+	view.Positions[1].PriceError = "no price data"
+	view.APR = &apr
+	view.XIRRError = "the money-weighted return did not converge"
+	return PortfolioViews{view, NewPortfolioView(models.Portfolio{ID: 2, Name: "Empty"})}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("JSON")
+	require.NoError(t, err)
+	require.Equal(t, JSON, format)
+
+	_, err = ParseFormat("xml")
+	require.Error(t, err)
+}
+
+func TestRender_Table(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, Table, testPortfolioViews()))
+
+	expected := "ID  NAME   SYMBOL  QUANTITY  BUY DATE    BUY PRICE  CLOSE PRICE  APR (%)  XIRR (%)\n" +
+		"1   Tech   AAPL    10        2020-01-15  300.00     305.00       10.00    n/a\n" +
+		"1   Tech   MSFT    5         2020-02-03  170.00     n/a          10.00    n/a\n" +
+		"2   Empty                                                                 \n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestRender_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, CSV, NewPortfolioSummaries([]models.Portfolio{
+		{ID: 1, Name: "Tech, Growth", Stocks: []models.Stock{{Symbol: "AAPL"}}},
+	})))
+
+	require.Equal(t, "ID,NAME,POSITIONS\n1,\"Tech, Growth\",1\n", buf.String())
+}
+
+func TestRender_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, JSON, NewAPRView(1,
+		time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC), 0.1)))
+
+	require.JSONEq(t, `{"portfolio_id":1,"from":"2020-01-15","to":"2021-01-15","apr":0.1}`, buf.String())
+}
+
+func TestRender_JSONEmptyPositions(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, JSON, NewPortfolioView(models.Portfolio{ID: 2, Name: "Empty"})))
+
+	require.JSONEq(t, `{"id":2,"name":"Empty","positions":[]}`, buf.String())
+}
+
+func TestRender_YAML(t *testing.T) {
+	var buf bytes.Buffer
+	price := &models.ResolvedPrice{
+		RequestedDate: time.Date(2020, 1, 18, 0, 0, 0, 0, time.UTC),
+		PriceBar:      models.PriceBar{Date: time.Date(2020, 1, 17, 0, 0, 0, 0, time.UTC), Close: 318.73, Source: "fmp"},
+	}
+	require.NoError(t, Render(&buf, YAML, NewPriceView("AAPL", price)))
+
+	require.Equal(t, "symbol: AAPL\ndate: \"2020-01-18\"\ntrading_date: \"2020-01-17\"\nclose: 318.73\nsource: fmp\n", buf.String())
+}
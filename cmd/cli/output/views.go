@@ -0,0 +1,855 @@
+package output
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// notAvailable is shown in tables and CSV in place of values that could not be computed.
+const notAvailable = "n/a"
+
+// PortfolioSummary is a one line description of a portfolio.
+type PortfolioSummary struct {
+	ID        int    `json:"id" yaml:"id"`
+	Name      string `json:"name" yaml:"name"`
+	Positions int    `json:"positions" yaml:"positions"`
+}
+
+// PortfolioSummaries is the view of the portfolio list.
+type PortfolioSummaries []PortfolioSummary
+
+func NewPortfolioSummaries(portfolios []models.Portfolio) PortfolioSummaries {
+	summaries := make(PortfolioSummaries, 0, len(portfolios))
+	for _, p := range portfolios {
+		summaries = append(summaries, PortfolioSummary{ID: p.ID, Name: p.Name, Positions: len(p.Stocks)})
+	}
+	return summaries
+}
+
+func (s PortfolioSummaries) Header() []string {
+	return []string{"ID", "NAME", "POSITIONS"}
+}
+
+func (s PortfolioSummaries) Rows() [][]string {
+	rows := make([][]string, 0, len(s))
+	for _, summary := range s {
+		rows = append(rows, []string{strconv.Itoa(summary.ID), summary.Name, strconv.Itoa(summary.Positions)})
+	}
+	return rows
+}
+
+// PositionView describes a single position of a portfolio. ClosePrice is the
+// close price on the purchase date, when it was requested.
+type PositionView struct {
+	Symbol     string   `json:"symbol" yaml:"symbol"`
+	Quantity   int      `json:"quantity" yaml:"quantity"`
+	BuyDate    string   `json:"buy_date" yaml:"buy_date"`
+	BuyPrice   float64  `json:"buy_price" yaml:"buy_price"`
+	ClosePrice *float64 `json:"close_price,omitempty" yaml:"close_price,omitempty"`
+	PriceError string   `json:"price_error,omitempty" yaml:"price_error,omitempty"`
+}
+
+func NewPositionView(stock models.Stock) PositionView {
+	return PositionView{
+		Symbol:   stock.Symbol,
+		Quantity: stock.Quantity,
+		BuyDate:  stock.BuyDate.Format(dateLayout),
+		BuyPrice: stock.BuyPrice,
+	}
+}
+
+// PortfolioView describes a portfolio with its positions and, optionally, its
+// APR and money-weighted return (XIRR).
+type PortfolioView struct {
+	ID        int            `json:"id" yaml:"id"`
+	Name      string         `json:"name" yaml:"name"`
+	Positions []PositionView `json:"positions" yaml:"positions"`
+	APR       *float64       `json:"apr,omitempty" yaml:"apr,omitempty"`
+	APRError  string         `json:"apr_error,omitempty" yaml:"apr_error,omitempty"`
+	XIRR      *float64       `json:"xirr,omitempty" yaml:"xirr,omitempty"`
+	XIRRError string         `json:"xirr_error,omitempty" yaml:"xirr_error,omitempty"`
+}
+
+func NewPortfolioView(portfolio models.Portfolio) PortfolioView {
+	view := PortfolioView{
+		ID:        portfolio.ID,
+		Name:      portfolio.Name,
+		Positions: make([]PositionView, 0, len(portfolio.Stocks)),
+	}
+	for _, stock := range portfolio.Stocks {
+		view.Positions = append(view.Positions, NewPositionView(stock))
+	}
+	return view
+}
+
+func (v PortfolioView) Header() []string {
+	return PortfolioViews{v}.Header()
+}
+
+func (v PortfolioView) Rows() [][]string {
+	return PortfolioViews{v}.Rows()
+}
+
+// PortfolioViews renders one row per position, repeating the portfolio columns.
+type PortfolioViews []PortfolioView
+
+func (v PortfolioViews) Header() []string {
+	return []string{"ID", "NAME", "SYMBOL", "QUANTITY", "BUY DATE", "BUY PRICE", "CLOSE PRICE", "APR (%)", "XIRR (%)"}
+}
+
+func (v PortfolioViews) Rows() [][]string {
+	var rows [][]string
+	for _, portfolio := range v {
+		id := strconv.Itoa(portfolio.ID)
+		apr := formatPercent(portfolio.APR)
+		if portfolio.APRError != "" {
+			apr = notAvailable
+		}
+		xirr := formatPercent(portfolio.XIRR)
+		if portfolio.XIRRError != "" {
+			xirr = notAvailable
+		}
+		if len(portfolio.Positions) == 0 {
+			rows = append(rows, []string{id, portfolio.Name, "", "", "", "", "", apr, xirr})
+			continue
+		}
+		for _, position := range portfolio.Positions {
+			closePrice := formatOptionalPrice(position.ClosePrice)
+			if position.PriceError != "" {
+				closePrice = notAvailable
+			}
+			rows = append(rows, []string{
+				id,
+				portfolio.Name,
+				position.Symbol,
+				strconv.Itoa(position.Quantity),
+				position.BuyDate,
+				formatPrice(position.BuyPrice),
+				closePrice,
+				apr,
+				xirr,
+			})
+		}
+	}
+	return rows
+}
+
+// PriceView is the close price of a symbol on a date. TradingDate is the day
+// the price comes from, which differs from Date when that date had no trading,
+// and Source where it was obtained.
+type PriceView struct {
+	Symbol      string  `json:"symbol" yaml:"symbol"`
+	Date        string  `json:"date" yaml:"date"`
+	TradingDate string  `json:"trading_date" yaml:"trading_date"`
+	Close       float64 `json:"close" yaml:"close"`
+	Source      string  `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+func NewPriceView(symbol string, price *models.ResolvedPrice) PriceView {
+	return PriceView{
+		Symbol:      symbol,
+		Date:        price.RequestedDate.Format(dateLayout),
+		TradingDate: price.Date.Format(dateLayout),
+		Close:       price.Close,
+		Source:      price.Source,
+	}
+}
+
+func (v PriceView) Header() []string {
+	return []string{"SYMBOL", "DATE", "TRADING DATE", "CLOSE", "SOURCE"}
+}
+
+func (v PriceView) Rows() [][]string {
+	return [][]string{{v.Symbol, v.Date, v.TradingDate, formatPrice(v.Close), v.Source}}
+}
+
+// PriceBarView is the daily open, high, low and close prices and volume of a symbol.
+type PriceBarView struct {
+	Symbol string  `json:"symbol" yaml:"symbol"`
+	Date   string  `json:"date" yaml:"date"`
+	Open   float64 `json:"open" yaml:"open"`
+	High   float64 `json:"high" yaml:"high"`
+	Low    float64 `json:"low" yaml:"low"`
+	Close  float64 `json:"close" yaml:"close"`
+	Volume int64   `json:"volume" yaml:"volume"`
+	Source string  `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+type PriceBarViews []PriceBarView
+
+func NewPriceBarViews(symbol string, bars []models.PriceBar) PriceBarViews {
+	views := make(PriceBarViews, 0, len(bars))
+	for _, bar := range bars {
+		views = append(views, PriceBarView{
+			Symbol: symbol,
+			Date:   bar.Date.Format(dateLayout),
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+			Source: bar.Source,
+		})
+	}
+	return views
+}
+
+func (v PriceBarViews) Header() []string {
+	return []string{"SYMBOL", "DATE", "OPEN", "HIGH", "LOW", "CLOSE", "VOLUME", "SOURCE"}
+}
+
+func (v PriceBarViews) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, bar := range v {
+		rows = append(rows, []string{
+			bar.Symbol,
+			bar.Date,
+			formatPrice(bar.Open),
+			formatPrice(bar.High),
+			formatPrice(bar.Low),
+			formatPrice(bar.Close),
+			strconv.FormatInt(bar.Volume, 10),
+			bar.Source,
+		})
+	}
+	return rows
+}
+
+// ManualPriceView is a price entered by hand.
+type ManualPriceView struct {
+	Symbol string  `json:"symbol" yaml:"symbol"`
+	Date   string  `json:"date" yaml:"date"`
+	Price  float64 `json:"price" yaml:"price"`
+}
+
+type ManualPriceViews []ManualPriceView
+
+func NewManualPriceViews(prices []models.ManualPrice) ManualPriceViews {
+	views := make(ManualPriceViews, 0, len(prices))
+	for _, price := range prices {
+		views = append(views, ManualPriceView{
+			Symbol: price.Symbol,
+			Date:   price.Date.Format(dateLayout),
+			Price:  price.Price,
+		})
+	}
+	return views
+}
+
+func (v ManualPriceViews) Header() []string {
+	return []string{"SYMBOL", "DATE", "PRICE"}
+}
+
+func (v ManualPriceViews) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, price := range v {
+		rows = append(rows, []string{price.Symbol, price.Date, formatPrice(price.Price)})
+	}
+	return rows
+}
+
+// MigrationView is a migration of the database schema and whether it is
+// applied.
+type MigrationView struct {
+	Version   int    `json:"version" yaml:"version"`
+	Name      string `json:"name" yaml:"name"`
+	Status    string `json:"status" yaml:"status"`
+	AppliedAt string `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+}
+
+type MigrationViews []MigrationView
+
+func NewMigrationViews(migrations []models.SchemaMigration) MigrationViews {
+	views := make(MigrationViews, 0, len(migrations))
+	for _, m := range migrations {
+		view := MigrationView{Version: m.Version, Name: m.Name, Status: "pending"}
+		if m.AppliedAt != nil {
+			view.Status = "applied"
+			view.AppliedAt = m.AppliedAt.Format(time.RFC3339)
+		}
+		if m.Unknown {
+			view.Status = "unknown"
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+func (v MigrationViews) Header() []string {
+	return []string{"VERSION", "NAME", "STATUS", "APPLIED AT"}
+}
+
+func (v MigrationViews) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, m := range v {
+		rows = append(rows, []string{strconv.Itoa(m.Version), m.Name, m.Status, m.AppliedAt})
+	}
+	return rows
+}
+
+// APRView is the APR of a portfolio between two dates and, when it could be
+// solved, its money-weighted return (XIRR), both as fractions.
+type APRView struct {
+	PortfolioID int      `json:"portfolio_id" yaml:"portfolio_id"`
+	From        string   `json:"from" yaml:"from"`
+	To          string   `json:"to" yaml:"to"`
+	APR         float64  `json:"apr" yaml:"apr"`
+	XIRR        *float64 `json:"xirr,omitempty" yaml:"xirr,omitempty"`
+	XIRRError   string   `json:"xirr_error,omitempty" yaml:"xirr_error,omitempty"`
+}
+
+func NewAPRView(portfolioID int, from, to time.Time, apr float64) APRView {
+	return APRView{PortfolioID: portfolioID, From: from.Format(dateLayout), To: to.Format(dateLayout), APR: apr}
+}
+
+func (v APRView) Header() []string {
+	return []string{"PORTFOLIO", "FROM", "TO", "APR (%)", "XIRR (%)"}
+}
+
+func (v APRView) Rows() [][]string {
+	xirr := formatPercent(v.XIRR)
+	if v.XIRRError != "" {
+		xirr = notAvailable
+	}
+	return [][]string{{strconv.Itoa(v.PortfolioID), v.From, v.To, formatPercent(&v.APR), xirr}}
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+func formatRatio(ratio float64) string {
+	return strconv.FormatFloat(ratio, 'f', 2, 64)
+}
+
+func formatOptionalPrice(price *float64) string {
+	if price == nil {
+		return ""
+	}
+	return formatPrice(*price)
+}
+
+func formatPercent(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value*100, 'f', 2, 64)
+}
+
+// TransactionView is an entry of a portfolio ledger.
+type TransactionView struct {
+	ID         int     `json:"id,omitempty" yaml:"id,omitempty"`
+	Date       string  `json:"date" yaml:"date"`
+	Type       string  `json:"type" yaml:"type"`
+	Symbol     string  `json:"symbol" yaml:"symbol"`
+	Quantity   float64 `json:"quantity" yaml:"quantity"`
+	Price      float64 `json:"price" yaml:"price"`
+	Commission float64 `json:"commission" yaml:"commission"`
+}
+
+// TransactionViews is the view of a portfolio ledger.
+type TransactionViews []TransactionView
+
+func NewTransactionViews(transactions []models.Transaction) TransactionViews {
+	views := make(TransactionViews, 0, len(transactions))
+	for _, t := range transactions {
+		views = append(views, TransactionView{
+			ID:         t.ID,
+			Date:       t.Date.Format(dateLayout),
+			Type:       string(t.Type),
+			Symbol:     t.Symbol,
+			Quantity:   t.Quantity,
+			Price:      t.Price,
+			Commission: t.Commission,
+		})
+	}
+	return views
+}
+
+func (v TransactionViews) Header() []string {
+	return []string{"ID", "DATE", "TYPE", "SYMBOL", "QUANTITY", "PRICE", "COMMISSION"}
+}
+
+func (v TransactionViews) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, t := range v {
+		id := ""
+		if t.ID != 0 {
+			id = strconv.Itoa(t.ID)
+		}
+		rows = append(rows, []string{id, t.Date, t.Type, t.Symbol, formatQuantity(t.Quantity), formatPrice(t.Price), formatPrice(t.Commission)})
+	}
+	return rows
+}
+
+// GainPositionView is the result of a single position.
+type GainPositionView struct {
+	Symbol         string  `json:"symbol" yaml:"symbol"`
+	Quantity       float64 `json:"quantity" yaml:"quantity"`
+	CostBasis      float64 `json:"cost_basis" yaml:"cost_basis"`
+	MarketValue    float64 `json:"market_value" yaml:"market_value"`
+	RealizedGain   float64 `json:"realized_gain" yaml:"realized_gain"`
+	UnrealizedGain float64 `json:"unrealized_gain" yaml:"unrealized_gain"`
+	Dividends      float64 `json:"dividends" yaml:"dividends"`
+	Fees           float64 `json:"fees" yaml:"fees"`
+}
+
+// GainsView is the realized and unrealized result of a portfolio on a date,
+// together with its value: cash plus the market value of the holdings.
+type GainsView struct {
+	PortfolioID   int                `json:"portfolio_id" yaml:"portfolio_id"`
+	Date          string             `json:"date" yaml:"date"`
+	Positions     []GainPositionView `json:"positions" yaml:"positions"`
+	Realized      float64            `json:"realized" yaml:"realized"`
+	Unrealized    float64            `json:"unrealized" yaml:"unrealized"`
+	Dividends     float64            `json:"dividends" yaml:"dividends"`
+	Interest      float64            `json:"interest" yaml:"interest"`
+	Fees          float64            `json:"fees" yaml:"fees"`
+	Total         float64            `json:"total" yaml:"total"`
+	Cash          float64            `json:"cash" yaml:"cash"`
+	HoldingsValue float64            `json:"holdings_value" yaml:"holdings_value"`
+	TotalValue    float64            `json:"total_value" yaml:"total_value"`
+}
+
+func NewGainsView(portfolioID int, date time.Time, gains *models.Gains) GainsView {
+	view := GainsView{
+		PortfolioID:   portfolioID,
+		Date:          date.Format(dateLayout),
+		Positions:     make([]GainPositionView, 0, len(gains.Positions)),
+		Realized:      gains.Realized,
+		Unrealized:    gains.Unrealized,
+		Dividends:     gains.Dividends,
+		Interest:      gains.Interest,
+		Fees:          gains.Fees,
+		Total:         gains.Total(),
+		Cash:          gains.Cash,
+		HoldingsValue: gains.HoldingsValue,
+		TotalValue:    gains.TotalValue(),
+	}
+	for _, p := range gains.Positions {
+		view.Positions = append(view.Positions, GainPositionView{
+			Symbol:         p.Symbol,
+			Quantity:       p.Quantity,
+			CostBasis:      p.CostBasis,
+			MarketValue:    p.MarketValue,
+			RealizedGain:   p.RealizedGain,
+			UnrealizedGain: p.UnrealizedGain,
+			Dividends:      p.Dividends,
+			Fees:           p.Fees,
+		})
+	}
+	return view
+}
+
+func (v GainsView) Header() []string {
+	return []string{"SYMBOL", "QUANTITY", "COST BASIS", "MARKET VALUE", "REALIZED", "UNREALIZED", "INCOME", "FEES"}
+}
+
+// Rows lists every position followed by a CASH and a TOTAL row. Income is the
+// dividends of a position and the interest of the cash account.
+func (v GainsView) Rows() [][]string {
+	rows := make([][]string, 0, len(v.Positions)+2)
+	for _, p := range v.Positions {
+		rows = append(rows, []string{
+			p.Symbol,
+			formatQuantity(p.Quantity),
+			formatPrice(p.CostBasis),
+			formatPrice(p.MarketValue),
+			formatPrice(p.RealizedGain),
+			formatPrice(p.UnrealizedGain),
+			formatPrice(p.Dividends),
+			formatPrice(p.Fees),
+		})
+	}
+	rows = append(rows,
+		[]string{"CASH", "", "", formatPrice(v.Cash), "", "", formatPrice(v.Interest), ""},
+		[]string{"TOTAL", "", "", formatPrice(v.TotalValue), formatPrice(v.Realized), formatPrice(v.Unrealized),
+			formatPrice(v.Dividends + v.Interest), formatPrice(v.Fees)},
+	)
+	return rows
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
+
+// LotView is an open lot of a portfolio.
+type LotView struct {
+	ID           string  `json:"id" yaml:"id"`
+	Symbol       string  `json:"symbol" yaml:"symbol"`
+	BuyDate      string  `json:"buy_date" yaml:"buy_date"`
+	Quantity     float64 `json:"quantity" yaml:"quantity"`
+	CostPerShare float64 `json:"cost_per_share" yaml:"cost_per_share"`
+}
+
+// LotViews is the view of the open lots of a portfolio.
+type LotViews []LotView
+
+func NewLotViews(lots []models.Lot) LotViews {
+	views := make(LotViews, 0, len(lots))
+	for _, lot := range lots {
+		views = append(views, LotView{
+			ID:           lot.ID,
+			Symbol:       lot.Symbol,
+			BuyDate:      lot.BuyDate.Format(dateLayout),
+			Quantity:     lot.Quantity,
+			CostPerShare: lot.CostPerShare,
+		})
+	}
+	return views
+}
+
+func (v LotViews) Header() []string {
+	return []string{"LOT", "SYMBOL", "BUY DATE", "QUANTITY", "COST PER SHARE"}
+}
+
+func (v LotViews) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, lot := range v {
+		rows = append(rows, []string{lot.ID, lot.Symbol, lot.BuyDate, formatQuantity(lot.Quantity), formatPrice(lot.CostPerShare)})
+	}
+	return rows
+}
+
+// RealizedSaleView is the part of a sell that consumed a single lot.
+type RealizedSaleView struct {
+	Symbol    string  `json:"symbol" yaml:"symbol"`
+	LotID     string  `json:"lot_id" yaml:"lot_id"`
+	BuyDate   string  `json:"buy_date" yaml:"buy_date"`
+	SellDate  string  `json:"sell_date" yaml:"sell_date"`
+	Quantity  float64 `json:"quantity" yaml:"quantity"`
+	Proceeds  float64 `json:"proceeds" yaml:"proceeds"`
+	CostBasis float64 `json:"cost_basis" yaml:"cost_basis"`
+	Gain      float64 `json:"gain" yaml:"gain"`
+	Term      string  `json:"term" yaml:"term"`
+}
+
+// RealizedGainsView is the realized gains report of a period.
+type RealizedGainsView struct {
+	PortfolioID int                `json:"portfolio_id" yaml:"portfolio_id"`
+	Method      string             `json:"method" yaml:"method"`
+	From        string             `json:"from" yaml:"from"`
+	To          string             `json:"to" yaml:"to"`
+	Sales       []RealizedSaleView `json:"sales" yaml:"sales"`
+	ShortTerm   float64            `json:"short_term" yaml:"short_term"`
+	LongTerm    float64            `json:"long_term" yaml:"long_term"`
+	Total       float64            `json:"total" yaml:"total"`
+}
+
+func NewRealizedGainsView(portfolio *models.Portfolio, report *models.RealizedGainsReport) RealizedGainsView {
+	view := RealizedGainsView{
+		PortfolioID: portfolio.ID,
+		Method:      string(portfolio.Method()),
+		From:        report.From.Format(dateLayout),
+		To:          report.To.Format(dateLayout),
+		Sales:       make([]RealizedSaleView, 0, len(report.Sales)),
+		ShortTerm:   report.ShortTerm,
+		LongTerm:    report.LongTerm,
+		Total:       report.Total(),
+	}
+	for _, sale := range report.Sales {
+		term := "short"
+		if sale.LongTerm {
+			term = "long"
+		}
+		view.Sales = append(view.Sales, RealizedSaleView{
+			Symbol:    sale.Symbol,
+			LotID:     sale.LotID,
+			BuyDate:   sale.BuyDate.Format(dateLayout),
+			SellDate:  sale.SellDate.Format(dateLayout),
+			Quantity:  sale.Quantity,
+			Proceeds:  sale.Proceeds,
+			CostBasis: sale.CostBasis,
+			Gain:      sale.Gain(),
+			Term:      term,
+		})
+	}
+	return view
+}
+
+func (v RealizedGainsView) Header() []string {
+	return []string{"SYMBOL", "LOT", "BUY DATE", "SELL DATE", "QUANTITY", "PROCEEDS", "COST BASIS", "GAIN", "TERM"}
+}
+
+// Rows lists every sale followed by the short-term, long-term and total rows.
+func (v RealizedGainsView) Rows() [][]string {
+	rows := make([][]string, 0, len(v.Sales)+3)
+	for _, sale := range v.Sales {
+		rows = append(rows, []string{
+			sale.Symbol,
+			sale.LotID,
+			sale.BuyDate,
+			sale.SellDate,
+			formatQuantity(sale.Quantity),
+			formatPrice(sale.Proceeds),
+			formatPrice(sale.CostBasis),
+			formatPrice(sale.Gain),
+			sale.Term,
+		})
+	}
+	rows = append(rows,
+		[]string{"SHORT TERM", "", "", "", "", "", "", formatPrice(v.ShortTerm), "short"},
+		[]string{"LONG TERM", "", "", "", "", "", "", formatPrice(v.LongTerm), "long"},
+		[]string{"TOTAL", "", "", "", "", "", "", formatPrice(v.Total), ""},
+	)
+	return rows
+}
+
+// CashEntryView is a movement of the cash account of a portfolio.
+type CashEntryView struct {
+	ID          int     `json:"id" yaml:"id"`
+	Date        string  `json:"date" yaml:"date"`
+	Type        string  `json:"type" yaml:"type"`
+	Amount      float64 `json:"amount" yaml:"amount"`
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// CashView lists the cash entries of a portfolio and its balance.
+type CashView struct {
+	PortfolioID int             `json:"portfolio_id" yaml:"portfolio_id"`
+	Entries     []CashEntryView `json:"entries" yaml:"entries"`
+	Date        string          `json:"date" yaml:"date"`
+	Balance     float64         `json:"balance" yaml:"balance"`
+}
+
+func NewCashView(portfolioID int, entries []models.CashEntry, date time.Time, balance float64) CashView {
+	view := CashView{
+		PortfolioID: portfolioID,
+		Entries:     make([]CashEntryView, 0, len(entries)),
+		Date:        date.Format(dateLayout),
+		Balance:     balance,
+	}
+	for _, entry := range entries {
+		view.Entries = append(view.Entries, CashEntryView{
+			ID:          entry.ID,
+			Date:        entry.Date.Format(dateLayout),
+			Type:        string(entry.Type),
+			Amount:      entry.SignedAmount(),
+			Description: entry.Description,
+		})
+	}
+	return view
+}
+
+func (v CashView) Header() []string {
+	return []string{"ID", "DATE", "TYPE", "AMOUNT", "DESCRIPTION"}
+}
+
+// Rows lists every entry followed by the balance, which also includes the
+// cash moved by transactions.
+func (v CashView) Rows() [][]string {
+	rows := make([][]string, 0, len(v.Entries)+1)
+	for _, entry := range v.Entries {
+		rows = append(rows, []string{strconv.Itoa(entry.ID), entry.Date, entry.Type, formatPrice(entry.Amount), entry.Description})
+	}
+	rows = append(rows, []string{"BALANCE", v.Date, "", formatPrice(v.Balance), ""})
+	return rows
+}
+
+// ValuationPointView is the value of a portfolio at the close of a day. The
+// returns are fractions.
+type ValuationPointView struct {
+	Date             string  `json:"date" yaml:"date"`
+	Cash             float64 `json:"cash" yaml:"cash"`
+	HoldingsValue    float64 `json:"holdings_value" yaml:"holdings_value"`
+	Value            float64 `json:"value" yaml:"value"`
+	NetFlow          float64 `json:"net_flow" yaml:"net_flow"`
+	Return           float64 `json:"return" yaml:"return"`
+	CumulativeReturn float64 `json:"cumulative_return" yaml:"cumulative_return"`
+}
+
+// TWRView is the time-weighted return of a portfolio with its daily valuation series.
+type TWRView struct {
+	PortfolioID int                  `json:"portfolio_id" yaml:"portfolio_id"`
+	From        string               `json:"from" yaml:"from"`
+	To          string               `json:"to" yaml:"to"`
+	Points      []ValuationPointView `json:"points" yaml:"points"`
+	Cumulative  float64              `json:"cumulative" yaml:"cumulative"`
+	Annualized  float64              `json:"annualized" yaml:"annualized"`
+}
+
+func NewTWRView(portfolioID int, report *models.TWRReport) TWRView {
+	view := TWRView{
+		PortfolioID: portfolioID,
+		From:        report.From.Format(dateLayout),
+		To:          report.To.Format(dateLayout),
+		Points:      make([]ValuationPointView, 0, len(report.Points)),
+		Cumulative:  report.Cumulative,
+		Annualized:  report.Annualized,
+	}
+	for _, point := range report.Points {
+		view.Points = append(view.Points, ValuationPointView{
+			Date:             point.Date.Format(dateLayout),
+			Cash:             point.Cash,
+			HoldingsValue:    point.HoldingsValue,
+			Value:            point.Value,
+			NetFlow:          point.NetFlow,
+			Return:           point.Return,
+			CumulativeReturn: point.CumulativeReturn,
+		})
+	}
+	return view
+}
+
+func (v TWRView) Header() []string {
+	return []string{"DATE", "CASH", "HOLDINGS", "VALUE", "NET FLOW", "RETURN (%)", "CUMULATIVE (%)"}
+}
+
+// Rows lists every trading day followed by the cumulative and annualized returns.
+func (v TWRView) Rows() [][]string {
+	rows := make([][]string, 0, len(v.Points)+2)
+	for _, point := range v.Points {
+		rows = append(rows, []string{
+			point.Date,
+			formatPrice(point.Cash),
+			formatPrice(point.HoldingsValue),
+			formatPrice(point.Value),
+			formatPrice(point.NetFlow),
+			formatPercent(&point.Return),
+			formatPercent(&point.CumulativeReturn),
+		})
+	}
+	rows = append(rows,
+		[]string{"CUMULATIVE", "", "", "", "", "", formatPercent(&v.Cumulative)},
+		[]string{"ANNUALIZED", "", "", "", "", "", formatPercent(&v.Annualized)},
+	)
+	return rows
+}
+
+// BenchmarkPointView compares the value of a portfolio and its benchmark on a
+// day. The returns are cumulative fractions.
+type BenchmarkPointView struct {
+	Date            string  `json:"date" yaml:"date"`
+	PortfolioValue  float64 `json:"portfolio_value" yaml:"portfolio_value"`
+	BenchmarkValue  float64 `json:"benchmark_value" yaml:"benchmark_value"`
+	PortfolioReturn float64 `json:"portfolio_return" yaml:"portfolio_return"`
+	BenchmarkReturn float64 `json:"benchmark_return" yaml:"benchmark_return"`
+}
+
+// BenchmarkView compares a portfolio with a benchmark symbol side by side.
+type BenchmarkView struct {
+	PortfolioID      int                  `json:"portfolio_id" yaml:"portfolio_id"`
+	Symbol           string               `json:"symbol" yaml:"symbol"`
+	From             string               `json:"from" yaml:"from"`
+	To               string               `json:"to" yaml:"to"`
+	Points           []BenchmarkPointView `json:"points" yaml:"points"`
+	PortfolioReturn  float64              `json:"portfolio_return" yaml:"portfolio_return"`
+	BenchmarkReturn  float64              `json:"benchmark_return" yaml:"benchmark_return"`
+	ExcessReturn     float64              `json:"excess_return" yaml:"excess_return"`
+	TrackingError    float64              `json:"tracking_error" yaml:"tracking_error"`
+	InformationRatio float64              `json:"information_ratio" yaml:"information_ratio"`
+}
+
+func NewBenchmarkView(portfolioID int, comparison *models.BenchmarkComparison) BenchmarkView {
+	view := BenchmarkView{
+		PortfolioID:      portfolioID,
+		Symbol:           comparison.Symbol,
+		From:             comparison.From.Format(dateLayout),
+		To:               comparison.To.Format(dateLayout),
+		Points:           make([]BenchmarkPointView, 0, len(comparison.Points)),
+		PortfolioReturn:  comparison.PortfolioReturn,
+		BenchmarkReturn:  comparison.BenchmarkReturn,
+		ExcessReturn:     comparison.ExcessReturn(),
+		TrackingError:    comparison.TrackingError,
+		InformationRatio: comparison.InformationRatio,
+	}
+	for _, point := range comparison.Points {
+		view.Points = append(view.Points, BenchmarkPointView{
+			Date:            point.Date.Format(dateLayout),
+			PortfolioValue:  point.PortfolioValue,
+			BenchmarkValue:  point.BenchmarkValue,
+			PortfolioReturn: point.PortfolioReturn,
+			BenchmarkReturn: point.BenchmarkReturn,
+		})
+	}
+	return view
+}
+
+func (v BenchmarkView) Header() []string {
+	return []string{"DATE", "PORTFOLIO", v.Symbol, "PORTFOLIO (%)", v.Symbol + " (%)"}
+}
+
+// Rows lists both value series side by side followed by the comparison metrics.
+func (v BenchmarkView) Rows() [][]string {
+	rows := make([][]string, 0, len(v.Points)+4)
+	for _, point := range v.Points {
+		rows = append(rows, []string{
+			point.Date,
+			formatPrice(point.PortfolioValue),
+			formatPrice(point.BenchmarkValue),
+			formatPercent(&point.PortfolioReturn),
+			formatPercent(&point.BenchmarkReturn),
+		})
+	}
+	rows = append(rows,
+		[]string{"RETURN (%)", "", "", formatPercent(&v.PortfolioReturn), formatPercent(&v.BenchmarkReturn)},
+		[]string{"EXCESS RETURN (%)", "", "", formatPercent(&v.ExcessReturn), ""},
+		[]string{"TRACKING ERROR (%)", "", "", formatPercent(&v.TrackingError), ""},
+		[]string{"INFORMATION RATIO", "", "", formatRatio(v.InformationRatio), ""},
+	)
+	return rows
+}
+
+// RiskView lists the risk metrics of a portfolio. Rates and returns are
+// annualized fractions.
+type RiskView struct {
+	PortfolioID    int     `json:"portfolio_id" yaml:"portfolio_id"`
+	From           string  `json:"from" yaml:"from"`
+	To             string  `json:"to" yaml:"to"`
+	Benchmark      string  `json:"benchmark" yaml:"benchmark"`
+	RiskFreeRate   float64 `json:"risk_free_rate" yaml:"risk_free_rate"`
+	Volatility     float64 `json:"volatility" yaml:"volatility"`
+	Sharpe         float64 `json:"sharpe" yaml:"sharpe"`
+	Sortino        float64 `json:"sortino" yaml:"sortino"`
+	MaxDrawdown    float64 `json:"max_drawdown" yaml:"max_drawdown"`
+	DrawdownPeak   string  `json:"drawdown_peak,omitempty" yaml:"drawdown_peak,omitempty"`
+	DrawdownTrough string  `json:"drawdown_trough,omitempty" yaml:"drawdown_trough,omitempty"`
+	Beta           float64 `json:"beta" yaml:"beta"`
+	Alpha          float64 `json:"alpha" yaml:"alpha"`
+}
+
+func NewRiskView(portfolioID int, report *models.RiskReport) RiskView {
+	view := RiskView{
+		PortfolioID:  portfolioID,
+		From:         report.From.Format(dateLayout),
+		To:           report.To.Format(dateLayout),
+		Benchmark:    report.Benchmark,
+		RiskFreeRate: report.RiskFreeRate,
+		Volatility:   report.Volatility,
+		Sharpe:       report.Sharpe,
+		Sortino:      report.Sortino,
+		MaxDrawdown:  report.MaxDrawdown,
+		Beta:         report.Beta,
+		Alpha:        report.Alpha,
+	}
+	// Without a drawdown there are no peak and trough dates.
+	if !report.DrawdownPeak.IsZero() {
+		view.DrawdownPeak = report.DrawdownPeak.Format(dateLayout)
+		view.DrawdownTrough = report.DrawdownTrough.Format(dateLayout)
+	}
+	return view
+}
+
+func (v RiskView) Header() []string {
+	return []string{"METRIC", "VALUE"}
+}
+
+// Rows lists one metric per row.
+func (v RiskView) Rows() [][]string {
+	return [][]string{
+		{"PERIOD", v.From + " - " + v.To},
+		{"RISK-FREE RATE (%)", formatPercent(&v.RiskFreeRate)},
+		{"VOLATILITY (%)", formatPercent(&v.Volatility)},
+		{"SHARPE RATIO", formatRatio(v.Sharpe)},
+		{"SORTINO RATIO", formatRatio(v.Sortino)},
+		{"MAX DRAWDOWN (%)", formatPercent(&v.MaxDrawdown)},
+		{"DRAWDOWN PEAK", v.DrawdownPeak},
+		{"DRAWDOWN TROUGH", v.DrawdownTrough},
+		{"BETA (" + v.Benchmark + ")", formatRatio(v.Beta)},
+		{"ALPHA (%)", formatPercent(&v.Alpha)},
+	}
+}
@@ -1,23 +1,168 @@
 package main
 
 import (
+	"context"
 	"github.com/fcopulgar/stock-manager-go/cmd/cli"
 	"github.com/fcopulgar/stock-manager-go/config"
 	"github.com/fcopulgar/stock-manager-go/repositories"
 	"github.com/fcopulgar/stock-manager-go/services"
+	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
 	config.LoadConfig()
 
-	// Initialize the repository and services
-	repo := repositories.NewSQLitePortfolioRepository("portfolios.db")
-	apiKey := config.GetEnv("FMP_API_KEY")
-	stockService := services.NewFinancialModelingPrepService(apiKey)
-	portfolioService := services.NewPortfolioService(repo, stockService)
+	// Initialize the repository and services. The schema is migrated on startup
+	// except by the db command, which reports and migrates it itself
+	dbCommand := cli.CommandName(os.Args[1:]) == "db"
+	repo, err := repositories.OpenSQLitePortfolioRepository("portfolios.db")
+	if err != nil {
+		log.Fatalf("Error opening the database: %v", err)
+	}
+
+	// The portfolios are kept in PostgreSQL when DATABASE_URL is set, while the
+	// prices and the usage of the APIs stay in the SQLite database, which is
+	// then always migrated
+	var portfolioRepo repositories.PortfolioRepository = repo
+	var migrator repositories.Migrator = repo
+	if databaseURL := config.GetEnv("DATABASE_URL"); databaseURL != "" {
+		postgresRepo, err := repositories.OpenPostgresPortfolioRepository(databaseURL)
+		if err != nil {
+			log.Fatalf("Error opening the PostgreSQL database: %v", err)
+		}
+		if _, err := repo.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating the database: %v", err)
+		}
+		portfolioRepo = postgresRepo
+		migrator = postgresRepo
+	}
+	if !dbCommand {
+		if _, err := migrator.Migrate(context.Background()); err != nil {
+			log.Fatalf("Error migrating the database: %v", err)
+		}
+	}
+
+	// Requests to Financial Modeling Prep stay within the limits of the plan,
+	// the free one by default, and the requests of the day are kept in the database
+	fmpOptions := services.DefaultClientOptions()
+	fmpOptions.Limiter = services.NewRateLimiter(services.FMPSource,
+		intEnv("FMP_REQUESTS_PER_MINUTE", services.DefaultFMPRequestsPerMinute),
+		intEnv("FMP_REQUESTS_PER_DAY", services.DefaultFMPRequestsPerDay),
+		repo)
+	fmpService := services.NewFinancialModelingPrepServiceWithOptions(config.GetEnv("FMP_API_KEY"), fmpOptions)
+	alphaVantageService := services.NewAlphaVantageService(config.GetEnv("ALPHAVANTAGE_API_KEY"))
+	csvService := services.NewCSVStockService(config.GetEnv("PRICE_CSV_DIR"))
+	csvService.MemoryMap = config.GetEnv("PRICE_CSV_MMAP") == "true"
+
+	// Prices come from Financial Modeling Prep unless PRICE_PROVIDER lists the
+	// providers to try in order, such as "fmp,alphavantage,csv"
+	providerNames := config.GetEnv("PRICE_PROVIDER")
+	if providerNames == "" {
+		providerNames = services.FMPSource
+	}
+	var providers []services.NamedProvider
+	for _, name := range strings.Split(providerNames, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case services.FMPSource:
+			providers = append(providers, services.NamedProvider{Name: name, Service: fmpService})
+		case services.AlphaVantageSource:
+			providers = append(providers, services.NamedProvider{Name: name, Service: alphaVantageService})
+		case services.CSVSource:
+			if csvService.Dir == "" {
+				log.Fatalf("PRICE_CSV_DIR is required by the csv price provider")
+			}
+			providers = append(providers, services.NamedProvider{Name: name, Service: csvService})
+		default:
+			log.Fatalf("Invalid PRICE_PROVIDER %q, expected fmp, alphavantage or csv", name)
+		}
+	}
+	provider := services.NewFailoverStockService(providers...)
+
+	// Prices are cached in the same database, the ones of today only for a while
+	ttl := services.DefaultPriceCacheTTL
+	if value := config.GetEnv("PRICE_CACHE_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid PRICE_CACHE_TTL %q: %v", value, err)
+		}
+		ttl = parsed
+	}
+	stockService := services.NewCachedStockService(provider, repo, ttl)
+
+	// Dates without trading are priced with the previous trading day unless configured otherwise
+	if value := config.GetEnv("PRICE_DATE_RESOLUTION"); value != "" {
+		resolution, err := services.ParseDateResolution(value)
+		if err != nil {
+			log.Fatalf("Invalid PRICE_DATE_RESOLUTION: %v", err)
+		}
+		fmpService.Resolution = resolution
+		alphaVantageService.Resolution = resolution
+		csvService.Resolution = resolution
+		stockService.Resolution = resolution
+	}
+
+	portfolioService := services.NewPortfolioService(portfolioRepo, stockService)
 
-	// Start the CLI
 	cli := cli.NewCLI(portfolioService, os.Stdin, os.Stdout)
-	cli.Run()
+	cli.SetErrorOutput(os.Stderr)
+	cli.SetPriceCache(stockService)
+	manualPrices := services.NewManualPriceFallback(repo)
+	cli.SetManualPrices(manualPrices)
+	cli.SetMigrator(migrator)
+
+	// Prices the provider cannot return are asked to the user in the menu and
+	// fail in commands, unless PRICE_FALLBACK selects another strategy
+	interactive := len(os.Args) <= 1
+	fallback := config.GetEnv("PRICE_FALLBACK")
+	if fallback == "" {
+		fallback = "fail"
+		if interactive {
+			fallback = "prompt"
+		}
+	}
+	var priceFallback services.PriceFallback
+	switch fallback {
+	case "prompt":
+		priceFallback = cli.PromptFallback()
+	case "manual":
+		priceFallback = manualPrices
+	case "last-known":
+		priceFallback = services.NewLastKnownPriceFallback(repo)
+	case "fail":
+		priceFallback = services.FailFastFallback{}
+	default:
+		log.Fatalf("Invalid PRICE_FALLBACK %q, expected prompt, manual, last-known or fail", fallback)
+	}
+	// Only the cache falls back, once neither the cached bars nor the providers
+	// had the price
+	stockService.Fallback = priceFallback
+
+	// Run a single command when one is given, which Ctrl-C cancels, otherwise
+	// start the interactive menu
+	if !interactive {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		code := cli.Execute(ctx, os.Args[1:])
+		stop()
+		os.Exit(code)
+	}
+	cli.Run(context.Background())
+}
+
+// intEnv returns the integer value of the environment variable, or fallback when it is not set.
+func intEnv(key string, fallback int) int {
+	value := config.GetEnv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Fatalf("Invalid %s %q, expected a non-negative integer", key, value)
+	}
+	return parsed
 }
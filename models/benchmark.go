@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// BenchmarkPoint compares the value of a portfolio on a trading day with the
+// value the same cash flows would have reached in the benchmark. The returns
+// are cumulative and time-weighted.
+type BenchmarkPoint struct {
+	Date            time.Time
+	PortfolioValue  float64
+	BenchmarkValue  float64
+	PortfolioReturn float64
+	BenchmarkReturn float64
+}
+
+// BenchmarkComparison is the result of a portfolio against a benchmark symbol.
+// TrackingError is the annualized standard deviation of the daily excess
+// returns and InformationRatio the annualized excess return per unit of it.
+type BenchmarkComparison struct {
+	Symbol           string
+	From             time.Time
+	To               time.Time
+	Points           []BenchmarkPoint
+	PortfolioReturn  float64
+	BenchmarkReturn  float64
+	TrackingError    float64
+	InformationRatio float64
+}
+
+// ExcessReturn returns how much the portfolio beat the benchmark over the period.
+func (c BenchmarkComparison) ExcessReturn() float64 {
+	return c.PortfolioReturn - c.BenchmarkReturn
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type CashEntryType string
+
+const (
+	CashDeposit    CashEntryType = "deposit"
+	CashWithdrawal CashEntryType = "withdrawal"
+	CashInterest   CashEntryType = "interest"
+	CashFee        CashEntryType = "fee"
+)
+
+// CashEntry is a movement of the cash account of a portfolio that is not
+// caused by a transaction. Amount is always positive; the type gives its sign.
+type CashEntry struct {
+	ID          int
+	PortfolioID int
+	Type        CashEntryType
+	Date        time.Time
+	Amount      float64
+	Description string
+}
+
+// SignedAmount returns the effect of the entry on the cash balance.
+func (e CashEntry) SignedAmount() float64 {
+	switch e.Type {
+	case CashWithdrawal, CashFee:
+		return -e.Amount
+	default:
+		return e.Amount
+	}
+}
+
+// IsExternalFlow reports whether the entry moves money in or out of the portfolio.
+func (e CashEntry) IsExternalFlow() bool {
+	return e.Type == CashDeposit || e.Type == CashWithdrawal
+}
@@ -0,0 +1,27 @@
+package models
+
+import "errors"
+
+// The kinds of failure shared by the repositories, the services and the price
+// providers. The errors they return wrap one of them, so callers such as the
+// CLI tell the kinds apart with errors.Is whatever the layer that failed.
+var (
+	// ErrNotFound is returned when a record, such as a portfolio, does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a record clashes with one already stored,
+	// such as a portfolio with the name of another.
+	ErrConflict = errors.New("already exists")
+	// ErrValidation is returned when the input is invalid, such as a sell of
+	// more shares than are held.
+	ErrValidation = errors.New("invalid input")
+	// ErrPriceUnavailable is returned when a price could be obtained neither
+	// from the providers nor from the fallback.
+	ErrPriceUnavailable = errors.New("price unavailable")
+	// ErrRateLimited is returned when a price provider refuses a request
+	// because too many were made.
+	ErrRateLimited = errors.New("API rate limit reached")
+	// ErrProviderConfig is returned when a price provider refuses a request
+	// because of its configuration, such as an API key without access to the
+	// endpoint, which retrying does not fix.
+	ErrProviderConfig = errors.New("price provider misconfigured")
+)
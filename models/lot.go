@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Lot is a group of shares bought together that is still held.
+type Lot struct {
+	ID           string
+	Symbol       string
+	BuyDate      time.Time
+	Quantity     float64
+	CostPerShare float64
+}
+
+// LotSelection picks the shares of a lot consumed by a specific-lot sell.
+type LotSelection struct {
+	LotID    string
+	Quantity float64
+}
+
+// RealizedSale is the part of a sell that consumed a single lot.
+type RealizedSale struct {
+	Symbol    string
+	LotID     string
+	BuyDate   time.Time
+	SellDate  time.Time
+	Quantity  float64
+	Proceeds  float64
+	CostBasis float64
+	LongTerm  bool
+}
+
+// Gain returns the realized gain of the sale.
+func (s RealizedSale) Gain() float64 {
+	return s.Proceeds - s.CostBasis
+}
+
+// RealizedGainsReport lists the sales of a period split by holding period.
+type RealizedGainsReport struct {
+	From      time.Time
+	To        time.Time
+	Sales     []RealizedSale
+	ShortTerm float64
+	LongTerm  float64
+}
+
+// Total returns the realized gain of the period.
+func (r RealizedGainsReport) Total() float64 {
+	return r.ShortTerm + r.LongTerm
+}
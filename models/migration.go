@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SchemaMigration is a versioned change of the database schema. AppliedAt is
+// nil while the migration is pending, and Unknown marks migrations applied by
+// a newer version of the program.
+type SchemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
@@ -1,7 +1,52 @@
 package models
 
 type Portfolio struct {
-	ID     int
-	Name   string
-	Stocks []Stock
+	ID              int
+	Name            string
+	Stocks          []Stock
+	CostBasisMethod CostBasisMethod
+}
+
+// CostBasisMethod selects which lots are consumed when shares are sold.
+type CostBasisMethod string
+
+const (
+	CostBasisFIFO     CostBasisMethod = "fifo"
+	CostBasisLIFO     CostBasisMethod = "lifo"
+	CostBasisHIFO     CostBasisMethod = "hifo"
+	CostBasisAverage  CostBasisMethod = "average"
+	CostBasisSpecific CostBasisMethod = "specific"
+)
+
+// CostBasisMethods lists every supported method.
+var CostBasisMethods = []CostBasisMethod{CostBasisFIFO, CostBasisLIFO, CostBasisHIFO, CostBasisAverage, CostBasisSpecific}
+
+// Method returns the cost basis method of the portfolio, average cost when none was set.
+func (p Portfolio) Method() CostBasisMethod {
+	if p.CostBasisMethod == "" {
+		return CostBasisAverage
+	}
+	return p.CostBasisMethod
+}
+
+// PortfolioSort is the order in which portfolios are listed.
+type PortfolioSort string
+
+const (
+	PortfolioSortID   PortfolioSort = "id"
+	PortfolioSortName PortfolioSort = "name"
+)
+
+// PortfolioSorts lists every supported order.
+var PortfolioSorts = []PortfolioSort{PortfolioSortID, PortfolioSortName}
+
+// PortfolioQuery selects a page of portfolios. Name keeps the portfolios whose
+// name contains it, ignoring case, and a Limit of 0 returns every portfolio
+// after Offset. Portfolios are sorted by ID when no order is given.
+type PortfolioQuery struct {
+	Name       string
+	Sort       PortfolioSort
+	Descending bool
+	Limit      int
+	Offset     int
 }
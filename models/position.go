@@ -0,0 +1,45 @@
+package models
+
+// Position is the holding of a symbol derived from the transaction ledger.
+type Position struct {
+	Symbol         string
+	Quantity       float64
+	CostBasis      float64
+	MarketPrice    float64
+	MarketValue    float64
+	RealizedGain   float64
+	UnrealizedGain float64
+	Dividends      float64
+	Fees           float64
+}
+
+// AverageCost returns the cost per share of the shares still held.
+func (p Position) AverageCost() float64 {
+	if p.Quantity == 0 {
+		return 0
+	}
+	return p.CostBasis / p.Quantity
+}
+
+// Gains summarizes the realized and unrealized results of a portfolio and its
+// value, which is the cash balance plus the market value of the holdings.
+type Gains struct {
+	Positions     []Position
+	Realized      float64
+	Unrealized    float64
+	Dividends     float64
+	Interest      float64
+	Fees          float64
+	Cash          float64
+	HoldingsValue float64
+}
+
+// Total returns the overall result including income and costs.
+func (g Gains) Total() float64 {
+	return g.Realized + g.Unrealized + g.Dividends + g.Interest - g.Fees
+}
+
+// TotalValue returns the cash balance plus the market value of the holdings.
+func (g Gains) TotalValue() float64 {
+	return g.Cash + g.HoldingsValue
+}
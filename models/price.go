@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// PriceBar holds the open, high, low and close prices and the volume of a
+// symbol on a trading day. Source names where the prices came from, such as
+// the provider that served them or a manual entry.
+type PriceBar struct {
+	Date   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+	Source string
+}
+
+// CachedPriceBar is a price bar of a symbol kept by the price cache together
+// with the time it was fetched from the provider.
+type CachedPriceBar struct {
+	Symbol string
+	PriceBar
+	FetchedAt time.Time
+}
+
+// PriceRange is a period whose bars were fetched for a symbol. Days inside it
+// without a bar had no trading.
+type PriceRange struct {
+	Symbol string
+	From   time.Time
+	To     time.Time
+}
+
+// ManualPrice is a price entered by hand for a symbol on a date, used when no
+// provider has it.
+type ManualPrice struct {
+	Symbol string
+	Date   time.Time
+	Price  float64
+}
+
+// DateResolution decides which trading day prices a date without trading,
+// such as a weekend or a holiday.
+type DateResolution string
+
+const (
+	ResolvePreviousTradingDay DateResolution = "previous"
+	ResolveNextTradingDay     DateResolution = "next"
+	ResolveStrict             DateResolution = "strict"
+)
+
+// DateResolutions lists every supported resolution.
+var DateResolutions = []DateResolution{ResolvePreviousTradingDay, ResolveNextTradingDay, ResolveStrict}
+
+// ResolvedPrice is the bar used for a requested date. Its date differs from
+// the requested one when that date had no trading.
+type ResolvedPrice struct {
+	RequestedDate time.Time
+	PriceBar
+}
+
+// Adjusted reports whether the price comes from another trading day.
+func (p ResolvedPrice) Adjusted() bool {
+	return p.Date.Format("2006-01-02") != p.RequestedDate.Format("2006-01-02")
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RiskReport holds the risk metrics of a portfolio over a period. Rates and
+// returns are annualized fractions computed from daily time-weighted returns.
+// MaxDrawdown is the largest fall from a peak, as a negative fraction, and
+// Alpha is the annualized return not explained by the exposure to the benchmark.
+type RiskReport struct {
+	From           time.Time
+	To             time.Time
+	Benchmark      string
+	RiskFreeRate   float64
+	Volatility     float64
+	Sharpe         float64
+	Sortino        float64
+	MaxDrawdown    float64
+	DrawdownPeak   time.Time
+	DrawdownTrough time.Time
+	Beta           float64
+	Alpha          float64
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type TransactionType string
+
+const (
+	TransactionBuy      TransactionType = "buy"
+	TransactionSell     TransactionType = "sell"
+	TransactionDividend TransactionType = "dividend"
+	TransactionFee      TransactionType = "fee"
+	TransactionSplit    TransactionType = "split"
+)
+
+// Transaction is an entry of a portfolio ledger.
+//
+// Buys and sells use Quantity shares at Price per share. Dividends and fees
+// carry their total cash amount in Price. Splits use Quantity as the split
+// ratio, e.g. 4 for a 4-for-1 split. Sells may list the lots they consume,
+// which is required when the portfolio uses specific-lot identification.
+type Transaction struct {
+	ID          int
+	PortfolioID int
+	Symbol      string
+	Type        TransactionType
+	Date        time.Time
+	Quantity    float64
+	Price       float64
+	Commission  float64
+	Lots        []LotSelection
+
+	// Contributed marks the buys derived from the stocks of the portfolio,
+	// which were contributed in kind and do not move cash.
+	Contributed bool
+	StockID     int
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ValuationPoint is the value of a portfolio at the close of a trading day.
+// NetFlow is the money contributed that day, negative for withdrawals, and
+// Return the time-weighted return of the day once that flow is removed.
+type ValuationPoint struct {
+	Date             time.Time
+	Cash             float64
+	HoldingsValue    float64
+	Value            float64
+	NetFlow          float64
+	Return           float64
+	CumulativeReturn float64
+}
+
+// TWRReport is the time-weighted return of a portfolio between two dates
+// together with the daily valuation series it was chained from.
+type TWRReport struct {
+	From       time.Time
+	To         time.Time
+	Points     []ValuationPoint
+	Cumulative float64
+	Annualized float64
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"time"
+)
+
+// APIUsageRepository counts the requests made to a price provider per day, so
+// its daily quota holds across runs.
+type APIUsageRepository interface {
+	GetAPIUsage(ctx context.Context, provider string, day time.Time) (int, error)
+	// IncrementAPIUsage counts one more request and returns the requests of the day.
+	IncrementAPIUsage(ctx context.Context, provider string, day time.Time) (int, error)
+}
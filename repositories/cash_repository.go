@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"context"
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+type CashRepository interface {
+	GetCashEntries(ctx context.Context, portfolioID int) ([]models.CashEntry, error)
+	SaveCashEntry(ctx context.Context, entry *models.CashEntry) error
+	DeleteCashEntry(ctx context.Context, id int) error
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The kinds of constraint the database enforces, which a ConstraintError
+// matches with errors.Is along with the domain error of the kind.
+var (
+	ErrDuplicate   = errors.New("duplicate value")
+	ErrForeignKey  = errors.New("referenced record does not exist")
+	ErrCheckFailed = errors.New("invalid value")
+)
+
+// ConstraintError is returned when a write violates a constraint of the
+// schema. Kind is ErrDuplicate, ErrForeignKey or ErrCheckFailed.
+type ConstraintError struct {
+	Kind   error
+	Detail string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Kind, e.Detail)
+}
+
+// Is lets errors.Is match the error against its kind.
+func (e *ConstraintError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the domain error of the kind: models.ErrConflict for a
+// duplicate, models.ErrNotFound for a missing reference and
+// models.ErrValidation for an invalid value.
+func (e *ConstraintError) Unwrap() error {
+	switch e.Kind {
+	case ErrDuplicate:
+		return models.ErrConflict
+	case ErrForeignKey:
+		return models.ErrNotFound
+	case ErrCheckFailed:
+		return models.ErrValidation
+	}
+	return nil
+}
+
+// notFound returns an error wrapping models.ErrNotFound when the statement
+// changed no row.
+func notFound(res sql.Result, record string, id any) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s %v %w", record, id, models.ErrNotFound)
+	}
+	return nil
+}
+
+// constraintError turns the constraint violations reported by SQLite or
+// PostgreSQL into a ConstraintError and returns any other error as it is.
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return postgresConstraintError(pgErr, err)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	var kind error
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		kind = ErrDuplicate
+	case sqlite3.ErrConstraintForeignKey:
+		kind = ErrForeignKey
+	case sqlite3.ErrConstraintCheck, sqlite3.ErrConstraintNotNull:
+		kind = ErrCheckFailed
+	default:
+		return err
+	}
+	return &ConstraintError{Kind: kind, Detail: sqliteErr.Error()}
+}
+
+// postgresConstraintError classifies the integrity constraint violations of
+// PostgreSQL by their SQLSTATE code.
+func postgresConstraintError(pgErr *pgconn.PgError, err error) error {
+	var kind error
+	switch pgErr.Code {
+	case "23505":
+		kind = ErrDuplicate
+	case "23503":
+		kind = ErrForeignKey
+	case "23514", "23502":
+		kind = ErrCheckFailed
+	default:
+		return err
+	}
+	return &ConstraintError{Kind: kind, Detail: pgErr.Message}
+}
@@ -0,0 +1,380 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// MemoryPortfolioRepository keeps the portfolios in memory, enforcing the
+// constraints of the database schema, for tests and short-lived programs.
+type MemoryPortfolioRepository struct {
+	mu           sync.Mutex
+	portfolios   map[int]models.Portfolio
+	transactions map[int]models.Transaction
+	cashEntries  map[int]models.CashEntry
+	lastIDs      map[string]int
+}
+
+func NewMemoryPortfolioRepository() *MemoryPortfolioRepository {
+	return &MemoryPortfolioRepository{
+		portfolios:   make(map[int]models.Portfolio),
+		transactions: make(map[int]models.Transaction),
+		cashEntries:  make(map[int]models.CashEntry),
+		lastIDs:      make(map[string]int),
+	}
+}
+
+func (repo *MemoryPortfolioRepository) GetAll(ctx context.Context) ([]models.Portfolio, error) {
+	return repo.List(ctx, models.PortfolioQuery{})
+}
+
+// List returns the page of portfolios selected by the query with their stocks.
+func (repo *MemoryPortfolioRepository) List(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		return nil, fmt.Errorf("invalid page with limit %d and offset %d", query.Limit, query.Offset)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	name := strings.ToLower(query.Name)
+	portfolios := []models.Portfolio{}
+	for _, portfolio := range repo.portfolios {
+		if strings.Contains(strings.ToLower(portfolio.Name), name) {
+			portfolios = append(portfolios, copyPortfolio(portfolio))
+		}
+	}
+
+	var less func(a, b models.Portfolio) bool
+	switch query.Sort {
+	case "", models.PortfolioSortID:
+		less = func(a, b models.Portfolio) bool { return a.ID < b.ID }
+	case models.PortfolioSortName:
+		less = func(a, b models.Portfolio) bool {
+			an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			if an != bn {
+				return an < bn
+			}
+			return a.ID < b.ID
+		}
+	default:
+		return nil, fmt.Errorf("unknown portfolio sort %q", query.Sort)
+	}
+	sort.Slice(portfolios, func(i, j int) bool {
+		if query.Descending {
+			return less(portfolios[j], portfolios[i])
+		}
+		return less(portfolios[i], portfolios[j])
+	})
+
+	if query.Offset >= len(portfolios) {
+		return []models.Portfolio{}, nil
+	}
+	portfolios = portfolios[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(portfolios) {
+		portfolios = portfolios[:query.Limit]
+	}
+	return portfolios, nil
+}
+
+func (repo *MemoryPortfolioRepository) GetByID(ctx context.Context, id int) (*models.Portfolio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	portfolio, ok := repo.portfolios[id]
+	if !ok {
+		return nil, fmt.Errorf("portfolio %d %w", id, models.ErrNotFound)
+	}
+	portfolio = copyPortfolio(portfolio)
+	return &portfolio, nil
+}
+
+// Save stores the portfolio with new IDs for it and its stocks. Like the
+// database repositories, it leaves the ID of the portfolio unset.
+func (repo *MemoryPortfolioRepository) Save(ctx context.Context, portfolio *models.Portfolio) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if err := repo.checkPortfolio(*portfolio, 0); err != nil {
+		return err
+	}
+
+	stored := models.Portfolio{Name: portfolio.Name, CostBasisMethod: portfolio.Method(), Stocks: []models.Stock{}}
+	stored.ID = repo.nextID("portfolios")
+	for _, stock := range portfolio.Stocks {
+		stock.ID = repo.nextID("stocks")
+		stock.BuyDate = dateOf(stock.BuyDate)
+		stored.Stocks = append(stored.Stocks, stock)
+	}
+	repo.portfolios[stored.ID] = stored
+	return nil
+}
+
+func (repo *MemoryPortfolioRepository) Update(ctx context.Context, portfolio *models.Portfolio) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if err := repo.checkPortfolio(*portfolio, portfolio.ID); err != nil {
+		return err
+	}
+	current, ok := repo.portfolios[portfolio.ID]
+	if !ok {
+		return fmt.Errorf("portfolio %d %w", portfolio.ID, models.ErrNotFound)
+	}
+
+	// Stocks keep their IDs across updates, and the ones of other portfolios are ignored
+	owned := make(map[int]bool, len(current.Stocks))
+	for _, stock := range current.Stocks {
+		owned[stock.ID] = true
+	}
+
+	updated := models.Portfolio{ID: portfolio.ID, Name: portfolio.Name, CostBasisMethod: portfolio.Method(), Stocks: []models.Stock{}}
+	for _, stock := range portfolio.Stocks {
+		if stock.ID == 0 {
+			stock.ID = repo.nextID("stocks")
+		} else if !owned[stock.ID] {
+			continue
+		}
+		stock.BuyDate = dateOf(stock.BuyDate)
+		updated.Stocks = append(updated.Stocks, stock)
+	}
+	sort.Slice(updated.Stocks, func(i, j int) bool { return updated.Stocks[i].ID < updated.Stocks[j].ID })
+	repo.portfolios[portfolio.ID] = updated
+	return nil
+}
+
+// Delete removes the portfolio with its stocks, transactions and cash entries.
+func (repo *MemoryPortfolioRepository) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.portfolios[id]; !ok {
+		return fmt.Errorf("portfolio %d %w", id, models.ErrNotFound)
+	}
+	delete(repo.portfolios, id)
+	for transactionID, transaction := range repo.transactions {
+		if transaction.PortfolioID == id {
+			delete(repo.transactions, transactionID)
+		}
+	}
+	for entryID, entry := range repo.cashEntries {
+		if entry.PortfolioID == id {
+			delete(repo.cashEntries, entryID)
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryPortfolioRepository) GetTransactions(ctx context.Context, portfolioID int) ([]models.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	transactions := []models.Transaction{}
+	for _, transaction := range repo.transactions {
+		if transaction.PortfolioID == portfolioID {
+			transaction.Lots = append([]models.LotSelection(nil), transaction.Lots...)
+			transactions = append(transactions, transaction)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.Before(transactions[j].Date)
+		}
+		return transactions[i].ID < transactions[j].ID
+	})
+	return transactions, nil
+}
+
+func (repo *MemoryPortfolioRepository) SaveTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.portfolios[transaction.PortfolioID]; !ok {
+		return &ConstraintError{Kind: ErrForeignKey, Detail: fmt.Sprintf("portfolio %d does not exist", transaction.PortfolioID)}
+	}
+	switch transaction.Type {
+	case models.TransactionBuy, models.TransactionSell, models.TransactionDividend, models.TransactionFee, models.TransactionSplit:
+	default:
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("transaction type %q", transaction.Type)}
+	}
+	if transaction.Quantity < 0 || transaction.Price < 0 || transaction.Commission < 0 {
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: "negative transaction quantity, price or commission"}
+	}
+	for _, lot := range transaction.Lots {
+		if lot.Quantity <= 0 {
+			return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("lot %s quantity %v", lot.LotID, lot.Quantity)}
+		}
+	}
+
+	stored := *transaction
+	stored.ID = repo.nextID("transactions")
+	stored.Date = dateOf(stored.Date)
+	stored.Lots = nil
+	if len(transaction.Lots) > 0 {
+		stored.Lots = append([]models.LotSelection(nil), transaction.Lots...)
+	}
+	// Only the columns of the database are stored
+	stored.Contributed = false
+	stored.StockID = 0
+	repo.transactions[stored.ID] = stored
+	transaction.ID = stored.ID
+	return nil
+}
+
+// DeleteTransaction removes the transaction with its lot selections.
+func (repo *MemoryPortfolioRepository) DeleteTransaction(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.transactions[id]; !ok {
+		return fmt.Errorf("transaction %d %w", id, models.ErrNotFound)
+	}
+	delete(repo.transactions, id)
+	return nil
+}
+
+func (repo *MemoryPortfolioRepository) GetCashEntries(ctx context.Context, portfolioID int) ([]models.CashEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	entries := []models.CashEntry{}
+	for _, entry := range repo.cashEntries {
+		if entry.PortfolioID == portfolioID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Date.Before(entries[j].Date)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+func (repo *MemoryPortfolioRepository) SaveCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.portfolios[entry.PortfolioID]; !ok {
+		return &ConstraintError{Kind: ErrForeignKey, Detail: fmt.Sprintf("portfolio %d does not exist", entry.PortfolioID)}
+	}
+	switch entry.Type {
+	case models.CashDeposit, models.CashWithdrawal, models.CashInterest, models.CashFee:
+	default:
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("cash entry type %q", entry.Type)}
+	}
+	if entry.Amount <= 0 {
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("cash entry amount %v", entry.Amount)}
+	}
+
+	stored := *entry
+	stored.ID = repo.nextID("cash_entries")
+	stored.Date = dateOf(stored.Date)
+	repo.cashEntries[stored.ID] = stored
+	entry.ID = stored.ID
+	return nil
+}
+
+func (repo *MemoryPortfolioRepository) DeleteCashEntry(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.cashEntries[id]; !ok {
+		return fmt.Errorf("cash entry %d %w", id, models.ErrNotFound)
+	}
+	delete(repo.cashEntries, id)
+	return nil
+}
+
+// checkPortfolio applies the constraints of the schema to the portfolio,
+// whose name may only be taken by the portfolio with the given ID.
+func (repo *MemoryPortfolioRepository) checkPortfolio(portfolio models.Portfolio, id int) error {
+	if portfolio.Name == "" {
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: "empty portfolio name"}
+	}
+	valid := false
+	for _, method := range models.CostBasisMethods {
+		valid = valid || portfolio.Method() == method
+	}
+	if !valid {
+		return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("cost basis method %q", portfolio.CostBasisMethod)}
+	}
+	for _, other := range repo.portfolios {
+		if other.Name == portfolio.Name && other.ID != id {
+			return &ConstraintError{Kind: ErrDuplicate, Detail: fmt.Sprintf("portfolio name %q", portfolio.Name)}
+		}
+	}
+	for _, stock := range portfolio.Stocks {
+		if stock.Symbol == "" || stock.Quantity <= 0 || stock.BuyPrice < 0 {
+			return &ConstraintError{Kind: ErrCheckFailed, Detail: fmt.Sprintf("stock %q quantity %d buy price %v", stock.Symbol, stock.Quantity, stock.BuyPrice)}
+		}
+	}
+	return nil
+}
+
+// nextID returns a new ID for a record of the table, which numbers its
+// records from 1 like the tables of the database.
+func (repo *MemoryPortfolioRepository) nextID(table string) int {
+	repo.lastIDs[table]++
+	return repo.lastIDs[table]
+}
+
+func copyPortfolio(portfolio models.Portfolio) models.Portfolio {
+	portfolio.Stocks = append([]models.Stock{}, portfolio.Stocks...)
+	return portfolio
+}
+
+// dateOf drops the time of day, as the date columns of the database do.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,438 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var embeddedMigrations embed.FS
+
+// The directories of the migrations of each database. The PostgreSQL ones
+// have the versions and names of the SQLite ones.
+const (
+	sqliteMigrations   = "migrations"
+	postgresMigrations = "migrations/postgres"
+)
+
+// migrationFiles holds the migrations, the embedded ones unless a test
+// replaces them.
+var migrationFiles fs.FS = embeddedMigrations
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of the program, whose schema this one does not know.
+var ErrSchemaTooNew = errors.New("the database schema is newer than this version of stock-manager")
+
+// Migrator applies the versioned migrations of the database schema.
+type Migrator interface {
+	// Migrate applies the pending migrations in order and returns them.
+	Migrate(ctx context.Context) ([]models.SchemaMigration, error)
+	// MigrationStatus returns the known migrations and the applied ones the
+	// program does not know, ordered by version.
+	MigrationStatus(ctx context.Context) ([]models.SchemaMigration, error)
+}
+
+// migration is an embedded SQL file named <version>_<name>.sql.
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// migrationHooks run in the transaction of a migration, after its SQL, for
+// the changes SQLite cannot express idempotently.
+var migrationHooks = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	1: upgradeLegacySchema,
+}
+
+// loadMigrations returns the embedded migrations of the directory ordered by
+// version.
+func loadMigrations(dir string) ([]migration, error) {
+	files, err := fs.Glob(migrationFiles, dir+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(files))
+	seen := make(map[int]string)
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q, expected <version>_<name>.sql", file)
+		}
+		if other, duplicated := seen[version]; duplicated {
+			return nil, fmt.Errorf("migrations %q and %q have the same version", other, file)
+		}
+		seen[version] = file
+
+		content, err := fs.ReadFile(migrationFiles, file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies every pending migration in its own transaction. It refuses
+// to touch a database migrated by a newer version of the program. Processes
+// migrating the database at the same time apply each migration once, since
+// every transaction reads the applied migrations again holding the write lock.
+func (repo *SQLitePortfolioRepository) Migrate(ctx context.Context) ([]models.SchemaMigration, error) {
+	migrations, err := loadMigrations(sqliteMigrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, repo.DB)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []models.SchemaMigration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		appliedAt, err := repo.applyMigration(ctx, migrations, m)
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if appliedAt != nil {
+			done = append(done, models.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: appliedAt})
+		}
+	}
+	return done, nil
+}
+
+// MigrationStatus reports which migrations are applied and which are pending.
+func (repo *SQLitePortfolioRepository) MigrationStatus(ctx context.Context) ([]models.SchemaMigration, error) {
+	return migrationStatus(ctx, repo.DB, sqliteMigrations)
+}
+
+// Migrate applies every pending migration in its own transaction. It refuses
+// to touch a database migrated by a newer version of the program. Programs
+// migrating the shared database at the same time wait for each other, from
+// before schema_migrations is created, so each migration is applied once.
+func (repo *PostgresPortfolioRepository) Migrate(ctx context.Context) ([]models.SchemaMigration, error) {
+	migrations, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		return nil, err
+	}
+	conn, unlock, err := repo.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var done []models.SchemaMigration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		appliedAt, err := repo.applyMigration(ctx, conn, m)
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, models.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: &appliedAt})
+	}
+	return done, nil
+}
+
+// MigrationStatus reports which migrations are applied and which are pending.
+func (repo *PostgresPortfolioRepository) MigrationStatus(ctx context.Context) ([]models.SchemaMigration, error) {
+	conn, unlock, err := repo.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return migrationStatus(ctx, conn, postgresMigrations)
+}
+
+// migrationStatus lists the migrations of the directory, with the time they
+// were applied to the database, and the applied ones it does not hold.
+func migrationStatus(ctx context.Context, db execQuerier, dir string) ([]models.SchemaMigration, error) {
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]models.SchemaMigration, 0, len(migrations))
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		entry := models.SchemaMigration{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			entry.AppliedAt = a.AppliedAt
+		}
+		status = append(status, entry)
+	}
+	for version, a := range applied {
+		if !known[version] {
+			a.Unknown = true
+			status = append(status, a)
+		}
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// execQuerier runs statements on a database or on one of its connections.
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// appliedMigrations returns the migrations recorded in schema_migrations,
+// which it creates when missing, by version.
+func appliedMigrations(ctx context.Context, db execQuerier) (map[int]models.SchemaMigration, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TEXT NOT NULL
+    )`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]models.SchemaMigration)
+	for rows.Next() {
+		var m models.SchemaMigration
+		var appliedAtStr string
+		if err := rows.Scan(&m.Version, &m.Name, &appliedAtStr); err != nil {
+			return nil, err
+		}
+		appliedAt, err := time.Parse(time.RFC3339, appliedAtStr)
+		if err != nil {
+			return nil, err
+		}
+		m.AppliedAt = &appliedAt
+		applied[m.Version] = m
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaVersion fails when a migration newer than the last known one
+// was applied.
+func checkSchemaVersion(migrations []migration, applied map[int]models.SchemaMigration) error {
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: it is at version %d and the latest known is %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the migration on a single connection with the foreign
+// keys off, since rebuilding a table drops the one other tables refer to, and
+// checks every reference before committing. The transaction takes the write
+// lock when it begins, so it returns a nil time when another process applied
+// the migration while this one waited for the lock.
+func (repo *SQLitePortfolioRepository) applyMigration(ctx context.Context, migrations []migration, m migration) (*time.Time, error) {
+	conn, err := repo.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// The pragma has no effect inside a transaction
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, tx)
+	if err == nil {
+		err = checkSchemaVersion(migrations, applied)
+	}
+	if _, ok := applied[m.Version]; err != nil || ok {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if _, err = tx.ExecContext(ctx, m.SQL); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if hook, ok := migrationHooks[m.Version]; ok {
+		if err = hook(ctx, tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err = checkForeignKeys(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	appliedAt := time.Now().UTC().Truncate(time.Second)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, appliedAt.Format(time.RFC3339))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &appliedAt, nil
+}
+
+// checkForeignKeys fails when a row refers to a row that does not exist.
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var index int
+		if err := rows.Scan(&table, &rowID, &parent, &index); err != nil {
+			return err
+		}
+		return &ConstraintError{
+			Kind:   ErrForeignKey,
+			Detail: fmt.Sprintf("row %d of %s refers to a missing row of %s", rowID.Int64, table, parent),
+		}
+	}
+	return rows.Err()
+}
+
+// upgradeLegacySchema adds the columns that databases created before the
+// migrations, by older versions of the program, may lack.
+func upgradeLegacySchema(ctx context.Context, tx *sql.Tx) error {
+	if err := addColumnIfMissing(ctx, tx, "portfolios", "cost_basis_method", "TEXT NOT NULL DEFAULT 'average'"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(ctx, tx, "prices", "source", "TEXT NOT NULL DEFAULT ''")
+}
+
+func addColumnIfMissing(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// migrationLock is the key of the advisory lock held while the migrations of
+// the PostgreSQL database are read or applied.
+const migrationLock = 7_261_003
+
+// lockMigrations returns a connection holding the migration lock, waiting for
+// the program holding it, and the function that releases it. The lock belongs
+// to the session, so a connection that cannot release it is discarded.
+func (repo *PostgresPortfolioRepository) lockMigrations(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := repo.DB.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return conn, unlock, nil
+}
+
+// applyMigration runs the migration on the connection holding the
+// migration lock.
+func (repo *PostgresPortfolioRepository) applyMigration(ctx context.Context, conn *sql.Conn, m migration) (time.Time, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err = tx.ExecContext(ctx, m.SQL); err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+
+	appliedAt := time.Now().UTC().Truncate(time.Second)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+		m.Version, m.Name, appliedAt.Format(time.RFC3339))
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	return appliedAt, nil
+}
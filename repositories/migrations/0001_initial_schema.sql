@@ -0,0 +1,79 @@
+-- The schema of the databases created before migrations were versioned, whose
+-- tables are kept as they are.
+CREATE TABLE IF NOT EXISTS portfolios (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    cost_basis_method TEXT NOT NULL DEFAULT 'average'
+);
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER,
+    symbol TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    buy_date TEXT NOT NULL,
+    buy_price REAL NOT NULL,
+    FOREIGN KEY(portfolio_id) REFERENCES portfolios(id)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    type TEXT NOT NULL,
+    date TEXT NOT NULL,
+    quantity REAL NOT NULL,
+    price REAL NOT NULL,
+    commission REAL NOT NULL DEFAULT 0,
+    FOREIGN KEY(portfolio_id) REFERENCES portfolios(id)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_lots (
+    transaction_id INTEGER NOT NULL,
+    lot_id TEXT NOT NULL,
+    quantity REAL NOT NULL,
+    FOREIGN KEY(transaction_id) REFERENCES transactions(id)
+);
+
+CREATE TABLE IF NOT EXISTS cash_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    date TEXT NOT NULL,
+    amount REAL NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY(portfolio_id) REFERENCES portfolios(id)
+);
+
+CREATE TABLE IF NOT EXISTS prices (
+    symbol TEXT NOT NULL,
+    date TEXT NOT NULL,
+    open REAL NOT NULL,
+    high REAL NOT NULL,
+    low REAL NOT NULL,
+    close REAL NOT NULL,
+    volume INTEGER NOT NULL,
+    fetched_at TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY(symbol, date)
+);
+
+CREATE TABLE IF NOT EXISTS price_ranges (
+    symbol TEXT NOT NULL,
+    from_date TEXT NOT NULL,
+    to_date TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS manual_prices (
+    symbol TEXT NOT NULL,
+    date TEXT NOT NULL,
+    price REAL NOT NULL,
+    PRIMARY KEY(symbol, date)
+);
+
+CREATE TABLE IF NOT EXISTS api_usage (
+    provider TEXT NOT NULL,
+    day TEXT NOT NULL,
+    requests INTEGER NOT NULL,
+    PRIMARY KEY(provider, day)
+);
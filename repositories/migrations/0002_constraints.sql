@@ -0,0 +1,133 @@
+-- Enforces the references between the tables, deleting the rows of a deleted
+-- portfolio or transaction with it, and rejects invalid values. SQLite cannot
+-- add constraints to a table, so every table is rebuilt. Rows whose portfolio
+-- or transaction no longer exists are dropped. Portfolios with an empty or
+-- repeated name are renamed after their ID, until the name is free, and an
+-- unknown cost basis method becomes the average. Stocks older versions saved
+-- with an empty symbol, a quantity that is not positive or a negative price
+-- are moved to dropped_stocks, and the other invalid rows are dropped.
+CREATE TABLE portfolios_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE CHECK(name <> ''),
+    cost_basis_method TEXT NOT NULL DEFAULT 'average'
+        CHECK(cost_basis_method IN ('fifo', 'lifo', 'hifo', 'average', 'specific'))
+);
+WITH RECURSIVE
+kept(id, name) AS (
+    SELECT id, name FROM portfolios p
+    WHERE trim(name) <> ''
+      AND NOT EXISTS (SELECT 1 FROM portfolios other WHERE other.name = p.name AND other.id < p.id)
+),
+renamed(id, name) AS (
+    SELECT id, CASE WHEN trim(name) = '' THEN 'Portfolio' ELSE name END || ' (' || id || ')'
+    FROM portfolios WHERE id NOT IN (SELECT id FROM kept)
+    UNION ALL
+    SELECT id, name || ' (' || id || ')' FROM renamed WHERE name IN (SELECT name FROM kept)
+),
+named(id, name) AS (
+    SELECT id, name FROM kept
+    UNION ALL
+    SELECT id, name FROM renamed WHERE name NOT IN (SELECT name FROM kept)
+)
+INSERT INTO portfolios_new (id, name, cost_basis_method)
+SELECT p.id, named.name,
+       CASE WHEN p.cost_basis_method IN ('fifo', 'lifo', 'hifo', 'average', 'specific')
+            THEN p.cost_basis_method
+            ELSE 'average'
+       END
+FROM portfolios p JOIN named ON named.id = p.id;
+DROP TABLE portfolios;
+ALTER TABLE portfolios_new RENAME TO portfolios;
+
+CREATE TABLE stocks_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    symbol TEXT NOT NULL CHECK(symbol <> ''),
+    quantity INTEGER NOT NULL CHECK(quantity > 0),
+    buy_date TEXT NOT NULL,
+    buy_price REAL NOT NULL CHECK(buy_price >= 0)
+);
+INSERT INTO stocks_new (id, portfolio_id, symbol, quantity, buy_date, buy_price)
+SELECT id, portfolio_id, symbol, quantity, buy_date, buy_price
+FROM stocks
+WHERE portfolio_id IN (SELECT id FROM portfolios)
+  AND symbol <> '' AND quantity > 0 AND buy_price >= 0;
+CREATE TABLE dropped_stocks (
+    id INTEGER PRIMARY KEY,
+    portfolio_id INTEGER,
+    symbol TEXT,
+    quantity INTEGER,
+    buy_date TEXT,
+    buy_price REAL
+);
+INSERT INTO dropped_stocks (id, portfolio_id, symbol, quantity, buy_date, buy_price)
+SELECT id, portfolio_id, symbol, quantity, buy_date, buy_price
+FROM stocks
+WHERE portfolio_id IN (SELECT id FROM portfolios)
+  AND id NOT IN (SELECT id FROM stocks_new);
+DROP TABLE stocks;
+ALTER TABLE stocks_new RENAME TO stocks;
+CREATE INDEX idx_stocks_portfolio_id ON stocks(portfolio_id);
+
+CREATE TABLE transactions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    symbol TEXT NOT NULL,
+    type TEXT NOT NULL CHECK(type IN ('buy', 'sell', 'dividend', 'fee', 'split')),
+    date TEXT NOT NULL,
+    quantity REAL NOT NULL CHECK(quantity >= 0),
+    price REAL NOT NULL CHECK(price >= 0),
+    commission REAL NOT NULL DEFAULT 0 CHECK(commission >= 0)
+);
+INSERT INTO transactions_new (id, portfolio_id, symbol, type, date, quantity, price, commission)
+SELECT id, portfolio_id, symbol, type, date, quantity, price, commission
+FROM transactions
+WHERE portfolio_id IN (SELECT id FROM portfolios)
+  AND type IN ('buy', 'sell', 'dividend', 'fee', 'split')
+  AND quantity >= 0 AND price >= 0 AND commission >= 0;
+DROP TABLE transactions;
+ALTER TABLE transactions_new RENAME TO transactions;
+CREATE INDEX idx_transactions_portfolio_id_date ON transactions(portfolio_id, date);
+
+CREATE TABLE transaction_lots_new (
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    lot_id TEXT NOT NULL,
+    quantity REAL NOT NULL CHECK(quantity > 0)
+);
+INSERT INTO transaction_lots_new (transaction_id, lot_id, quantity)
+SELECT transaction_id, lot_id, quantity
+FROM transaction_lots
+WHERE transaction_id IN (SELECT id FROM transactions) AND quantity > 0;
+DROP TABLE transaction_lots;
+ALTER TABLE transaction_lots_new RENAME TO transaction_lots;
+CREATE INDEX idx_transaction_lots_transaction_id ON transaction_lots(transaction_id);
+
+CREATE TABLE cash_entries_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('deposit', 'withdrawal', 'interest', 'fee')),
+    date TEXT NOT NULL,
+    amount REAL NOT NULL CHECK(amount > 0),
+    description TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO cash_entries_new (id, portfolio_id, type, date, amount, description)
+SELECT id, portfolio_id, type, date, amount, description
+FROM cash_entries
+WHERE portfolio_id IN (SELECT id FROM portfolios)
+  AND type IN ('deposit', 'withdrawal', 'interest', 'fee') AND amount > 0;
+DROP TABLE cash_entries;
+ALTER TABLE cash_entries_new RENAME TO cash_entries;
+CREATE INDEX idx_cash_entries_portfolio_id_date ON cash_entries(portfolio_id, date);
+
+CREATE TABLE manual_prices_new (
+    symbol TEXT NOT NULL,
+    date TEXT NOT NULL,
+    price REAL NOT NULL CHECK(price > 0),
+    PRIMARY KEY(symbol, date)
+);
+INSERT INTO manual_prices_new (symbol, date, price)
+SELECT symbol, date, price FROM manual_prices WHERE price > 0;
+DROP TABLE manual_prices;
+ALTER TABLE manual_prices_new RENAME TO manual_prices;
+
+CREATE INDEX idx_price_ranges_symbol ON price_ranges(symbol, from_date);
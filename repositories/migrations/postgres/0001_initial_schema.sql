@@ -0,0 +1,44 @@
+-- The tables of the portfolios. Prices, manual prices and the usage of the
+-- APIs stay in the SQLite database of each machine.
+CREATE TABLE portfolios (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    cost_basis_method TEXT NOT NULL DEFAULT 'average'
+);
+
+CREATE TABLE stocks (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    portfolio_id INTEGER REFERENCES portfolios(id),
+    symbol TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    buy_date DATE NOT NULL,
+    buy_price DOUBLE PRECISION NOT NULL
+);
+
+CREATE TABLE transactions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id),
+    symbol TEXT NOT NULL,
+    type TEXT NOT NULL,
+    date DATE NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    commission DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+-- Lots keep the order they were selected in by their ID
+CREATE TABLE transaction_lots (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    lot_id TEXT NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL
+);
+
+CREATE TABLE cash_entries (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios(id),
+    type TEXT NOT NULL,
+    date DATE NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,42 @@
+-- Deletes the rows of a deleted portfolio or transaction with it and rejects
+-- invalid values, like the SQLite migration of the same version.
+ALTER TABLE portfolios
+    ADD CONSTRAINT portfolios_name_key UNIQUE (name),
+    ADD CONSTRAINT portfolios_name_check CHECK (name <> ''),
+    ADD CONSTRAINT portfolios_cost_basis_method_check
+        CHECK (cost_basis_method IN ('fifo', 'lifo', 'hifo', 'average', 'specific'));
+
+ALTER TABLE stocks DROP CONSTRAINT stocks_portfolio_id_fkey;
+ALTER TABLE stocks
+    ALTER COLUMN portfolio_id SET NOT NULL,
+    ADD CONSTRAINT stocks_portfolio_id_fkey
+        FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+    ADD CONSTRAINT stocks_symbol_check CHECK (symbol <> ''),
+    ADD CONSTRAINT stocks_quantity_check CHECK (quantity > 0),
+    ADD CONSTRAINT stocks_buy_price_check CHECK (buy_price >= 0);
+CREATE INDEX idx_stocks_portfolio_id ON stocks(portfolio_id);
+
+ALTER TABLE transactions DROP CONSTRAINT transactions_portfolio_id_fkey;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_portfolio_id_fkey
+        FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+    ADD CONSTRAINT transactions_type_check CHECK (type IN ('buy', 'sell', 'dividend', 'fee', 'split')),
+    ADD CONSTRAINT transactions_quantity_check CHECK (quantity >= 0),
+    ADD CONSTRAINT transactions_price_check CHECK (price >= 0),
+    ADD CONSTRAINT transactions_commission_check CHECK (commission >= 0);
+CREATE INDEX idx_transactions_portfolio_id_date ON transactions(portfolio_id, date);
+
+ALTER TABLE transaction_lots DROP CONSTRAINT transaction_lots_transaction_id_fkey;
+ALTER TABLE transaction_lots
+    ADD CONSTRAINT transaction_lots_transaction_id_fkey
+        FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    ADD CONSTRAINT transaction_lots_quantity_check CHECK (quantity > 0);
+CREATE INDEX idx_transaction_lots_transaction_id ON transaction_lots(transaction_id);
+
+ALTER TABLE cash_entries DROP CONSTRAINT cash_entries_portfolio_id_fkey;
+ALTER TABLE cash_entries
+    ADD CONSTRAINT cash_entries_portfolio_id_fkey
+        FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+    ADD CONSTRAINT cash_entries_type_check CHECK (type IN ('deposit', 'withdrawal', 'interest', 'fee')),
+    ADD CONSTRAINT cash_entries_amount_check CHECK (amount > 0);
+CREATE INDEX idx_cash_entries_portfolio_id_date ON cash_entries(portfolio_id, date);
@@ -0,0 +1,312 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSQLitePortfolioRepository_Migrate(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := repo.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from MigrationStatus, got %v", err)
+	}
+	if len(status) == 0 || status[0].Version != 1 || status[0].AppliedAt != nil {
+		t.Fatalf("Expected the initial migration to be pending, got %+v", status)
+	}
+
+	applied, err := repo.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from Migrate, got %v", err)
+	}
+	if len(applied) != len(status) {
+		t.Fatalf("Expected %d migrations to be applied, got %d", len(status), len(applied))
+	}
+
+	// Nothing is left to apply
+	applied, err = repo.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from Migrate, got %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("Expected no migration to be applied again, got %d", len(applied))
+	}
+
+	status, err = repo.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from MigrationStatus, got %v", err)
+	}
+	for _, m := range status {
+		if m.AppliedAt == nil || m.Unknown {
+			t.Errorf("Expected migration %d to be applied, got %+v", m.Version, m)
+		}
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database created before portfolios had a cost basis method
+	_, err = repo.DB.Exec(`CREATE TABLE portfolios (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+        INSERT INTO portfolios (name) VALUES ('Legacy');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Expected no error from Migrate, got %v", err)
+	}
+
+	portfolios, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from GetAll, got %v", err)
+	}
+	if len(portfolios) != 1 || portfolios[0].Name != "Legacy" || portfolios[0].Method() != "average" {
+		t.Fatalf("Expected the legacy portfolio with the average method, got %+v", portfolios)
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateConstraints(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database with a repeated portfolio name and the stocks of a deleted portfolio
+	_, err = repo.DB.Exec(`CREATE TABLE portfolios (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+        CREATE TABLE stocks (id INTEGER PRIMARY KEY AUTOINCREMENT, portfolio_id INTEGER, symbol TEXT NOT NULL,
+            quantity INTEGER NOT NULL, buy_date TEXT NOT NULL, buy_price REAL NOT NULL);
+        INSERT INTO portfolios (name) VALUES ('Savings'), ('Savings');
+        INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES
+            (1, 'AAPL', 10, '2020-01-15', 300), (7, 'MSFT', 5, '2020-01-15', 150);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Expected no error from Migrate, got %v", err)
+	}
+
+	portfolios, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from GetAll, got %v", err)
+	}
+	if len(portfolios) != 2 || portfolios[0].Name != "Savings" || portfolios[1].Name != "Savings (2)" {
+		t.Fatalf("Expected the repeated name to be renamed after its ID, got %+v", portfolios)
+	}
+	if len(portfolios[0].Stocks) != 1 {
+		t.Fatalf("Expected the stock of the first portfolio to be kept, got %+v", portfolios[0].Stocks)
+	}
+
+	var orphans int
+	if err := repo.DB.QueryRow("SELECT COUNT(*) FROM stocks WHERE portfolio_id = 7").Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("Expected the stocks of the deleted portfolio to be dropped, got %d", orphans)
+	}
+
+	var foreignKeys int
+	if err := repo.DB.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatal(err)
+	}
+	if foreignKeys != 1 {
+		t.Error("Expected the foreign keys to be enforced after migrating")
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateInvalidData(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database from before names and prices were validated, where renaming
+	// the repeated name after its ID collides with an existing name
+	_, err = repo.DB.Exec(`CREATE TABLE portfolios (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+        CREATE TABLE stocks (id INTEGER PRIMARY KEY AUTOINCREMENT, portfolio_id INTEGER, symbol TEXT NOT NULL,
+            quantity INTEGER NOT NULL, buy_date TEXT NOT NULL, buy_price REAL NOT NULL);
+        INSERT INTO portfolios (name) VALUES ('Savings'), ('Savings (3)'), ('Savings'), ('');
+        INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES
+            (1, 'AAPL', 10, '2020-01-15', 300), (1, 'MSFT', 5, '2020-01-15', -150), (4, 'TSLA', 0, '2020-01-15', 200);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Expected no error from Migrate, got %v", err)
+	}
+
+	portfolios, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from GetAll, got %v", err)
+	}
+	expected := []string{"Savings", "Savings (3)", "Savings (3) (3)", "Portfolio (4)"}
+	if len(portfolios) != len(expected) {
+		t.Fatalf("Expected %d portfolios, got %+v", len(expected), portfolios)
+	}
+	for i, name := range expected {
+		if portfolios[i].Name != name {
+			t.Errorf("Expected portfolio %d to be named %q, got %q", portfolios[i].ID, name, portfolios[i].Name)
+		}
+	}
+	if len(portfolios[0].Stocks) != 1 || portfolios[0].Stocks[0].Symbol != "AAPL" {
+		t.Errorf("Expected only the valid stock of the first portfolio to be kept, got %+v", portfolios[0].Stocks)
+	}
+
+	var dropped int
+	if err := repo.DB.QueryRow("SELECT COUNT(*) FROM dropped_stocks WHERE symbol IN ('MSFT', 'TSLA')").Scan(&dropped); err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 2 {
+		t.Errorf("Expected the invalid stocks to be moved to dropped_stocks, got %d", dropped)
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Processes starting together on a new database
+	const processes = 4
+	results := make(chan int, processes)
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		repo, err := OpenSQLitePortfolioRepository(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.DB.Close() })
+
+		go func() {
+			applied, err := repo.Migrate(ctx)
+			errs <- err
+			results <- len(applied)
+		}()
+	}
+
+	total := 0
+	for i := 0; i < processes; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected no error from Migrate, got %v", err)
+		}
+		total += <-results
+	}
+
+	migrations, err := loadMigrations(sqliteMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(migrations) {
+		t.Fatalf("Expected each of the %d migrations to be applied once, got %d", len(migrations), total)
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	_, err := repo.DB.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (999, 'future', '2030-01-01T00:00:00Z')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Migrate(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+
+	status, err := repo.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error from MigrationStatus, got %v", err)
+	}
+	last := status[len(status)-1]
+	if last.Version != 999 || !last.Unknown || last.AppliedAt == nil {
+		t.Fatalf("Expected the unknown migration 999 to be listed as applied, got %+v", last)
+	}
+}
+
+func TestSQLitePortfolioRepository_MigrateRollback(t *testing.T) {
+	ctx := context.Background()
+	embedded, hooks := migrationFiles, migrationHooks
+	t.Cleanup(func() { migrationFiles, migrationHooks = embedded, hooks })
+	migrationHooks = nil
+	migrationFiles = fstest.MapFS{
+		"migrations/0001_first.sql":  {Data: []byte("CREATE TABLE first (id INTEGER);")},
+		"migrations/0002_broken.sql": {Data: []byte("CREATE TABLE second (id INTEGER); INSERT INTO missing VALUES (1);")},
+	}
+
+	repo, err := OpenSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := repo.Migrate(ctx)
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Fatalf("Expected only the first migration to be applied, got %+v", applied)
+	}
+
+	var count int
+	err = repo.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'second'").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("Expected the table of the failed migration to be rolled back")
+	}
+}
+
+func TestPostgresPortfolioRepository_MigrateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	databaseURL := testPostgresSchema(t)
+
+	// Programs starting together on a database without schema_migrations
+	const programs = 4
+	results := make(chan int, programs)
+	errs := make(chan error, programs)
+	for i := 0; i < programs; i++ {
+		repo, err := OpenPostgresPortfolioRepository(databaseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.DB.Close() })
+
+		go func() {
+			applied, err := repo.Migrate(ctx)
+			errs <- err
+			results <- len(applied)
+		}()
+	}
+
+	total := 0
+	for i := 0; i < programs; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected no error from Migrate, got %v", err)
+		}
+		total += <-results
+	}
+
+	migrations, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(migrations) {
+		t.Fatalf("Expected each of the %d migrations to be applied once, got %d", len(migrations), total)
+	}
+}
@@ -1,11 +1,19 @@
 package repositories
 
-import "github.com/fcopulgar/stock-manager-go/models"
+import (
+	"context"
+	"github.com/fcopulgar/stock-manager-go/models"
+)
 
 type PortfolioRepository interface {
-	GetAll() ([]models.Portfolio, error)
-	GetByID(id int) (*models.Portfolio, error)
-	Save(portfolio *models.Portfolio) error
-	Update(portfolio *models.Portfolio) error
-	Delete(id int) error
+	GetAll(ctx context.Context) ([]models.Portfolio, error)
+	List(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error)
+	// GetByID, Update and Delete return an error wrapping models.ErrNotFound
+	// when the portfolio does not exist.
+	GetByID(ctx context.Context, id int) (*models.Portfolio, error)
+	Save(ctx context.Context, portfolio *models.Portfolio) error
+	Update(ctx context.Context, portfolio *models.Portfolio) error
+	Delete(ctx context.Context, id int) error
+	TransactionRepository
+	CashRepository
 }
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *PostgresPortfolioRepository) GetCashEntries(ctx context.Context, portfolioID int) ([]models.CashEntry, error) {
+	entries := []models.CashEntry{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, type, date, amount, description FROM cash_entries WHERE portfolio_id = $1 ORDER BY date, id",
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.CashEntry
+		var entryType string
+
+		err := rows.Scan(&entry.ID, &entry.PortfolioID, &entryType, &entry.Date, &entry.Amount, &entry.Description)
+		if err != nil {
+			return nil, err
+		}
+		entry.Type = models.CashEntryType(entryType)
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (repo *PostgresPortfolioRepository) SaveCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	err := repo.DB.QueryRowContext(ctx,
+		"INSERT INTO cash_entries (portfolio_id, type, date, amount, description) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		entry.PortfolioID, string(entry.Type), entry.Date.Format("2006-01-02"), entry.Amount, entry.Description,
+	).Scan(&entry.ID)
+	return constraintError(err)
+}
+
+func (repo *PostgresPortfolioRepository) DeleteCashEntry(ctx context.Context, id int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM cash_entries WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return notFound(res, "cash entry", id)
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *PostgresPortfolioRepository) GetTransactions(ctx context.Context, portfolioID int) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, symbol, type, date, quantity, price, commission FROM transactions WHERE portfolio_id = $1 ORDER BY date, id",
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		var transactionType string
+
+		err := rows.Scan(&transaction.ID, &transaction.PortfolioID, &transaction.Symbol, &transactionType,
+			&transaction.Date, &transaction.Quantity, &transaction.Price, &transaction.Commission)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Type = models.TransactionType(transactionType)
+
+		transactions = append(transactions, transaction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	lots, err := repo.getLotSelectionsByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range transactions {
+		transactions[i].Lots = lots[transactions[i].ID]
+	}
+
+	return transactions, nil
+}
+
+func (repo *PostgresPortfolioRepository) SaveTransaction(ctx context.Context, transaction *models.Transaction) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO transactions (portfolio_id, symbol, type, date, quantity, price, commission) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		transaction.PortfolioID, transaction.Symbol, string(transaction.Type), transaction.Date.Format("2006-01-02"),
+		transaction.Quantity, transaction.Price, transaction.Commission,
+	).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return constraintError(err)
+	}
+
+	for _, lot := range transaction.Lots {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO transaction_lots (transaction_id, lot_id, quantity) VALUES ($1, $2, $3)",
+			id, lot.LotID, lot.Quantity,
+		)
+		if err != nil {
+			tx.Rollback()
+			return constraintError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	transaction.ID = id
+
+	return nil
+}
+
+// DeleteTransaction removes the transaction, and the database removes its lot
+// selections with it.
+func (repo *PostgresPortfolioRepository) DeleteTransaction(ctx context.Context, id int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM transactions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return notFound(res, "transaction", id)
+}
+
+// getLotSelectionsByPortfolioID returns the lots selected by the sells of a portfolio, keyed by transaction ID.
+func (repo *PostgresPortfolioRepository) getLotSelectionsByPortfolioID(ctx context.Context, portfolioID int) (map[int][]models.LotSelection, error) {
+	lots := make(map[int][]models.LotSelection)
+
+	rows, err := repo.DB.QueryContext(ctx,
+		`SELECT tl.transaction_id, tl.lot_id, tl.quantity FROM transaction_lots tl
+        JOIN transactions t ON t.id = tl.transaction_id
+        WHERE t.portfolio_id = $1 ORDER BY tl.id`,
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transactionID int
+		var lot models.LotSelection
+		if err := rows.Scan(&transactionID, &lot.LotID, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		lots[transactionID] = append(lots[transactionID], lot)
+	}
+
+	return lots, rows.Err()
+}
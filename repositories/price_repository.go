@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// PriceRepository stores the bars fetched from a price provider.
+type PriceRepository interface {
+	GetCachedPrices(ctx context.Context, symbol string, from, to time.Time) ([]models.CachedPriceBar, error)
+	// GetLastCachedPrice returns the most recent bar of the symbol on or before
+	// the date, or nil when there is none.
+	GetLastCachedPrice(ctx context.Context, symbol string, date time.Time) (*models.CachedPriceBar, error)
+	SaveCachedPrices(ctx context.Context, bars []models.CachedPriceBar) error
+	GetCachedRanges(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceRange, error)
+	// SaveCachedRange merges the range with the stored ranges of the symbol
+	// it overlaps or is next to.
+	SaveCachedRange(ctx context.Context, priceRange models.PriceRange) error
+	// DeleteCachedPrices removes the bars and ranges of the symbol, or of every
+	// symbol when it is empty.
+	DeleteCachedPrices(ctx context.Context, symbol string) error
+}
+
+// ManualPriceRepository stores the prices entered by hand.
+type ManualPriceRepository interface {
+	// GetManualPrice returns the price of the symbol on the date, or nil when
+	// none was entered.
+	GetManualPrice(ctx context.Context, symbol string, date time.Time) (*models.ManualPrice, error)
+	// GetManualPrices returns the prices of the symbol, or of every symbol when
+	// it is empty, ordered by symbol and date.
+	GetManualPrices(ctx context.Context, symbol string) ([]models.ManualPrice, error)
+	SaveManualPrice(ctx context.Context, price models.ManualPrice) error
+	DeleteManualPrice(ctx context.Context, symbol string, date time.Time) error
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (repo *SQLitePortfolioRepository) GetAPIUsage(ctx context.Context, provider string, day time.Time) (int, error) {
+	var requests int
+	err := repo.DB.QueryRowContext(ctx,
+		"SELECT requests FROM api_usage WHERE provider = ? AND day = ?",
+		provider, day.Format("2006-01-02"),
+	).Scan(&requests)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return requests, err
+}
+
+func (repo *SQLitePortfolioRepository) IncrementAPIUsage(ctx context.Context, provider string, day time.Time) (int, error) {
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO api_usage (provider, day, requests) VALUES (?, ?, 1) ON CONFLICT(provider, day) DO UPDATE SET requests = requests + 1",
+		provider, day.Format("2006-01-02"),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return repo.GetAPIUsage(ctx, provider, day)
+}
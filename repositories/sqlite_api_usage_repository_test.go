@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLitePortfolioRepository_APIUsage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	repo := NewSQLitePortfolioRepository(path)
+
+	day := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 2; i++ {
+		requests, err := repo.IncrementAPIUsage(ctx, "fmp", day)
+		if err != nil {
+			t.Fatalf("Expected no error from IncrementAPIUsage, got %v", err)
+		}
+		if requests != i {
+			t.Errorf("Expected %d requests, got %d", i, requests)
+		}
+	}
+	repo.DB.Close()
+
+	// The usage is kept across runs and counted per provider and day
+	repo = NewSQLitePortfolioRepository(path)
+	defer repo.DB.Close()
+	if requests, err := repo.GetAPIUsage(ctx, "fmp", day); err != nil || requests != 2 {
+		t.Errorf("Expected 2 requests after reopening, got %d and %v", requests, err)
+	}
+	if requests, err := repo.GetAPIUsage(ctx, "fmp", day.AddDate(0, 0, 1)); err != nil || requests != 0 {
+		t.Errorf("Expected no requests the next day, got %d and %v", requests, err)
+	}
+}
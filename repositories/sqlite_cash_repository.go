@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *SQLitePortfolioRepository) GetCashEntries(ctx context.Context, portfolioID int) ([]models.CashEntry, error) {
+	entries := []models.CashEntry{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, type, date, amount, description FROM cash_entries WHERE portfolio_id = ? ORDER BY date, id",
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.CashEntry
+		var entryType, dateStr string
+
+		err := rows.Scan(&entry.ID, &entry.PortfolioID, &entryType, &dateStr, &entry.Amount, &entry.Description)
+		if err != nil {
+			return nil, err
+		}
+		entry.Type = models.CashEntryType(entryType)
+
+		entry.Date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (repo *SQLitePortfolioRepository) SaveCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	res, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO cash_entries (portfolio_id, type, date, amount, description) VALUES (?, ?, ?, ?, ?)",
+		entry.PortfolioID, string(entry.Type), entry.Date.Format("2006-01-02"), entry.Amount, entry.Description,
+	)
+	if err != nil {
+		return constraintError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+
+	return nil
+}
+
+func (repo *SQLitePortfolioRepository) DeleteCashEntry(ctx context.Context, id int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM cash_entries WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return notFound(res, "cash entry", id)
+}
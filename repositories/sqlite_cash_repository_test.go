@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func TestSQLitePortfolioRepository_CashEntries(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	err := repo.Save(ctx, &models.Portfolio{Name: "Cash Portfolio"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	withdrawal := &models.CashEntry{
+		PortfolioID: 1,
+		Type:        models.CashWithdrawal,
+		Date:        time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		Amount:      250,
+		Description: "Rent",
+	}
+	deposit := &models.CashEntry{
+		PortfolioID: 1,
+		Type:        models.CashDeposit,
+		Date:        time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC),
+		Amount:      1000,
+	}
+
+	for _, entry := range []*models.CashEntry{withdrawal, deposit} {
+		if err := repo.SaveCashEntry(ctx, entry); err != nil {
+			t.Fatalf("Expected no error from SaveCashEntry, got %v", err)
+		}
+		if entry.ID == 0 {
+			t.Fatal("Expected SaveCashEntry to assign an ID")
+		}
+	}
+
+	// Entries are returned in chronological order
+	entries, err := repo.GetCashEntries(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error from GetCashEntries, got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 cash entries, got %d", len(entries))
+	}
+	if entries[0].Type != models.CashDeposit || entries[1].Type != models.CashWithdrawal {
+		t.Errorf("Expected deposit then withdrawal, got %s then %s", entries[0].Type, entries[1].Type)
+	}
+	if entries[1].Amount != 250 || entries[1].Description != "Rent" {
+		t.Errorf("Expected amount 250 and description Rent, got %v and %q", entries[1].Amount, entries[1].Description)
+	}
+
+	if err := repo.DeleteCashEntry(ctx, deposit.ID); err != nil {
+		t.Fatalf("Expected no error from DeleteCashEntry, got %v", err)
+	}
+
+	// Deleting the portfolio deletes its cash entries
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Expected no error from Delete, got %v", err)
+	}
+	entries, err = repo.GetCashEntries(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error from GetCashEntries, got %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no cash entries after deleting the portfolio, got %d", len(entries))
+	}
+}
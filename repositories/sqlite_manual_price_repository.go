@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *SQLitePortfolioRepository) GetManualPrice(ctx context.Context, symbol string, date time.Time) (*models.ManualPrice, error) {
+	price := models.ManualPrice{Symbol: symbol, Date: date}
+
+	err := repo.DB.QueryRowContext(ctx,
+		"SELECT price FROM manual_prices WHERE symbol = ? AND date = ?",
+		symbol, date.Format("2006-01-02"),
+	).Scan(&price.Price)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No price entered
+		}
+		return nil, err
+	}
+
+	return &price, nil
+}
+
+func (repo *SQLitePortfolioRepository) GetManualPrices(ctx context.Context, symbol string) ([]models.ManualPrice, error) {
+	prices := []models.ManualPrice{}
+
+	query := "SELECT symbol, date, price FROM manual_prices"
+	var args []interface{}
+	if symbol != "" {
+		query += " WHERE symbol = ?"
+		args = append(args, symbol)
+	}
+	rows, err := repo.DB.QueryContext(ctx, query+" ORDER BY symbol, date", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var price models.ManualPrice
+		var dateStr string
+
+		if err := rows.Scan(&price.Symbol, &dateStr, &price.Price); err != nil {
+			return nil, err
+		}
+
+		price.Date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, err
+		}
+
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// SaveManualPrice inserts the price, replacing the one already entered for the same symbol and date.
+func (repo *SQLitePortfolioRepository) SaveManualPrice(ctx context.Context, price models.ManualPrice) error {
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT OR REPLACE INTO manual_prices (symbol, date, price) VALUES (?, ?, ?)",
+		price.Symbol, price.Date.Format("2006-01-02"), price.Price,
+	)
+	return constraintError(err)
+}
+
+func (repo *SQLitePortfolioRepository) DeleteManualPrice(ctx context.Context, symbol string, date time.Time) error {
+	res, err := repo.DB.ExecContext(ctx,
+		"DELETE FROM manual_prices WHERE symbol = ? AND date = ?",
+		symbol, date.Format("2006-01-02"),
+	)
+	if err != nil {
+		return err
+	}
+	return notFound(res, "manual price of "+symbol+" on", date.Format("2006-01-02"))
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func TestSQLitePortfolioRepository_ManualPrices(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	day := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, price := range []models.ManualPrice{
+		{Symbol: "MSFT", Date: day, Price: 212.65},
+		{Symbol: "AAPL", Date: day, Price: 127.14},
+		{Symbol: "AAPL", Date: day, Price: 127.5},
+	} {
+		if err := repo.SaveManualPrice(ctx, price); err != nil {
+			t.Fatalf("Expected no error from SaveManualPrice, got %v", err)
+		}
+	}
+
+	price, err := repo.GetManualPrice(ctx, "AAPL", day)
+	if err != nil {
+		t.Fatalf("Expected no error from GetManualPrice, got %v", err)
+	}
+	if price == nil || price.Price != 127.5 {
+		t.Fatalf("Expected the replaced price 127.5, got %+v", price)
+	}
+
+	prices, err := repo.GetManualPrices(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error from GetManualPrices, got %v", err)
+	}
+	if len(prices) != 2 || prices[0].Symbol != "AAPL" || prices[1].Symbol != "MSFT" {
+		t.Errorf("Expected the AAPL and MSFT prices, got %+v", prices)
+	}
+
+	if err := repo.DeleteManualPrice(ctx, "AAPL", day); err != nil {
+		t.Fatalf("Expected no error from DeleteManualPrice, got %v", err)
+	}
+	price, err = repo.GetManualPrice(ctx, "AAPL", day)
+	if err != nil || price != nil {
+		t.Errorf("Expected no AAPL price after deleting it, got %+v and %v", price, err)
+	}
+}
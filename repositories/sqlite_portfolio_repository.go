@@ -1,8 +1,12 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/fcopulgar/stock-manager-go/models"
@@ -14,88 +18,167 @@ type SQLitePortfolioRepository struct {
 	DB *sql.DB
 }
 
+// NewSQLitePortfolioRepository opens the database and migrates its schema to
+// the latest version.
 func NewSQLitePortfolioRepository(dbPath string) *SQLitePortfolioRepository {
-	db, err := sql.Open("sqlite3", dbPath)
+	repo, err := OpenSQLitePortfolioRepository(dbPath)
 	if err != nil {
 		log.Fatalf("Error opening the database: %v", err)
 	}
 
-	repo := &SQLitePortfolioRepository{DB: db}
-	repo.createTables()
+	if _, err := repo.Migrate(context.Background()); err != nil {
+		log.Fatalf("Error migrating the database: %v", err)
+	}
 	return repo
 }
 
-func (repo *SQLitePortfolioRepository) createTables() {
-	portfolioTable := `CREATE TABLE IF NOT EXISTS portfolios (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL
-    );`
-
-	stockTable := `CREATE TABLE IF NOT EXISTS stocks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        portfolio_id INTEGER,
-        symbol TEXT NOT NULL,
-        quantity INTEGER NOT NULL,
-        buy_date TEXT NOT NULL,
-        buy_price REAL NOT NULL,
-        FOREIGN KEY(portfolio_id) REFERENCES portfolios(id)
-    );`
-
-	_, err := repo.DB.Exec(portfolioTable)
+// OpenSQLitePortfolioRepository opens the database without migrating it.
+func OpenSQLitePortfolioRepository(dbPath string) (*SQLitePortfolioRepository, error) {
+	db, err := sql.Open("sqlite3", dataSourceName(dbPath))
 	if err != nil {
-		log.Fatalf("Error creating the portfolios table: %v", err)
+		return nil, err
 	}
+	return &SQLitePortfolioRepository{DB: db}, nil
+}
+
+// dataSourceName enforces the foreign keys on every connection, and enables
+// the write-ahead log and a busy timeout so that a reader and a writer, or two
+// processes, do not fail with "database is locked". Write transactions take
+// the lock when they begin instead of failing when they first write.
+func dataSourceName(dbPath string) string {
+	return "file:" + dbPath + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+}
+
+func (repo *SQLitePortfolioRepository) GetAll(ctx context.Context) ([]models.Portfolio, error) {
+	return repo.List(ctx, models.PortfolioQuery{})
+}
 
-	_, err = repo.DB.Exec(stockTable)
+// List returns the page of portfolios selected by the query with their stocks.
+// The portfolios and the stocks of all of them are read with one query each.
+func (repo *SQLitePortfolioRepository) List(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error) {
+	selection, args, err := portfolioSelection(query)
 	if err != nil {
-		log.Fatalf("Error when creating the stocks table: %v", err)
+		return nil, err
 	}
-}
 
-func (repo *SQLitePortfolioRepository) GetAll() ([]models.Portfolio, error) {
 	portfolios := []models.Portfolio{}
-
-	rows, err := repo.DB.Query("SELECT id, name FROM portfolios")
+	rows, err := repo.DB.QueryContext(ctx, "SELECT id, name, cost_basis_method "+selection, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	indexes := make(map[int]int)
 	for rows.Next() {
 		var portfolio models.Portfolio
-		err := rows.Scan(&portfolio.ID, &portfolio.Name)
+		var method string
+		err := rows.Scan(&portfolio.ID, &portfolio.Name, &method)
 		if err != nil {
 			return nil, err
 		}
 
-		stocks, err := repo.getStocksByPortfolioID(portfolio.ID)
-		if err != nil {
-			return nil, err
-		}
-		portfolio.Stocks = stocks
+		portfolio.CostBasisMethod = models.CostBasisMethod(method)
+		portfolio.Stocks = []models.Stock{}
 
+		indexes[portfolio.ID] = len(portfolios)
 		portfolios = append(portfolios, portfolio)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	if len(portfolios) == 0 {
+		return portfolios, nil
+	}
+
+	stocks, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, symbol, quantity, buy_date, buy_price FROM stocks WHERE portfolio_id IN (SELECT id "+selection+") ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer stocks.Close()
+
+	for stocks.Next() {
+		var portfolioID int
+		stock, err := scanStock(stocks, &portfolioID)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := indexes[portfolioID]; ok {
+			portfolios[i].Stocks = append(portfolios[i].Stocks, stock)
+		}
+	}
+
+	if err = stocks.Err(); err != nil {
+		return nil, err
+	}
 
 	return portfolios, nil
 }
 
-func (repo *SQLitePortfolioRepository) GetByID(id int) (*models.Portfolio, error) {
+// portfolioSelection returns the FROM clause, and its arguments, selecting
+// the portfolios of the query in order. Its SQL works in SQLite and, with the
+// placeholders numbered, in PostgreSQL.
+func portfolioSelection(query models.PortfolioQuery) (string, []any, error) {
+	if query.Limit < 0 || query.Offset < 0 {
+		return "", nil, fmt.Errorf("invalid page with limit %d and offset %d", query.Limit, query.Offset)
+	}
+
+	var order string
+	switch query.Sort {
+	case "", models.PortfolioSortID:
+		order = "id"
+	case models.PortfolioSortName:
+		order = "lower(name)"
+	default:
+		return "", nil, fmt.Errorf("unknown portfolio sort %q", query.Sort)
+	}
+	direction := "ASC"
+	if query.Descending {
+		direction = "DESC"
+	}
+
+	selection := "FROM portfolios"
+	var args []any
+	if query.Name != "" {
+		selection += ` WHERE lower(name) LIKE lower(?) ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(query.Name)+"%")
+	}
+	selection += fmt.Sprintf(" ORDER BY %s %s, id %s", order, direction, direction)
+
+	// An offset needs a limit, the largest one when there is none
+	if query.Limit > 0 || query.Offset > 0 {
+		limit := int64(query.Limit)
+		if limit == 0 {
+			limit = math.MaxInt64
+		}
+		selection += " LIMIT ? OFFSET ?"
+		args = append(args, limit, query.Offset)
+	}
+	return selection, args, nil
+}
+
+// likeEscaper makes the wildcards of a LIKE pattern match themselves.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (repo *SQLitePortfolioRepository) GetByID(ctx context.Context, id int) (*models.Portfolio, error) {
 	var portfolio models.Portfolio
+	var method string
 
-	err := repo.DB.QueryRow("SELECT id, name FROM portfolios WHERE id = ?", id).Scan(&portfolio.ID, &portfolio.Name)
+	err := repo.DB.QueryRowContext(ctx, "SELECT id, name, cost_basis_method FROM portfolios WHERE id = ?", id).Scan(&portfolio.ID, &portfolio.Name, &method)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // Portfolio not found
+			return nil, fmt.Errorf("portfolio %d %w", id, models.ErrNotFound)
 		}
 		return nil, err
 	}
+	portfolio.CostBasisMethod = models.CostBasisMethod(method)
 
-	stocks, err := repo.getStocksByPortfolioID(portfolio.ID)
+	stocks, err := repo.getStocksByPortfolioID(ctx, portfolio.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -104,16 +187,16 @@ func (repo *SQLitePortfolioRepository) GetByID(id int) (*models.Portfolio, error
 	return &portfolio, nil
 }
 
-func (repo *SQLitePortfolioRepository) Save(portfolio *models.Portfolio) error {
-	tx, err := repo.DB.Begin()
+func (repo *SQLitePortfolioRepository) Save(ctx context.Context, portfolio *models.Portfolio) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	res, err := tx.Exec("INSERT INTO portfolios (name) VALUES (?)", portfolio.Name)
+	res, err := tx.ExecContext(ctx, "INSERT INTO portfolios (name, cost_basis_method) VALUES (?, ?)", portfolio.Name, string(portfolio.Method()))
 	if err != nil {
 		tx.Rollback()
-		return err
+		return constraintError(err)
 	}
 
 	portfolioID, err := res.LastInsertId()
@@ -123,77 +206,110 @@ func (repo *SQLitePortfolioRepository) Save(portfolio *models.Portfolio) error {
 	}
 
 	for _, stock := range portfolio.Stocks {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES (?, ?, ?, ?, ?)",
 			portfolioID, stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"), stock.BuyPrice,
 		)
 		if err != nil {
 			tx.Rollback()
-			return err
+			return constraintError(err)
 		}
 	}
 
 	return tx.Commit()
 }
 
-func (repo *SQLitePortfolioRepository) Update(portfolio *models.Portfolio) error {
-	tx, err := repo.DB.Begin()
+func (repo *SQLitePortfolioRepository) Update(ctx context.Context, portfolio *models.Portfolio) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE portfolios SET name = ? WHERE id = ?", portfolio.Name, portfolio.ID)
+	res, err := tx.ExecContext(ctx, "UPDATE portfolios SET name = ?, cost_basis_method = ? WHERE id = ?", portfolio.Name, string(portfolio.Method()), portfolio.ID)
 	if err != nil {
+		tx.Rollback()
+		return constraintError(err)
+	}
+	if err := notFound(res, "portfolio", portfolio.ID); err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	_, err = tx.Exec("DELETE FROM stocks WHERE portfolio_id = ?", portfolio.ID)
+	// Stocks keep their IDs across updates, since sells may refer to them as lots
+	kept := make(map[int]bool)
+	for _, stock := range portfolio.Stocks {
+		if stock.ID != 0 {
+			kept[stock.ID] = true
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM stocks WHERE portfolio_id = ?", portfolio.ID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
+	var removed []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		if !kept[id] {
+			removed = append(removed, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	for _, stock := range portfolio.Stocks {
-		_, err = tx.Exec(
-			"INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES (?, ?, ?, ?, ?)",
-			portfolio.ID, stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"), stock.BuyPrice,
-		)
+	for _, id := range removed {
+		_, err = tx.ExecContext(ctx, "DELETE FROM stocks WHERE id = ?", id)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
 	}
 
-	return tx.Commit()
-}
-
-func (repo *SQLitePortfolioRepository) Delete(id int) error {
-	tx, err := repo.DB.Begin()
-	if err != nil {
-		return err
+	for _, stock := range portfolio.Stocks {
+		if stock.ID != 0 {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE stocks SET symbol = ?, quantity = ?, buy_date = ?, buy_price = ? WHERE id = ? AND portfolio_id = ?",
+				stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"), stock.BuyPrice, stock.ID, portfolio.ID,
+			)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES (?, ?, ?, ?, ?)",
+				portfolio.ID, stock.Symbol, stock.Quantity, stock.BuyDate.Format("2006-01-02"), stock.BuyPrice,
+			)
+		}
+		if err != nil {
+			tx.Rollback()
+			return constraintError(err)
+		}
 	}
 
-	_, err = tx.Exec("DELETE FROM stocks WHERE portfolio_id = ?", id)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	return tx.Commit()
+}
 
-	_, err = tx.Exec("DELETE FROM portfolios WHERE id = ?", id)
+// Delete removes the portfolio, and the database removes its stocks,
+// transactions and cash entries with it.
+func (repo *SQLitePortfolioRepository) Delete(ctx context.Context, id int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM portfolios WHERE id = ?", id)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-
-	return tx.Commit()
+	return notFound(res, "portfolio", id)
 }
 
-func (repo *SQLitePortfolioRepository) getStocksByPortfolioID(portfolioID int) ([]models.Stock, error) {
+func (repo *SQLitePortfolioRepository) getStocksByPortfolioID(ctx context.Context, portfolioID int) ([]models.Stock, error) {
 	stocks := []models.Stock{}
 
-	rows, err := repo.DB.Query(
-		"SELECT id, symbol, quantity, buy_date, buy_price FROM stocks WHERE portfolio_id = ?",
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, symbol, quantity, buy_date, buy_price FROM stocks WHERE portfolio_id = ? ORDER BY id",
 		portfolioID,
 	)
 	if err != nil {
@@ -202,19 +318,11 @@ func (repo *SQLitePortfolioRepository) getStocksByPortfolioID(portfolioID int) (
 	defer rows.Close()
 
 	for rows.Next() {
-		var stock models.Stock
-		var buyDateStr string
-
-		err := rows.Scan(&stock.ID, &stock.Symbol, &stock.Quantity, &buyDateStr, &stock.BuyPrice)
+		var stockPortfolioID int
+		stock, err := scanStock(rows, &stockPortfolioID)
 		if err != nil {
 			return nil, err
 		}
-
-		stock.BuyDate, err = time.Parse("2006-01-02", buyDateStr)
-		if err != nil {
-			return nil, err
-		}
-
 		stocks = append(stocks, stock)
 	}
 
@@ -224,3 +332,18 @@ func (repo *SQLitePortfolioRepository) getStocksByPortfolioID(portfolioID int) (
 
 	return stocks, nil
 }
+
+// scanStock reads a row of id, portfolio_id, symbol, quantity, buy_date and
+// buy_price into a stock and the ID of its portfolio.
+func scanStock(rows *sql.Rows, portfolioID *int) (models.Stock, error) {
+	var stock models.Stock
+	var buyDateStr string
+
+	err := rows.Scan(&stock.ID, portfolioID, &stock.Symbol, &stock.Quantity, &buyDateStr, &stock.BuyPrice)
+	if err != nil {
+		return stock, err
+	}
+
+	stock.BuyDate, err = time.Parse("2006-01-02", buyDateStr)
+	return stock, err
+}
@@ -1,9 +1,13 @@
 package repositories
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 )
 
 func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
+	ctx := context.Background()
 	tempDir, err := ioutil.TempDir("", "testdb")
 	if err != nil {
 		t.Fatal(err)
@@ -34,7 +39,7 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 	}
 
 	// Test Save
-	err = repo.Save(portfolio)
+	err = repo.Save(ctx, portfolio)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -44,7 +49,7 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 	// You could modify Save to assign the ID to the object or just not check the ID here.
 
 	// Test GetAll (should return 1 portfolio)
-	portfolios, err := repo.GetAll()
+	portfolios, err := repo.GetAll(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error from GetAll, got %v", err)
 	}
@@ -63,7 +68,7 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 
 	// Test GetByID
 	// We know that the only portfolio saved has ID 1, because AUTOINCREMENT starts at 1.
-	p, err := repo.GetByID(1)
+	p, err := repo.GetByID(ctx, 1)
 	if err != nil {
 		t.Fatalf("Expected no error from GetByID, got %v", err)
 	}
@@ -77,13 +82,13 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 	// Test Update
 	p.Name = "Updated Portfolio Name"
 	p.Stocks[0].Quantity = 20
-	err = repo.Update(p)
+	err = repo.Update(ctx, p)
 	if err != nil {
 		t.Fatalf("Expected no error from Update, got %v", err)
 	}
 
 	// Verify the update
-	updated, err := repo.GetByID(1)
+	updated, err := repo.GetByID(ctx, 1)
 	if err != nil {
 		t.Fatalf("Expected no error from GetByID after update, got %v", err)
 	}
@@ -94,22 +99,59 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 		t.Errorf("Expected updated quantity 20, got %d", updated.Stocks[0].Quantity)
 	}
 
+	// Update keeps the IDs of the existing stocks, which sells refer to as lots
+	if updated.Stocks[0].ID != p.Stocks[0].ID {
+		t.Errorf("Expected stock ID %d to be kept, got %d", p.Stocks[0].ID, updated.Stocks[0].ID)
+	}
+	if updated.CostBasisMethod != models.CostBasisAverage {
+		t.Errorf("Expected the default cost basis method, got '%s'", updated.CostBasisMethod)
+	}
+
+	updated.CostBasisMethod = models.CostBasisHIFO
+	updated.Stocks = append(updated.Stocks, models.Stock{
+		Symbol:   "MSFT",
+		Quantity: 5,
+		BuyDate:  time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC),
+		BuyPrice: 170.0,
+	})
+	err = repo.Update(ctx, updated)
+	if err != nil {
+		t.Fatalf("Expected no error from Update, got %v", err)
+	}
+	updated, err = repo.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error from GetByID after update, got %v", err)
+	}
+	if len(updated.Stocks) != 2 || updated.Stocks[0].ID != p.Stocks[0].ID {
+		t.Fatalf("Expected the existing stock to be kept and a new one added, got %+v", updated.Stocks)
+	}
+	if updated.CostBasisMethod != models.CostBasisHIFO {
+		t.Errorf("Expected cost basis method 'hifo', got '%s'", updated.CostBasisMethod)
+	}
+
 	// Test Delete
-	err = repo.Delete(1)
+	err = repo.Delete(ctx, 1)
 	if err != nil {
 		t.Fatalf("Expected no error from Delete, got %v", err)
 	}
 
-	deleted, err := repo.GetByID(1)
-	if err != nil {
-		t.Fatalf("Expected no error from GetByID after delete, got %v", err)
+	deleted, err := repo.GetByID(ctx, 1)
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from GetByID after delete, got %v", err)
 	}
 	if deleted != nil {
 		t.Fatal("Expected nil after delete, got a portfolio")
 	}
 
+	if err := repo.Delete(ctx, 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from deleting it again, got %v", err)
+	}
+	if err := repo.Update(ctx, &models.Portfolio{ID: 1, Name: "Gone"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from updating it, got %v", err)
+	}
+
 	// Verify that GetAll returns 0 portfolios now
-	portfolios, err = repo.GetAll()
+	portfolios, err = repo.GetAll(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error from GetAll after delete, got %v", err)
 	}
@@ -117,3 +159,208 @@ func TestSQLitePortfolioRepository_CRUD(t *testing.T) {
 		t.Fatalf("Expected 0 portfolios after delete, got %d", len(portfolios))
 	}
 }
+
+func TestSQLitePortfolioRepository_Canceled(t *testing.T) {
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := repo.Save(ctx, &models.Portfolio{Name: "Canceled"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected Save to be canceled, got %v", err)
+	}
+	portfolios, err := repo.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error from GetAll, got %v", err)
+	}
+	if len(portfolios) != 0 {
+		t.Fatalf("Expected the canceled portfolio not to be saved, got %d portfolios", len(portfolios))
+	}
+}
+
+func TestSQLitePortfolioRepository_Constraints(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	portfolio := &models.Portfolio{Name: "Growth"}
+	if err := repo.Save(ctx, portfolio); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err := repo.Save(ctx, &models.Portfolio{Name: "Growth"})
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) || !errors.Is(err, ErrDuplicate) || !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("Expected a duplicate name error, got %v", err)
+	}
+
+	err = repo.Save(ctx, &models.Portfolio{Name: "Invalid", Stocks: []models.Stock{
+		{Symbol: "AAPL", Quantity: -1, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 300},
+	}})
+	if !errors.Is(err, ErrCheckFailed) || !errors.Is(err, models.ErrValidation) {
+		t.Fatalf("Expected an invalid quantity error, got %v", err)
+	}
+
+	err = repo.SaveTransaction(ctx, &models.Transaction{
+		PortfolioID: 999, Symbol: "AAPL", Type: models.TransactionBuy,
+		Date: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), Quantity: 10, Price: 300,
+	})
+	if !errors.Is(err, ErrForeignKey) {
+		t.Fatalf("Expected a missing portfolio error, got %v", err)
+	}
+
+	var journalMode string
+	if err := repo.DB.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatal(err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("Expected the WAL journal mode, got %q", journalMode)
+	}
+}
+
+func TestSQLitePortfolioRepository_DeleteCascade(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	day := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	if err := repo.Save(ctx, &models.Portfolio{Name: "Growth", Stocks: []models.Stock{
+		{Symbol: "AAPL", Quantity: 10, BuyDate: day, BuyPrice: 300},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	transaction := &models.Transaction{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: day,
+		Quantity: 5, Price: 310, Lots: []models.LotSelection{{LotID: "1", Quantity: 5}}}
+	if err := repo.SaveTransaction(ctx, transaction); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveCashEntry(ctx, &models.CashEntry{PortfolioID: 1, Type: models.CashDeposit, Date: day, Amount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Expected no error from Delete, got %v", err)
+	}
+
+	for _, table := range []string{"stocks", "transactions", "transaction_lots", "cash_entries"} {
+		var count int
+		if err := repo.DB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("Expected the rows of %s to be deleted with the portfolio, got %d", table, count)
+		}
+	}
+}
+
+func TestSQLitePortfolioRepository_List(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+	day := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	for _, name := range []string{"Tech", "dividends", "Tech 100%", "Bonds"} {
+		portfolio := &models.Portfolio{Name: name, Stocks: []models.Stock{
+			{Symbol: "AAPL", Quantity: 10, BuyDate: day, BuyPrice: 300},
+			{Symbol: "MSFT", Quantity: 5, BuyDate: day, BuyPrice: 150},
+		}}
+		if err := repo.Save(ctx, portfolio); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(portfolios []models.Portfolio) []string {
+		result := []string{}
+		for _, portfolio := range portfolios {
+			result = append(result, portfolio.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		query models.PortfolioQuery
+		want  []string
+	}{
+		{"all by ID", models.PortfolioQuery{}, []string{"Tech", "dividends", "Tech 100%", "Bonds"}},
+		{"by name", models.PortfolioQuery{Sort: models.PortfolioSortName}, []string{"Bonds", "dividends", "Tech", "Tech 100%"}},
+		{"descending", models.PortfolioQuery{Sort: models.PortfolioSortName, Descending: true}, []string{"Tech 100%", "Tech", "dividends", "Bonds"}},
+		{"page", models.PortfolioQuery{Limit: 2, Offset: 1}, []string{"dividends", "Tech 100%"}},
+		{"offset only", models.PortfolioQuery{Offset: 3}, []string{"Bonds"}},
+		{"name filter", models.PortfolioQuery{Name: "tech"}, []string{"Tech", "Tech 100%"}},
+		{"wildcard", models.PortfolioQuery{Name: "%"}, []string{"Tech 100%"}},
+		{"no match", models.PortfolioQuery{Name: "crypto"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portfolios, err := repo.List(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Expected no error from List, got %v", err)
+			}
+			if got := names(portfolios); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for _, portfolio := range portfolios {
+				if len(portfolio.Stocks) != 2 || portfolio.Stocks[0].Symbol != "AAPL" || portfolio.Stocks[1].Symbol != "MSFT" {
+					t.Errorf("Expected the stocks of %s in order, got %+v", portfolio.Name, portfolio.Stocks)
+				}
+			}
+		})
+	}
+
+	if _, err := repo.List(ctx, models.PortfolioQuery{Sort: "size"}); err == nil {
+		t.Error("Expected an error for an unknown sort")
+	}
+	if _, err := repo.List(ctx, models.PortfolioQuery{Limit: -1}); err == nil {
+		t.Error("Expected an error for a negative limit")
+	}
+}
+
+// BenchmarkSQLitePortfolioRepository_GetAll lists 10,000 portfolios with three
+// stocks each, reading the stocks with a query per portfolio as GetAll used to
+// and with the single query of List.
+func BenchmarkSQLitePortfolioRepository_GetAll(b *testing.B) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(b.TempDir(), "bench.db"))
+	day := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i <= 10000; i++ {
+		if _, err := tx.Exec("INSERT INTO portfolios (id, name) VALUES (?, ?)", i, fmt.Sprintf("Portfolio %05d", i)); err != nil {
+			b.Fatal(err)
+		}
+		for _, symbol := range []string{"AAPL", "MSFT", "GOOG"} {
+			_, err := tx.Exec("INSERT INTO stocks (portfolio_id, symbol, quantity, buy_date, buy_price) VALUES (?, ?, 10, ?, 100)",
+				i, symbol, day.Format("2006-01-02"))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("QueryPerPortfolio", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			portfolios, err := repo.List(ctx, models.PortfolioQuery{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for j := range portfolios {
+				if portfolios[j].Stocks, err = repo.getStocksByPortfolioID(ctx, portfolios[j].ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("BatchedQuery", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetAll(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
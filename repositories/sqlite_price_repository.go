@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *SQLitePortfolioRepository) GetCachedPrices(ctx context.Context, symbol string, from, to time.Time) ([]models.CachedPriceBar, error) {
+	bars := []models.CachedPriceBar{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT symbol, date, open, high, low, close, volume, fetched_at, source FROM prices WHERE symbol = ? AND date >= ? AND date <= ? ORDER BY date",
+		symbol, from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bar models.CachedPriceBar
+		var dateStr, fetchedAtStr string
+
+		err := rows.Scan(&bar.Symbol, &dateStr, &bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &fetchedAtStr, &bar.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		bar.Date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, err
+		}
+		bar.FetchedAt, err = time.Parse(time.RFC3339, fetchedAtStr)
+		if err != nil {
+			return nil, err
+		}
+
+		bars = append(bars, bar)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bars, nil
+}
+
+func (repo *SQLitePortfolioRepository) GetLastCachedPrice(ctx context.Context, symbol string, date time.Time) (*models.CachedPriceBar, error) {
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT symbol, date, open, high, low, close, volume, fetched_at, source FROM prices WHERE symbol = ? AND date <= ? ORDER BY date DESC LIMIT 1",
+		symbol, date.Format("2006-01-02"),
+	)
+
+	var bar models.CachedPriceBar
+	var dateStr, fetchedAtStr string
+	err := row.Scan(&bar.Symbol, &dateStr, &bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &fetchedAtStr, &bar.Source)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bar.Date, err = time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return nil, err
+	}
+	bar.FetchedAt, err = time.Parse(time.RFC3339, fetchedAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bar, nil
+}
+
+// SaveCachedPrices inserts the bars, replacing the ones already stored for the same symbol and date.
+func (repo *SQLitePortfolioRepository) SaveCachedPrices(ctx context.Context, bars []models.CachedPriceBar) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, bar := range bars {
+		_, err = tx.ExecContext(ctx,
+			"INSERT OR REPLACE INTO prices (symbol, date, open, high, low, close, volume, fetched_at, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			bar.Symbol, bar.Date.Format("2006-01-02"), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume, bar.FetchedAt.UTC().Format(time.RFC3339), bar.Source,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCachedRanges returns the fetched ranges of the symbol that overlap the period.
+func (repo *SQLitePortfolioRepository) GetCachedRanges(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceRange, error) {
+	ranges := []models.PriceRange{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT symbol, from_date, to_date FROM price_ranges WHERE symbol = ? AND from_date <= ? AND to_date >= ? ORDER BY from_date",
+		symbol, to.Format("2006-01-02"), from.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var priceRange models.PriceRange
+		var fromStr, toStr string
+
+		if err := rows.Scan(&priceRange.Symbol, &fromStr, &toStr); err != nil {
+			return nil, err
+		}
+
+		priceRange.From, err = time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return nil, err
+		}
+		priceRange.To, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, priceRange)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+// SaveCachedRange stores the range merged with the ranges of the symbol it
+// overlaps or touches, so that a symbol keeps a row per disjoint range.
+func (repo *SQLitePortfolioRepository) SaveCachedRange(ctx context.Context, priceRange models.PriceRange) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	from := priceRange.From.Format("2006-01-02")
+	to := priceRange.To.Format("2006-01-02")
+	var minFrom, maxTo sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT MIN(from_date), MAX(to_date) FROM price_ranges WHERE symbol = ? AND from_date <= ? AND to_date >= ?",
+		priceRange.Symbol, priceRange.To.AddDate(0, 0, 1).Format("2006-01-02"), priceRange.From.AddDate(0, 0, -1).Format("2006-01-02"),
+	).Scan(&minFrom, &maxTo)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if minFrom.Valid && minFrom.String < from {
+		from = minFrom.String
+	}
+	if maxTo.Valid && maxTo.String > to {
+		to = maxTo.String
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM price_ranges WHERE symbol = ? AND from_date <= ? AND to_date >= ?",
+		priceRange.Symbol, priceRange.To.AddDate(0, 0, 1).Format("2006-01-02"), priceRange.From.AddDate(0, 0, -1).Format("2006-01-02"),
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO price_ranges (symbol, from_date, to_date) VALUES (?, ?, ?)",
+		priceRange.Symbol, from, to,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *SQLitePortfolioRepository) DeleteCachedPrices(ctx context.Context, symbol string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"prices", "price_ranges"} {
+		if symbol == "" {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+table)
+		} else {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE symbol = ?", symbol)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func TestSQLitePortfolioRepository_CachedPrices(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	day := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	fetchedAt := time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+	bars := []models.CachedPriceBar{
+		{Symbol: "AAPL", PriceBar: models.PriceBar{Date: day, Open: 128.78, High: 130.22, Low: 127, Close: 127.14, Volume: 111598500, Source: "fmp"}, FetchedAt: fetchedAt},
+		{Symbol: "MSFT", PriceBar: models.PriceBar{Date: day, Close: 212.65}, FetchedAt: fetchedAt},
+	}
+	if err := repo.SaveCachedPrices(ctx, bars); err != nil {
+		t.Fatalf("Expected no error from SaveCachedPrices, got %v", err)
+	}
+
+	// Saving the same symbol and date again replaces the bar
+	bars[0].Close = 127.5
+	if err := repo.SaveCachedPrices(ctx, bars[:1]); err != nil {
+		t.Fatalf("Expected no error from SaveCachedPrices, got %v", err)
+	}
+
+	cached, err := repo.GetCachedPrices(ctx, "AAPL", day, day.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("Expected no error from GetCachedPrices, got %v", err)
+	}
+	if len(cached) != 1 {
+		t.Fatalf("Expected 1 cached bar, got %d", len(cached))
+	}
+	if cached[0].Close != 127.5 || cached[0].Volume != 111598500 || !cached[0].FetchedAt.Equal(fetchedAt) || cached[0].Source != "fmp" {
+		t.Errorf("Unexpected cached bar %+v", cached[0])
+	}
+
+	err = repo.SaveCachedRange(ctx, models.PriceRange{Symbol: "AAPL", From: day, To: day.AddDate(0, 0, 4)})
+	if err != nil {
+		t.Fatalf("Expected no error from SaveCachedRange, got %v", err)
+	}
+	ranges, err := repo.GetCachedRanges(ctx, "AAPL", day.AddDate(0, 0, 4), day.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("Expected no error from GetCachedRanges, got %v", err)
+	}
+	if len(ranges) != 1 {
+		t.Fatalf("Expected the overlapping range, got %d ranges", len(ranges))
+	}
+
+	// Overlapping and adjacent ranges are merged into one row, a separate one is kept apart
+	for _, priceRange := range []models.PriceRange{
+		{Symbol: "AAPL", From: day.AddDate(0, 0, 5), To: day.AddDate(0, 0, 9)},
+		{Symbol: "AAPL", From: day.AddDate(0, 0, 2), To: day.AddDate(0, 0, 3)},
+		{Symbol: "AAPL", From: day.AddDate(0, 0, -3), To: day.AddDate(0, 0, 1)},
+		{Symbol: "AAPL", From: day.AddDate(0, 0, 20), To: day.AddDate(0, 0, 25)},
+	} {
+		if err := repo.SaveCachedRange(ctx, priceRange); err != nil {
+			t.Fatalf("Expected no error from SaveCachedRange, got %v", err)
+		}
+	}
+	ranges, err = repo.GetCachedRanges(ctx, "AAPL", day.AddDate(0, 0, -10), day.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatalf("Expected no error from GetCachedRanges, got %v", err)
+	}
+	want := []models.PriceRange{
+		{Symbol: "AAPL", From: day.AddDate(0, 0, -3), To: day.AddDate(0, 0, 9)},
+		{Symbol: "AAPL", From: day.AddDate(0, 0, 20), To: day.AddDate(0, 0, 25)},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("Expected the merged ranges %+v, got %+v", want, ranges)
+	}
+	var count int
+	if err := repo.DB.QueryRow("SELECT COUNT(*) FROM price_ranges").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 stored ranges, got %d", count)
+	}
+
+	if err := repo.DeleteCachedPrices(ctx, "AAPL"); err != nil {
+		t.Fatalf("Expected no error from DeleteCachedPrices, got %v", err)
+	}
+	cached, _ = repo.GetCachedPrices(ctx, "AAPL", day, day)
+	ranges, _ = repo.GetCachedRanges(ctx, "AAPL", day, day)
+	if len(cached) != 0 || len(ranges) != 0 {
+		t.Errorf("Expected the AAPL prices to be deleted, got %d bars and %d ranges", len(cached), len(ranges))
+	}
+	cached, _ = repo.GetCachedPrices(ctx, "MSFT", day, day)
+	if len(cached) != 1 {
+		t.Errorf("Expected the MSFT prices to be kept, got %d bars", len(cached))
+	}
+
+	last, err := repo.GetLastCachedPrice(ctx, "MSFT", day.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("Expected no error from GetLastCachedPrice, got %v", err)
+	}
+	if last == nil || !last.Date.Equal(day) || last.Close != 212.65 {
+		t.Errorf("Expected the MSFT bar of %s, got %+v", day.Format("2006-01-02"), last)
+	}
+	last, err = repo.GetLastCachedPrice(ctx, "MSFT", day.AddDate(0, 0, -1))
+	if err != nil || last != nil {
+		t.Errorf("Expected no MSFT bar before %s, got %+v and %v", day.Format("2006-01-02"), last, err)
+	}
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func (repo *SQLitePortfolioRepository) GetTransactions(ctx context.Context, portfolioID int) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT id, portfolio_id, symbol, type, date, quantity, price, commission FROM transactions WHERE portfolio_id = ? ORDER BY date, id",
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		var transactionType, dateStr string
+
+		err := rows.Scan(&transaction.ID, &transaction.PortfolioID, &transaction.Symbol, &transactionType,
+			&dateStr, &transaction.Quantity, &transaction.Price, &transaction.Commission)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Type = models.TransactionType(transactionType)
+
+		transaction.Date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	lots, err := repo.getLotSelectionsByPortfolioID(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range transactions {
+		transactions[i].Lots = lots[transactions[i].ID]
+	}
+
+	return transactions, nil
+}
+
+func (repo *SQLitePortfolioRepository) SaveTransaction(ctx context.Context, transaction *models.Transaction) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO transactions (portfolio_id, symbol, type, date, quantity, price, commission) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		transaction.PortfolioID, transaction.Symbol, string(transaction.Type), transaction.Date.Format("2006-01-02"),
+		transaction.Quantity, transaction.Price, transaction.Commission,
+	)
+	if err != nil {
+		tx.Rollback()
+		return constraintError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, lot := range transaction.Lots {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO transaction_lots (transaction_id, lot_id, quantity) VALUES (?, ?, ?)",
+			id, lot.LotID, lot.Quantity,
+		)
+		if err != nil {
+			tx.Rollback()
+			return constraintError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	transaction.ID = int(id)
+
+	return nil
+}
+
+// DeleteTransaction removes the transaction, and the database removes its lot
+// selections with it.
+func (repo *SQLitePortfolioRepository) DeleteTransaction(ctx context.Context, id int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM transactions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return notFound(res, "transaction", id)
+}
+
+// getLotSelectionsByPortfolioID returns the lots selected by the sells of a portfolio, keyed by transaction ID.
+func (repo *SQLitePortfolioRepository) getLotSelectionsByPortfolioID(ctx context.Context, portfolioID int) (map[int][]models.LotSelection, error) {
+	lots := make(map[int][]models.LotSelection)
+
+	rows, err := repo.DB.QueryContext(ctx,
+		`SELECT tl.transaction_id, tl.lot_id, tl.quantity FROM transaction_lots tl
+        JOIN transactions t ON t.id = tl.transaction_id
+        WHERE t.portfolio_id = ? ORDER BY tl.rowid`,
+		portfolioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transactionID int
+		var lot models.LotSelection
+		if err := rows.Scan(&transactionID, &lot.LotID, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		lots[transactionID] = append(lots[transactionID], lot)
+	}
+
+	return lots, rows.Err()
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+func TestSQLitePortfolioRepository_Transactions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "test.db"))
+
+	err := repo.Save(ctx, &models.Portfolio{Name: "Ledger Portfolio"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sell := &models.Transaction{
+		PortfolioID: 1,
+		Symbol:      "AAPL",
+		Type:        models.TransactionSell,
+		Date:        time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		Quantity:    2.5,
+		Price:       120.0,
+		Commission:  1.5,
+		Lots:        []models.LotSelection{{LotID: "T2", Quantity: 2}, {LotID: "S1", Quantity: 0.5}},
+	}
+	buy := &models.Transaction{
+		PortfolioID: 1,
+		Symbol:      "AAPL",
+		Type:        models.TransactionBuy,
+		Date:        time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC),
+		Quantity:    10,
+		Price:       100.0,
+	}
+
+	for _, transaction := range []*models.Transaction{sell, buy} {
+		if err := repo.SaveTransaction(ctx, transaction); err != nil {
+			t.Fatalf("Expected no error from SaveTransaction, got %v", err)
+		}
+		if transaction.ID == 0 {
+			t.Fatal("Expected SaveTransaction to assign an ID")
+		}
+	}
+
+	// Transactions are returned in chronological order
+	transactions, err := repo.GetTransactions(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error from GetTransactions, got %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+	if transactions[0].Type != models.TransactionBuy || transactions[1].Type != models.TransactionSell {
+		t.Errorf("Expected buy then sell, got %s then %s", transactions[0].Type, transactions[1].Type)
+	}
+	if transactions[1].Quantity != 2.5 || transactions[1].Commission != 1.5 {
+		t.Errorf("Expected quantity 2.5 and commission 1.5, got %v and %v", transactions[1].Quantity, transactions[1].Commission)
+	}
+
+	if len(transactions[1].Lots) != 2 || transactions[1].Lots[0].LotID != "T2" || transactions[1].Lots[1].Quantity != 0.5 {
+		t.Errorf("Expected the selected lots to be stored, got %+v", transactions[1].Lots)
+	}
+
+	if err := repo.DeleteTransaction(ctx, buy.ID); err != nil {
+		t.Fatalf("Expected no error from DeleteTransaction, got %v", err)
+	}
+
+	// Deleting the portfolio removes its remaining transactions
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Expected no error from Delete, got %v", err)
+	}
+	transactions, err = repo.GetTransactions(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error from GetTransactions, got %v", err)
+	}
+	if len(transactions) != 0 {
+		t.Fatalf("Expected 0 transactions after delete, got %d", len(transactions))
+	}
+}
@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"context"
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+type TransactionRepository interface {
+	GetTransactions(ctx context.Context, portfolioID int) ([]models.Transaction, error)
+	SaveTransaction(ctx context.Context, transaction *models.Transaction) error
+	DeleteTransaction(ctx context.Context, id int) error
+}
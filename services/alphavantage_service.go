@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/api"
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/go-resty/resty/v2"
+)
+
+// AlphaVantageSource is the source of the prices of Alpha Vantage.
+const AlphaVantageSource = "alphavantage"
+
+// alphaVantageCompactDays is how far back the compact output of Alpha Vantage
+// reaches. It returns the last 100 trading days, roughly 140 calendar days.
+const alphaVantageCompactDays = 140
+
+// AlphaVantageService fetches prices from the daily adjusted series of Alpha
+// Vantage. Like FinancialModelingPrepService, dates without trading are
+// resolved according to Resolution and prices that cannot be fetched are asked
+// to Fallback. The bars hold the prices as traded, not adjusted for splits and
+// dividends, since purchases are recorded at those prices.
+type AlphaVantageService struct {
+	APIKey     string
+	Client     *resty.Client
+	Resolution models.DateResolution
+	Fallback   PriceFallback
+	Now        func() time.Time
+}
+
+func NewAlphaVantageService(apiKey string) *AlphaVantageService {
+	return &AlphaVantageService{
+		APIKey:     apiKey,
+		Client:     newClient("https://www.alphavantage.co", DefaultClientOptions()),
+		Resolution: models.ResolvePreviousTradingDay,
+		Fallback:   FailFastFallback{},
+		Now:        time.Now,
+	}
+}
+
+func (av *AlphaVantageService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := av.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Open, nil
+}
+
+func (av *AlphaVantageService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := av.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Close, nil
+}
+
+func (av *AlphaVantageService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	return resolvePrice(ctx, av.fetchPriceHistory, symbol, date, av.Resolution, av.Fallback)
+}
+
+// GetPriceHistory returns the daily bars of the symbol between from and to.
+// Alpha Vantage has no ranged queries, so the series is fetched and filtered.
+func (av *AlphaVantageService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: the end date %s is before the start date %s", models.ErrValidation, to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	return av.fetchPriceHistory(ctx, symbol, from, to)
+}
+
+func (av *AlphaVantageService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	return api.GetSP500Symbols(ctx, &api.DefaultHTTPClient{})
+}
+
+func (av *AlphaVantageService) fetchPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	// The compact output is much smaller and enough for recent periods
+	outputSize := "full"
+	if av.Now().Sub(from) < alphaVantageCompactDays*24*time.Hour {
+		outputSize = "compact"
+	}
+
+	resp, err := av.Client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"function":   "TIME_SERIES_DAILY_ADJUSTED",
+			"symbol":     symbol,
+			"outputsize": outputSize,
+			"apikey":     av.APIKey,
+		}).
+		Get("/query")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("API request failed with status code %d", resp.StatusCode())
+	}
+
+	var result struct {
+		ErrorMessage string `json:"Error Message"`
+		Note         string `json:"Note"`
+		Information  string `json:"Information"`
+		TimeSeries   map[string]struct {
+			Open   string `json:"1. open"`
+			High   string `json:"2. high"`
+			Low    string `json:"3. low"`
+			Close  string `json:"4. close"`
+			Volume string `json:"6. volume"`
+		} `json:"Time Series (Daily)"`
+	}
+
+	err = json.Unmarshal(resp.Body(), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors and rate limits come back with status 200 and a message instead of
+	// the series. An unknown symbol is an invalid call, and the rate limit
+	// messages point to the premium plans too, but only a key without access
+	// to the endpoint is told it is a premium endpoint.
+	switch {
+	case result.ErrorMessage != "":
+		return nil, fmt.Errorf("%w for %s: %s", ErrNoPriceData, symbol, result.ErrorMessage)
+	case result.TimeSeries == nil && strings.Contains(result.Information, "premium endpoint"):
+		return nil, fmt.Errorf("%w: Alpha Vantage refuses TIME_SERIES_DAILY_ADJUSTED to the API key: %s", ErrProviderConfig, result.Information)
+	case result.Note != "" || (result.Information != "" && result.TimeSeries == nil):
+		message := result.Note
+		if message == "" {
+			message = result.Information
+		}
+		return nil, &RateLimitError{Provider: "Alpha Vantage", Message: message}
+	case result.TimeSeries == nil:
+		return nil, fmt.Errorf("%w for %s", ErrNoPriceData, symbol)
+	}
+
+	bars := make([]models.PriceBar, 0, len(result.TimeSeries))
+	for dateStr, daily := range result.TimeSeries {
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in the price history of %s", dateStr, symbol)
+		}
+		if date.Before(dateOnly(from)) || date.After(dateOnly(to)) {
+			continue
+		}
+
+		bar := models.PriceBar{Date: date, Source: AlphaVantageSource}
+		values := []struct {
+			text  string
+			value *float64
+		}{
+			{daily.Open, &bar.Open},
+			{daily.High, &bar.High},
+			{daily.Low, &bar.Low},
+			{daily.Close, &bar.Close},
+		}
+		for _, v := range values {
+			if *v.value, err = strconv.ParseFloat(v.text, 64); err != nil {
+				return nil, fmt.Errorf("invalid price %q in the price history of %s on %s", v.text, symbol, dateStr)
+			}
+		}
+		if bar.Volume, err = strconv.ParseInt(daily.Volume, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid volume %q in the price history of %s on %s", daily.Volume, symbol, dateStr)
+		}
+
+		bars = append(bars, bar)
+	}
+
+	// The series is a JSON object, so its order is lost
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	return bars, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newFixtureServer serves the recorded Alpha Vantage response in testdata and
+// keeps the query of the last request.
+func newFixtureServer(t *testing.T, fixture string, query *map[string]string) *httptest.Server {
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("Reading fixture %s: %v", fixture, err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query != nil {
+			*query = map[string]string{}
+			for key := range r.URL.Query() {
+				(*query)[key] = r.URL.Query().Get(key)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestAlphaVantageService(ts *httptest.Server, now time.Time) *AlphaVantageService {
+	av := NewAlphaVantageService("dummykey")
+	av.Client.SetBaseURL(ts.URL)
+	av.Now = func() time.Time { return now }
+	return av
+}
+
+func TestAlphaVantageService_GetPriceHistory(t *testing.T) {
+	ctx := context.Background()
+	var query map[string]string
+	ts := newFixtureServer(t, "alphavantage_daily_adjusted.json", &query)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	from := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC)
+	bars, err := av.GetPriceHistory(ctx, "AAPL", from, to)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if query["function"] != "TIME_SERIES_DAILY_ADJUSTED" || query["symbol"] != "AAPL" || query["outputsize"] != "compact" || query["apikey"] != "dummykey" {
+		t.Errorf("Unexpected query %v", query)
+	}
+	if len(bars) != 3 {
+		t.Fatalf("Expected the 3 bars of the period, got %d", len(bars))
+	}
+	if !bars[0].Date.Equal(from) || !bars[2].Date.Equal(to) {
+		t.Errorf("Expected bars in chronological order, got %v to %v", bars[0].Date, bars[2].Date)
+	}
+	if bars[0].Open != 128.78 || bars[0].High != 130.2242 || bars[0].Low != 127 || bars[0].Close != 127.14 || bars[0].Volume != 111598531 {
+		t.Errorf("Unexpected bar %+v", bars[0])
+	}
+
+	// Periods older than the compact output need the full series
+	if _, err := av.GetPriceHistory(ctx, "AAPL", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), to); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if query["outputsize"] != "full" {
+		t.Errorf("Expected the full output size, got %q", query["outputsize"])
+	}
+}
+
+func TestAlphaVantageService_GetPriceOpenAndClose(t *testing.T) {
+	ctx := context.Background()
+	ts := newFixtureServer(t, "alphavantage_daily_adjusted.json", nil)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	date := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	open, err := av.GetPriceOpen(ctx, "AAPL", date)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if open != 128.78 {
+		t.Errorf("Expected open price 128.78, got %f", open)
+	}
+
+	// Martin Luther King Jr. Day is priced with the previous trading day
+	closePrice, err := av.GetPriceClose(ctx, "AAPL", time.Date(2021, 1, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if closePrice != 127.14 {
+		t.Errorf("Expected close price 127.14, got %f", closePrice)
+	}
+}
+
+func TestAlphaVantageService_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	ts := newFixtureServer(t, "alphavantage_rate_limit.json", nil)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	_, err := av.GetPriceClose(ctx, "AAPL", time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+}
+
+func TestAlphaVantageService_InvalidSymbol(t *testing.T) {
+	ctx := context.Background()
+	ts := newFixtureServer(t, "alphavantage_invalid_symbol.json", nil)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	_, err := av.GetPriceHistory(ctx, "NOPE", time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrNoPriceData) {
+		t.Errorf("Expected ErrNoPriceData for an unknown symbol, got %v", err)
+	}
+}
+
+func TestAlphaVantageService_DailyRateLimit(t *testing.T) {
+	ctx := context.Background()
+	ts := newFixtureServer(t, "alphavantage_daily_rate_limit.json", nil)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	_, err := av.GetPriceClose(ctx, "AAPL", time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrProviderConfig) {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+}
+
+func TestAlphaVantageService_PremiumEndpoint(t *testing.T) {
+	ctx := context.Background()
+	ts := newFixtureServer(t, "alphavantage_premium_endpoint.json", nil)
+	av := newTestAlphaVantageService(ts, time.Date(2021, 1, 22, 18, 0, 0, 0, time.UTC))
+
+	_, err := av.GetPriceHistory(ctx, "AAPL", time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrProviderConfig) || errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected a configuration error for a premium endpoint, got %v", err)
+	}
+}
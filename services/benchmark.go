@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// compareToBenchmark replays the external flows of the valuation series into
+// the benchmark symbol. The value on the first day buys the benchmark at its
+// close and, like the portfolio series, later flows happen at the start of
+// their day, at the previous close. The benchmark is priced from the histories.
+func (ps *PortfolioService) compareToBenchmark(ctx context.Context, points []models.ValuationPoint, histories *priceHistories, symbol string, from, to time.Time) (*models.BenchmarkComparison, error) {
+	comparison := &models.BenchmarkComparison{
+		Symbol: symbol,
+		From:   from,
+		To:     to,
+		Points: make([]models.BenchmarkPoint, 0, len(points)),
+	}
+
+	shares := 0.0
+	previousPrice := 0.0
+	previousValue := 0.0
+	growth := 1.0
+	var excess []float64
+	for i, point := range points {
+		price, err := histories.closePrice(ctx, symbol, point.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		benchmarkReturn := 0.0
+		if i == 0 {
+			shares = point.Value / price
+		} else {
+			shares += point.NetFlow / previousPrice
+			if invested := previousValue + point.NetFlow; invested > 0 {
+				benchmarkReturn = shares*price/invested - 1
+			}
+			excess = append(excess, point.Return-benchmarkReturn)
+		}
+		growth *= 1 + benchmarkReturn
+
+		value := shares * price
+		comparison.Points = append(comparison.Points, models.BenchmarkPoint{
+			Date:            point.Date,
+			PortfolioValue:  point.Value,
+			BenchmarkValue:  value,
+			PortfolioReturn: point.CumulativeReturn,
+			BenchmarkReturn: growth - 1,
+		})
+		previousPrice = price
+		previousValue = value
+	}
+
+	if len(points) > 0 {
+		comparison.PortfolioReturn = points[len(points)-1].CumulativeReturn
+		comparison.BenchmarkReturn = growth - 1
+	}
+	comparison.TrackingError = sampleStdDev(excess) * math.Sqrt(tradingDaysPerYear)
+	if comparison.TrackingError > 0 {
+		comparison.InformationRatio = mean(excess) * tradingDaysPerYear / comparison.TrackingError
+	}
+	return comparison, nil
+}
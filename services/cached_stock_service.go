@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/repositories"
+)
+
+// DefaultPriceCacheTTL is how long the prices of the current day are reused.
+const DefaultPriceCacheTTL = 15 * time.Minute
+
+// PriceCacheInterface warms and invalidates the stored prices.
+type PriceCacheInterface interface {
+	WarmPrices(ctx context.Context, symbols []string, from, to time.Time) (int, error)
+	InvalidatePrices(ctx context.Context, symbols []string) error
+}
+
+// CachedStockService stores the bars returned by another stock service. Bars
+// of past dates never change and are kept forever, while the bars of the
+// current day are fetched again once they are older than the TTL. Dates
+// without trading are resolved from the stored bars like the provider does.
+// Prices that cannot be resolved are asked to Fallback, and a nil Fallback
+// returns the error.
+type CachedStockService struct {
+	Provider   StockServiceInterface
+	Repo       repositories.PriceRepository
+	TTL        time.Duration
+	Resolution models.DateResolution
+	Fallback   PriceFallback
+	Now        func() time.Time
+}
+
+func NewCachedStockService(provider StockServiceInterface, repo repositories.PriceRepository, ttl time.Duration) *CachedStockService {
+	return &CachedStockService{
+		Provider:   provider,
+		Repo:       repo,
+		TTL:        ttl,
+		Resolution: models.ResolvePreviousTradingDay,
+		Fallback:   FailFastFallback{},
+		Now:        time.Now,
+	}
+}
+
+func (cs *CachedStockService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := cs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Open, nil
+}
+
+func (cs *CachedStockService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := cs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Close, nil
+}
+
+// GetResolvedPrice resolves the date from the bars of the window, cached or
+// fetched once, and asks the fallback for the prices it cannot resolve.
+func (cs *CachedStockService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	return resolvePrice(ctx, cs.GetPriceHistory, symbol, date, cs.Resolution, cs.Fallback)
+}
+
+// GetPriceHistory serves the bars from the cache when it covers the whole
+// period and otherwise fetches the period from the provider and stores it. An
+// empty reply stores nothing, so the period is fetched again next time.
+func (cs *CachedStockService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	from, to = dateOnly(from), dateOnly(to)
+	cached, err := cs.Repo.GetCachedPrices(ctx, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	covered, err := cs.covers(ctx, symbol, from, to, cached)
+	if err != nil {
+		return nil, err
+	}
+	if covered {
+		bars := make([]models.PriceBar, 0, len(cached))
+		for _, bar := range cached {
+			bars = append(bars, bar.PriceBar)
+		}
+		return bars, nil
+	}
+
+	bars, err := cs.Provider.GetPriceHistory(ctx, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedAt := cs.Now()
+	toStore := make([]models.CachedPriceBar, 0, len(bars))
+	for _, bar := range bars {
+		toStore = append(toStore, models.CachedPriceBar{Symbol: symbol, PriceBar: bar, FetchedAt: fetchedAt})
+	}
+	if err := cs.Repo.SaveCachedPrices(ctx, toStore); err != nil {
+		return nil, err
+	}
+
+	// Days without a bar after the first one had no trading, such as the
+	// holiday a price is resolved for, but before it the provider may just
+	// have missed the bars, so the period is only known from the first bar.
+	// Of it, only the past part is final.
+	if len(bars) == 0 {
+		return bars, nil
+	}
+	first := dateOnly(bars[0].Date)
+	for _, bar := range bars[1:] {
+		if day := dateOnly(bar.Date); day.Before(first) {
+			first = day
+		}
+	}
+	last := to
+	if yesterday := cs.today().AddDate(0, 0, -1); last.After(yesterday) {
+		last = yesterday
+	}
+	if !first.After(last) {
+		if err := cs.Repo.SaveCachedRange(ctx, models.PriceRange{Symbol: symbol, From: first, To: last}); err != nil {
+			return nil, err
+		}
+	}
+	return bars, nil
+}
+
+func (cs *CachedStockService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	return cs.Provider.GetSP500Symbols(ctx)
+}
+
+// WarmPrices fetches the bars of every symbol for the period and returns how
+// many bars are stored. Symbols without bars in the period are skipped.
+func (cs *CachedStockService) WarmPrices(ctx context.Context, symbols []string, from, to time.Time) (int, error) {
+	total := 0
+	for _, symbol := range symbols {
+		bars, err := cs.GetPriceHistory(ctx, symbol, from, to)
+		if errors.Is(err, ErrNoPriceData) {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		total += len(bars)
+	}
+	return total, nil
+}
+
+// InvalidatePrices removes the stored bars of the symbols, or of every symbol
+// when none is given.
+func (cs *CachedStockService) InvalidatePrices(ctx context.Context, symbols []string) error {
+	if len(symbols) == 0 {
+		return cs.Repo.DeleteCachedPrices(ctx, "")
+	}
+	for _, symbol := range symbols {
+		if err := cs.Repo.DeleteCachedPrices(ctx, symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// covers reports whether the cached bars can answer for the period: every past
+// trading day has a bar fetched after the day ended or was fetched before
+// without a bar, and the bars of today are fresh.
+func (cs *CachedStockService) covers(ctx context.Context, symbol string, from, to time.Time, cached []models.CachedPriceBar) (bool, error) {
+	today := cs.today()
+	if to.After(today) {
+		to = today
+	}
+
+	ranges, err := cs.Repo.GetCachedRanges(ctx, symbol, from, to)
+	if err != nil {
+		return false, err
+	}
+
+	byDate := make(map[string]models.CachedPriceBar, len(cached))
+	for _, bar := range cached {
+		byDate[bar.Date.Format("2006-01-02")] = bar
+	}
+
+	for _, day := range tradingDays(from, to) {
+		bar, ok := byDate[day.Format("2006-01-02")]
+		if day.Before(today) {
+			// A bar fetched before its day ended holds an unfinished price
+			if ok && bar.FetchedAt.Before(day.AddDate(0, 0, 1)) {
+				return false, nil
+			}
+			if !ok && !inRanges(ranges, day) {
+				return false, nil
+			}
+			continue
+		}
+		if !ok || cs.Now().Sub(bar.FetchedAt) >= cs.TTL {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// today returns the current date at midnight UTC, like the dates of the bars.
+func (cs *CachedStockService) today() time.Time {
+	return dateOnly(cs.Now())
+}
+
+func inRanges(ranges []models.PriceRange, day time.Time) bool {
+	for _, r := range ranges {
+		if !day.Before(r.From) && !day.After(r.To) {
+			return true
+		}
+	}
+	return false
+}
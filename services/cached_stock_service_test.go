@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/repositories"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCachedStockService(t *testing.T, now time.Time) (*CachedStockService, *MockStockService) {
+	repo := repositories.NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "prices.db"))
+	t.Cleanup(func() { repo.DB.Close() })
+
+	provider := new(MockStockService)
+	cache := NewCachedStockService(provider, repo, 15*time.Minute)
+	cache.Now = func() time.Time { return now }
+	// Prices are looked up on their own date unless a test resolves them.
+	cache.Resolution = models.ResolveStrict
+	return cache, provider
+}
+
+// TestCachedStockService_PastDates test that past prices are only fetched once
+func TestCachedStockService_PastDates(t *testing.T) {
+	ctx := context.Background()
+	cache, provider := newTestCachedStockService(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	// Friday, the Monday holiday and Tuesday.
+	from := date(2021, 1, 15)
+	to := date(2021, 1, 19)
+	provider.On("GetPriceHistory", "AAPL", from, to).Return([]models.PriceBar{
+		{Date: from, Open: 128.78, High: 130.22, Low: 127, Close: 127.14, Volume: 111598500},
+		{Date: to, Open: 127.78, High: 128.71, Low: 126.94, Close: 127.83, Volume: 90757300},
+	}, nil).Once()
+
+	bars, err := cache.GetPriceHistory(ctx, "AAPL", from, to)
+	require.NoError(t, err)
+	require.Len(t, bars, 2)
+
+	// The holiday has no bar but the period is known, so nothing is fetched again.
+	bars, err = cache.GetPriceHistory(ctx, "AAPL", from, to)
+	require.NoError(t, err)
+	require.Len(t, bars, 2)
+	require.Equal(t, int64(90757300), bars[1].Volume)
+
+	price, err := cache.GetPriceClose(ctx, "AAPL", to)
+	require.NoError(t, err)
+	require.Equal(t, 127.83, price)
+
+	price, err = cache.GetPriceOpen(ctx, "AAPL", from)
+	require.NoError(t, err)
+	require.Equal(t, 128.78, price)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 1)
+}
+
+// TestCachedStockService_Today test that the prices of today expire after the TTL
+func TestCachedStockService_Today(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 15, 0, 0, 0, time.UTC)
+	cache, provider := newTestCachedStockService(t, now)
+
+	today := date(2021, 6, 1)
+	provider.On("GetPriceHistory", "AAPL", today, today).Return([]models.PriceBar{{Date: today, Close: 124.0}}, nil).Once()
+	provider.On("GetPriceHistory", "AAPL", today, today).Return([]models.PriceBar{{Date: today, Close: 125.0}}, nil).Once()
+
+	price, err := cache.GetPriceClose(ctx, "AAPL", now)
+	require.NoError(t, err)
+	require.Equal(t, 124.0, price)
+
+	cache.Now = func() time.Time { return now.Add(10 * time.Minute) }
+	price, err = cache.GetPriceClose(ctx, "AAPL", today)
+	require.NoError(t, err)
+	require.Equal(t, 124.0, price)
+
+	cache.Now = func() time.Time { return now.Add(20 * time.Minute) }
+	price, err = cache.GetPriceClose(ctx, "AAPL", today)
+	require.NoError(t, err)
+	require.Equal(t, 125.0, price)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 2)
+}
+
+// TestCachedStockService_TodayBecomesPast test that a bar fetched during its day is fetched again once the day ended
+func TestCachedStockService_TodayBecomesPast(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 15, 0, 0, 0, time.UTC)
+	cache, provider := newTestCachedStockService(t, now)
+
+	day := date(2021, 6, 1)
+	provider.On("GetPriceHistory", "AAPL", day, day).Return([]models.PriceBar{{Date: day, Close: 124.0}}, nil).Once()
+	provider.On("GetPriceHistory", "AAPL", day, day).Return([]models.PriceBar{{Date: day, Close: 125.0}}, nil).Once()
+
+	price, err := cache.GetPriceClose(ctx, "AAPL", day)
+	require.NoError(t, err)
+	require.Equal(t, 124.0, price)
+
+	// The next morning the intraday price is replaced by the close, which is then final.
+	cache.Now = func() time.Time { return time.Date(2021, 6, 2, 9, 0, 0, 0, time.UTC) }
+	price, err = cache.GetPriceClose(ctx, "AAPL", day)
+	require.NoError(t, err)
+	require.Equal(t, 125.0, price)
+
+	cache.Now = func() time.Time { return time.Date(2021, 6, 10, 9, 0, 0, 0, time.UTC) }
+	price, err = cache.GetPriceClose(ctx, "AAPL", day)
+	require.NoError(t, err)
+	require.Equal(t, 125.0, price)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 2)
+}
+
+// TestCachedStockService_EmptyReply test that a period the provider returned no bars for is not cached as without trading
+func TestCachedStockService_EmptyReply(t *testing.T) {
+	ctx := context.Background()
+	cache, provider := newTestCachedStockService(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	from := date(2021, 1, 11)
+	to := date(2021, 1, 15)
+	bars := []models.PriceBar{{Date: date(2021, 1, 14), Close: 128.91}, {Date: to, Close: 127.14}}
+	provider.On("GetPriceHistory", "AAPL", from, to).Return([]models.PriceBar{}, nil).Once()
+	provider.On("GetPriceHistory", "AAPL", from, to).Return(bars, nil).Once()
+	provider.On("GetPriceHistory", "AAPL", from, to).Return(bars, nil).Once()
+	provider.On("GetPriceHistory", "AAPL", date(2021, 1, 14), to).Return(bars, nil).Once()
+
+	count, err := cache.WarmPrices(ctx, []string{"AAPL"}, from, to)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	got, err := cache.GetPriceHistory(ctx, "AAPL", from, to)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+
+	// The period is only known from the first returned bar, the days before it are fetched again
+	_, err = cache.GetPriceHistory(ctx, "AAPL", from, to)
+	require.NoError(t, err)
+	_, err = cache.GetPriceHistory(ctx, "AAPL", date(2021, 1, 14), to)
+	require.NoError(t, err)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 3)
+}
+
+// TestCachedStockService_InvalidatePrices test that invalidated prices are fetched again
+func TestCachedStockService_InvalidatePrices(t *testing.T) {
+	ctx := context.Background()
+	cache, provider := newTestCachedStockService(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	day := date(2021, 1, 15)
+	provider.On("GetPriceHistory", "AAPL", day, day).Return([]models.PriceBar{{Date: day, Close: 127.14}}, nil)
+	provider.On("GetPriceHistory", "MSFT", day, day).Return([]models.PriceBar{{Date: day, Close: 212.65}}, nil)
+
+	count, err := cache.WarmPrices(ctx, []string{"AAPL", "MSFT"}, day, day)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.NoError(t, cache.InvalidatePrices(ctx, []string{"AAPL"}))
+	_, err = cache.GetPriceClose(ctx, "AAPL", day)
+	require.NoError(t, err)
+	_, err = cache.GetPriceClose(ctx, "MSFT", day)
+	require.NoError(t, err)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 3)
+}
+
+// TestCachedStockService_ResolvePreviousTradingDay test that a holiday is priced with the previous trading day
+func TestCachedStockService_ResolvePreviousTradingDay(t *testing.T) {
+	ctx := context.Background()
+	cache, provider := newTestCachedStockService(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	cache.Resolution = models.ResolvePreviousTradingDay
+
+	// Martin Luther King Jr. Day, the previous trading day is Friday.
+	holiday := date(2021, 1, 18)
+	provider.On("GetPriceHistory", "AAPL", date(2021, 1, 8), holiday).Return([]models.PriceBar{
+		{Date: date(2021, 1, 8), Close: 132.05},
+		{Date: date(2021, 1, 11), Close: 128.98},
+		{Date: date(2021, 1, 12), Close: 128.8},
+		{Date: date(2021, 1, 13), Close: 130.89},
+		{Date: date(2021, 1, 14), Close: 128.91},
+		{Date: date(2021, 1, 15), Close: 127.14},
+	}, nil).Once()
+
+	price, err := cache.GetResolvedPrice(ctx, "AAPL", holiday)
+	require.NoError(t, err)
+	require.True(t, price.Adjusted())
+	require.Equal(t, date(2021, 1, 15), price.Date)
+	require.Equal(t, holiday, price.RequestedDate)
+	require.Equal(t, 127.14, price.Close)
+
+	closePrice, err := cache.GetPriceClose(ctx, "AAPL", holiday)
+	require.NoError(t, err)
+	require.Equal(t, 127.14, closePrice)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 1)
+}
+
+// TestCachedStockService_Unresolvable test that a date without a bar is fetched once and then asked to the fallback
+func TestCachedStockService_Unresolvable(t *testing.T) {
+	ctx := context.Background()
+	cache, provider := newTestCachedStockService(t, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	fallback := &countingFallback{}
+	cache.Fallback = fallback
+
+	// A holiday in strict mode has no bar.
+	holiday := date(2021, 1, 18)
+	provider.On("GetPriceHistory", "AAPL", holiday, holiday).Return([]models.PriceBar{}, nil).Once()
+
+	_, err := cache.GetResolvedPrice(ctx, "AAPL", holiday)
+	require.ErrorIs(t, err, ErrNoPriceData)
+	require.ErrorIs(t, err, models.ErrPriceUnavailable)
+	require.Equal(t, 1, fallback.calls)
+
+	provider.AssertNumberOfCalls(t, "GetPriceHistory", 1)
+	provider.AssertNotCalled(t, "GetResolvedPrice", "AAPL", holiday)
+}
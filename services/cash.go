@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// transactionCashFlow returns the effect of a transaction on the cash account.
+// The stocks of the portfolio are positions contributed in kind and do not
+// move cash.
+func transactionCashFlow(t models.Transaction) float64 {
+	if t.Contributed {
+		return 0
+	}
+
+	switch t.Type {
+	case models.TransactionBuy:
+		return -(t.Quantity*t.Price + t.Commission)
+	case models.TransactionSell:
+		return t.Quantity*t.Price - t.Commission
+	case models.TransactionDividend:
+		return t.Price - t.Commission
+	case models.TransactionFee:
+		return -(t.Price + t.Commission)
+	default:
+		return 0
+	}
+}
+
+// cashBalance returns the cash of the portfolio on asOf.
+func cashBalance(ledger []models.Transaction, entries []models.CashEntry, asOf time.Time) float64 {
+	balance := 0.0
+	for _, t := range ledger {
+		if !t.Date.After(asOf) {
+			balance += transactionCashFlow(t)
+		}
+	}
+	for _, entry := range entries {
+		if !entry.Date.After(asOf) {
+			balance += entry.SignedAmount()
+		}
+	}
+	return balance
+}
+
+// netContributions returns the money put into the portfolio up to asOf: the
+// cost of the contributed stocks plus deposits minus withdrawals.
+func netContributions(ledger []models.Transaction, entries []models.CashEntry, asOf time.Time) float64 {
+	total := 0.0
+	for _, t := range ledger {
+		if t.Contributed && !t.Date.After(asOf) {
+			total += t.Quantity * t.Price
+		}
+	}
+	for _, entry := range entries {
+		if entry.IsExternalFlow() && !entry.Date.After(asOf) {
+			total += entry.SignedAmount()
+		}
+	}
+	return total
+}
+
+func validateCashEntry(entry *models.CashEntry) error {
+	switch entry.Type {
+	case models.CashDeposit, models.CashWithdrawal, models.CashInterest, models.CashFee:
+	default:
+		return fmt.Errorf("unknown cash entry type %q", entry.Type)
+	}
+
+	if entry.Amount <= 0 {
+		return fmt.Errorf("the amount of a cash entry must be greater than zero")
+	}
+	if entry.Date.IsZero() {
+		return fmt.Errorf("a cash entry requires a date")
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package services
+
+import "os"
+
+// mapFile reads the whole file, since memory mapping is only supported on Unix.
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	return data, nil, err
+}
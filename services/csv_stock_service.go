@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// CSVSource is the source of the prices read from CSV files.
+const CSVSource = "csv"
+
+// csvSymbol matches the symbols that can name a file of the directory, such
+// as BRK.B or ^GSPC, and nothing that could lead out of it.
+var csvSymbol = regexp.MustCompile(`^[A-Z0-9.\-^]+$`)
+
+// CSVStockService reads daily bars from a directory with one CSV file per
+// symbol, named like AAPL.csv, whose header names the Date, Open, High, Low,
+// Close and Volume columns. Other columns are ignored and fields are not
+// quoted. Each file is indexed by date the first time it is read and bars are
+// looked up with a binary search on that index. With MemoryMap the files are
+// mapped into memory instead of read, which keeps large datasets off the heap.
+type CSVStockService struct {
+	Dir        string
+	MemoryMap  bool
+	Resolution models.DateResolution
+	Fallback   PriceFallback
+
+	mu     sync.Mutex
+	series map[string]*csvSeries
+}
+
+// csvSeries is the content of a CSV file and the position of each of its rows
+// in chronological order.
+type csvSeries struct {
+	data    []byte
+	unmap   func() error
+	columns csvColumns
+	index   []csvRow
+}
+
+type csvRow struct {
+	date       time.Time
+	start, end int
+}
+
+// csvColumns is the position of every column used in the rows of a file.
+type csvColumns struct {
+	date, open, high, low, close, volume int
+}
+
+func NewCSVStockService(dir string) *CSVStockService {
+	return &CSVStockService{
+		Dir:        dir,
+		Resolution: models.ResolvePreviousTradingDay,
+		Fallback:   FailFastFallback{},
+		series:     make(map[string]*csvSeries),
+	}
+}
+
+func (cs *CSVStockService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := cs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Open, nil
+}
+
+func (cs *CSVStockService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := cs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Close, nil
+}
+
+func (cs *CSVStockService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	return resolvePrice(ctx, cs.GetPriceHistory, symbol, date, cs.Resolution, cs.Fallback)
+}
+
+func (cs *CSVStockService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: the end date %s is before the start date %s", models.ErrValidation, to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	series, err := cs.load(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to = dateOnly(from), dateOnly(to)
+	first := sort.Search(len(series.index), func(i int) bool { return !series.index[i].date.Before(from) })
+
+	bars := []models.PriceBar{}
+	for _, row := range series.index[first:] {
+		if row.date.After(to) {
+			break
+		}
+		bar, err := series.bar(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s.csv: %w", symbol, err)
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
+
+// GetSP500Symbols returns the symbols with a CSV file, since the S&P 500 list
+// needs network access.
+func (cs *CSVStockService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(cs.Dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(paths))
+	for _, path := range paths {
+		symbols = append(symbols, strings.TrimSuffix(filepath.Base(path), ".csv"))
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+// Close releases the memory mapped files.
+func (cs *CSVStockService) Close() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var firstErr error
+	for symbol, series := range cs.series {
+		if series.unmap != nil {
+			if err := series.unmap(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		delete(cs.series, symbol)
+	}
+	return firstErr
+}
+
+// load returns the indexed file of the symbol, reading it the first time.
+// Symbols are upper case, so any case finds the same file, and a symbol that
+// is not a plain file name has no prices.
+func (cs *CSVStockService) load(symbol string) (*csvSeries, error) {
+	symbol = strings.ToUpper(symbol)
+	if !csvSymbol.MatchString(symbol) {
+		return nil, fmt.Errorf("%w for %q: not a valid symbol", ErrNoPriceData, symbol)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if series, ok := cs.series[symbol]; ok {
+		return series, nil
+	}
+
+	path := filepath.Join(cs.Dir, symbol+".csv")
+	var data []byte
+	var unmap func() error
+	var err error
+	if cs.MemoryMap {
+		data, unmap, err = mapFile(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s in %s", ErrNoPriceData, symbol, cs.Dir)
+		}
+		return nil, err
+	}
+
+	series, err := indexCSV(data)
+	if err != nil {
+		if unmap != nil {
+			unmap()
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	series.unmap = unmap
+
+	if cs.series == nil {
+		cs.series = make(map[string]*csvSeries)
+	}
+	cs.series[symbol] = series
+	return series, nil
+}
+
+// indexCSV reads the header and the date of every row.
+func indexCSV(data []byte) (*csvSeries, error) {
+	series := &csvSeries{data: data}
+
+	headerEnd := bytes.IndexByte(data, '\n')
+	if headerEnd < 0 {
+		headerEnd = len(data)
+	}
+	columns, err := parseCSVHeader(string(data[:headerEnd]))
+	if err != nil {
+		return nil, err
+	}
+	series.columns = columns
+
+	sorted := true
+	for start := headerEnd + 1; start < len(data); {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += start
+		}
+
+		line := strings.TrimSpace(string(data[start:end]))
+		if line != "" {
+			fields := strings.Split(line, ",")
+			if len(fields) <= columns.date {
+				return nil, fmt.Errorf("row %q has no date", line)
+			}
+			date, err := time.Parse("2006-01-02", strings.TrimSpace(fields[columns.date]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid date in row %q", line)
+			}
+			if n := len(series.index); n > 0 && !date.After(series.index[n-1].date) {
+				sorted = false
+			}
+			series.index = append(series.index, csvRow{date: date, start: start, end: end})
+		}
+		start = end + 1
+	}
+
+	// Files are usually in chronological order, but some sources write the newest row first
+	if !sorted {
+		sort.SliceStable(series.index, func(i, j int) bool { return series.index[i].date.Before(series.index[j].date) })
+	}
+	return series, nil
+}
+
+func parseCSVHeader(header string) (csvColumns, error) {
+	positions := make(map[string]int)
+	for i, name := range strings.Split(strings.TrimSpace(header), ",") {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var columns csvColumns
+	for name, position := range map[string]*int{
+		"date":   &columns.date,
+		"open":   &columns.open,
+		"high":   &columns.high,
+		"low":    &columns.low,
+		"close":  &columns.close,
+		"volume": &columns.volume,
+	} {
+		i, ok := positions[name]
+		if !ok {
+			return csvColumns{}, fmt.Errorf("missing %s column in header %q", name, header)
+		}
+		*position = i
+	}
+	return columns, nil
+}
+
+// bar parses the row.
+func (s *csvSeries) bar(row csvRow) (models.PriceBar, error) {
+	line := strings.TrimSpace(string(s.data[row.start:row.end]))
+	fields := strings.Split(line, ",")
+
+	bar := models.PriceBar{Date: row.date, Source: CSVSource}
+	for _, column := range []struct {
+		position int
+		value    *float64
+	}{
+		{s.columns.open, &bar.Open},
+		{s.columns.high, &bar.High},
+		{s.columns.low, &bar.Low},
+		{s.columns.close, &bar.Close},
+	} {
+		if column.position >= len(fields) {
+			return models.PriceBar{}, fmt.Errorf("row %q has too few columns", line)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[column.position]), 64)
+		if err != nil {
+			return models.PriceBar{}, fmt.Errorf("invalid price in row %q", line)
+		}
+		*column.value = value
+	}
+
+	if s.columns.volume >= len(fields) {
+		return models.PriceBar{}, fmt.Errorf("row %q has too few columns", line)
+	}
+	// Some sources write the volume as a float
+	volume, err := strconv.ParseFloat(strings.TrimSpace(fields[s.columns.volume]), 64)
+	if err != nil {
+		return models.PriceBar{}, fmt.Errorf("invalid volume in row %q", line)
+	}
+	bar.Volume = int64(volume)
+
+	return bar, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCSVStockService(t *testing.T, memoryMap bool) *CSVStockService {
+	cs := NewCSVStockService(filepath.Join("testdata", "csv"))
+	cs.MemoryMap = memoryMap
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+// TestCSVStockService_GetPriceHistory test GetPriceHistory() reading and mapping the files
+func TestCSVStockService_GetPriceHistory(t *testing.T) {
+	ctx := context.Background()
+	for _, memoryMap := range []bool{false, true} {
+		cs := newTestCSVStockService(t, memoryMap)
+
+		bars, err := cs.GetPriceHistory(ctx, "AAPL", date(2021, 1, 13), date(2021, 1, 19))
+		require.NoError(t, err)
+		require.Len(t, bars, 4)
+		require.Equal(t, date(2021, 1, 13), bars[0].Date)
+		require.Equal(t, models.PriceBar{Date: date(2021, 1, 19), Open: 127.78, High: 128.71, Low: 126.94, Close: 127.83, Volume: 90757300, Source: CSVSource}, bars[3])
+
+		// Rows in reverse order, lower case headers, CRLF line endings and float volumes
+		bars, err = cs.GetPriceHistory(ctx, "MSFT", date(2021, 1, 1), date(2021, 1, 31))
+		require.NoError(t, err)
+		require.Len(t, bars, 3)
+		require.Equal(t, date(2021, 1, 15), bars[0].Date)
+		require.Equal(t, int64(37777300), bars[2].Volume)
+
+		bars, err = cs.GetPriceHistory(ctx, "AAPL", date(2021, 2, 1), date(2021, 2, 5))
+		require.NoError(t, err)
+		require.Empty(t, bars)
+	}
+}
+
+// TestCSVStockService_GetResolvedPrice test that prices are looked up and resolved
+func TestCSVStockService_GetResolvedPrice(t *testing.T) {
+	ctx := context.Background()
+	cs := newTestCSVStockService(t, true)
+
+	closePrice, err := cs.GetPriceClose(ctx, "AAPL", date(2021, 1, 15))
+	require.NoError(t, err)
+	require.Equal(t, 127.14, closePrice)
+
+	open, err := cs.GetPriceOpen(ctx, "AAPL", date(2021, 1, 12))
+	require.NoError(t, err)
+	require.Equal(t, 128.5, open)
+
+	price, err := cs.GetResolvedPrice(ctx, "AAPL", date(2021, 1, 18))
+	require.NoError(t, err)
+	require.True(t, price.Adjusted())
+	require.Equal(t, date(2021, 1, 15), price.Date)
+
+	_, err = cs.GetPriceClose(ctx, "GOOG", date(2021, 1, 15))
+	require.Error(t, err)
+}
+
+// TestCSVStockService_Symbols test that symbols find their file in any case and cannot name a file outside the directory
+func TestCSVStockService_Symbols(t *testing.T) {
+	ctx := context.Background()
+	data, err := os.ReadFile(filepath.Join("testdata", "csv", "AAPL.csv"))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "prices"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prices", "AAPL.csv"), data, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "OUTSIDE.csv"), data, 0o644))
+
+	cs := NewCSVStockService(filepath.Join(dir, "prices"))
+	t.Cleanup(func() { cs.Close() })
+
+	closePrice, err := cs.GetPriceClose(ctx, "aapl", date(2021, 1, 15))
+	require.NoError(t, err)
+	require.Equal(t, 127.14, closePrice)
+	_, err = cs.GetPriceClose(ctx, "AAPL", date(2021, 1, 15))
+	require.NoError(t, err)
+	require.Len(t, cs.series, 1)
+
+	for _, symbol := range []string{"../OUTSIDE", "..\\OUTSIDE", "prices/../../OUTSIDE", ""} {
+		_, err := cs.GetPriceHistory(ctx, symbol, date(2021, 1, 13), date(2021, 1, 19))
+		require.ErrorIs(t, err, ErrNoPriceData, symbol)
+	}
+}
+
+// TestCSVStockService_GetSP500Symbols test that the symbols are the files of the directory
+func TestCSVStockService_GetSP500Symbols(t *testing.T) {
+	ctx := context.Background()
+	cs := newTestCSVStockService(t, false)
+
+	symbols, err := cs.GetSP500Symbols(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"AAPL", "MSFT"}, symbols)
+}
+
+// TestCSVStockService_PortfolioGains test that a portfolio can be valued from a frozen dataset
+func TestCSVStockService_PortfolioGains(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	portfolio := &models.Portfolio{
+		ID:     1,
+		Name:   "Offline",
+		Stocks: []models.Stock{{Symbol: "AAPL", Quantity: 10, BuyDate: date(2021, 1, 11), BuyPrice: 128.98}},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{}, nil)
+	ps := NewPortfolioService(mockRepo, newTestCSVStockService(t, false))
+
+	gains, err := ps.CalculateGains(ctx, portfolio, date(2021, 1, 20))
+	require.NoError(t, err)
+	require.InDelta(t, 10*(132.03-128.98), gains.Unrealized, 1e-9)
+}
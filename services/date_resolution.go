@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// resolutionWindowDays is how far a date is resolved, which covers long
+// weekends and the longest market closures.
+const resolutionWindowDays = 10
+
+// ParseDateResolution parses the name of a date resolution, case insensitively.
+func ParseDateResolution(value string) (models.DateResolution, error) {
+	resolution := models.DateResolution(strings.ToLower(strings.TrimSpace(value)))
+	for _, supported := range models.DateResolutions {
+		if resolution == supported {
+			return resolution, nil
+		}
+	}
+	return "", fmt.Errorf("unknown date resolution %q, expected previous, next or strict", value)
+}
+
+// resolutionWindow returns the period whose bars are needed to resolve the date.
+func resolutionWindow(date time.Time, resolution models.DateResolution) (time.Time, time.Time) {
+	date = dateOnly(date)
+	switch resolution {
+	case models.ResolveNextTradingDay:
+		return date, date.AddDate(0, 0, resolutionWindowDays)
+	case models.ResolveStrict:
+		return date, date
+	default:
+		return date.AddDate(0, 0, -resolutionWindowDays), date
+	}
+}
+
+// resolveBar picks the bar of the date or, when the date had no trading, the
+// bar of the closest trading day in the direction of the resolution. An empty
+// resolution resolves to the previous trading day.
+func resolveBar(symbol string, bars []models.PriceBar, date time.Time, resolution models.DateResolution) (*models.ResolvedPrice, error) {
+	date = dateOnly(date)
+	if resolution == "" {
+		resolution = models.ResolvePreviousTradingDay
+	}
+
+	var resolved *models.PriceBar
+	for i := range bars {
+		bar := &bars[i]
+		switch {
+		case bar.Date.Equal(date):
+			return &models.ResolvedPrice{RequestedDate: date, PriceBar: *bar}, nil
+		case resolution == models.ResolvePreviousTradingDay && bar.Date.Before(date):
+			if resolved == nil || bar.Date.After(resolved.Date) {
+				resolved = bar
+			}
+		case resolution == models.ResolveNextTradingDay && bar.Date.After(date):
+			if resolved == nil || bar.Date.Before(resolved.Date) {
+				resolved = bar
+			}
+		}
+	}
+
+	if resolved == nil {
+		if resolution == models.ResolveStrict {
+			return nil, fmt.Errorf("%w for %s on %s", ErrNoPriceData, symbol, date.Format("2006-01-02"))
+		}
+		return nil, fmt.Errorf("%w for %s within %d days of %s", ErrNoPriceData, symbol, resolutionWindowDays, date.Format("2006-01-02"))
+	}
+	return &models.ResolvedPrice{RequestedDate: date, PriceBar: *resolved}, nil
+}
+
+// resolvePrice resolves the date from the bars returned by history and asks
+// the fallback, when there is one, for the prices it cannot resolve unless
+// ctx is done.
+func resolvePrice(ctx context.Context, history func(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error), symbol string, date time.Time, resolution models.DateResolution, fallback PriceFallback) (*models.ResolvedPrice, error) {
+	from, to := resolutionWindow(date, resolution)
+	bars, err := history(ctx, symbol, from, to)
+	if err == nil {
+		var price *models.ResolvedPrice
+		if price, err = resolveBar(symbol, bars, date, resolution); err == nil {
+			return price, nil
+		}
+	}
+	if fallback == nil || ctx.Err() != nil {
+		return nil, err
+	}
+	price, err := fallback.FallbackPrice(ctx, symbol, date, err)
+	if err != nil && ctx.Err() == nil {
+		return nil, priceUnavailable(symbol, date, err)
+	}
+	return price, err
+}
+
+// dateOnly drops the time of day, since prices are daily.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveBar test the trading day picked by every resolution
+func TestResolveBar(t *testing.T) {
+	// Thursday, Friday and the Tuesday after a long weekend.
+	bars := []models.PriceBar{
+		{Date: date(2021, 1, 14), Close: 128.91},
+		{Date: date(2021, 1, 15), Close: 127.14},
+		{Date: date(2021, 1, 19), Close: 127.83},
+	}
+
+	price, err := resolveBar("AAPL", bars, date(2021, 1, 15), models.ResolveStrict)
+	require.NoError(t, err)
+	require.False(t, price.Adjusted())
+	require.Equal(t, 127.14, price.Close)
+
+	price, err = resolveBar("AAPL", bars, date(2021, 1, 18), models.ResolvePreviousTradingDay)
+	require.NoError(t, err)
+	require.True(t, price.Adjusted())
+	require.Equal(t, date(2021, 1, 15), price.Date)
+
+	price, err = resolveBar("AAPL", bars, date(2021, 1, 16), models.ResolveNextTradingDay)
+	require.NoError(t, err)
+	require.Equal(t, date(2021, 1, 19), price.Date)
+
+	_, err = resolveBar("AAPL", bars, date(2021, 1, 18), models.ResolveStrict)
+	require.Error(t, err)
+
+	_, err = resolveBar("AAPL", bars, date(2021, 1, 20), models.ResolveNextTradingDay)
+	require.Error(t, err)
+}
+
+// TestParseDateResolution test ParseDateResolution()
+func TestParseDateResolution(t *testing.T) {
+	resolution, err := ParseDateResolution(" Next ")
+	require.NoError(t, err)
+	require.Equal(t, models.ResolveNextTradingDay, resolution)
+
+	_, err = ParseDateResolution("nearest")
+	require.Error(t, err)
+}
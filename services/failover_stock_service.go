@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+const (
+	// DefaultFailureThreshold is how many consecutive failures make a provider unhealthy.
+	DefaultFailureThreshold = 3
+	// DefaultBaseBackoff is how long an unhealthy provider is skipped the first time.
+	DefaultBaseBackoff = 30 * time.Second
+	// DefaultMaxBackoff caps the backoff of a provider that keeps failing.
+	DefaultMaxBackoff = 30 * time.Minute
+)
+
+// NamedProvider is a price provider of a failover chain.
+type NamedProvider struct {
+	Name    string
+	Service StockServiceInterface
+}
+
+// FailoverStockService asks its providers in order and returns the first
+// answer. A provider becomes unhealthy after FailureThreshold consecutive
+// failures, or at once when it reports its rate limit, and is skipped until
+// its backoff expires. The backoff doubles every time the provider becomes
+// unhealthy again, up to MaxBackoff, and a single failure is enough once it
+// was unhealthy. A rate limited provider is skipped at least for the time it
+// asks in Retry-After. A provider refusing its configuration is skipped for
+// MaxBackoff at once, since retrying does not help. A provider without the price, or without bars for a
+// period, does not count as a failure and the next provider is asked. Prices no provider could return are asked to Fallback, so the
+// providers of the chain should fail fast.
+type FailoverStockService struct {
+	Providers        []NamedProvider
+	FailureThreshold int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	Fallback         PriceFallback
+	Now              func() time.Time
+
+	mu     sync.Mutex
+	health map[string]*providerHealth
+}
+
+// providerHealth tracks the failures of a provider. Trips counts how many
+// times in a row it became unhealthy, which sets its next backoff.
+type providerHealth struct {
+	failures int
+	trips    int
+	retryAt  time.Time
+}
+
+func NewFailoverStockService(providers ...NamedProvider) *FailoverStockService {
+	return &FailoverStockService{
+		Providers:        providers,
+		FailureThreshold: DefaultFailureThreshold,
+		BaseBackoff:      DefaultBaseBackoff,
+		MaxBackoff:       DefaultMaxBackoff,
+		Fallback:         FailFastFallback{},
+		Now:              time.Now,
+		health:           make(map[string]*providerHealth),
+	}
+}
+
+func (fs *FailoverStockService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := fs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Open, nil
+}
+
+func (fs *FailoverStockService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := fs.GetResolvedPrice(ctx, symbol, date)
+	if err != nil {
+		return 0, err
+	}
+	return price.Close, nil
+}
+
+// GetResolvedPrice returns the price of the first provider that has it, with
+// the provider as its source.
+func (fs *FailoverStockService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	var price *models.ResolvedPrice
+	source, err := fs.try(ctx, func(provider StockServiceInterface) error {
+		var err error
+		price, err = provider.GetResolvedPrice(ctx, symbol, date)
+		return err
+	})
+	if err != nil {
+		if fs.Fallback == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		price, err := fs.Fallback.FallbackPrice(ctx, symbol, date, err)
+		if err != nil && ctx.Err() == nil {
+			return nil, priceUnavailable(symbol, date, err)
+		}
+		return price, err
+	}
+
+	if price.Source == "" {
+		price.Source = source
+	}
+	return price, nil
+}
+
+func (fs *FailoverStockService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	var bars []models.PriceBar
+	source, err := fs.try(ctx, func(provider StockServiceInterface) error {
+		var err error
+		bars, err = provider.GetPriceHistory(ctx, symbol, from, to)
+		if err == nil && len(bars) == 0 {
+			// Another provider may have the bars during an outage of this one
+			return fmt.Errorf("%w for %s between %s and %s", ErrNoPriceData, symbol, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range bars {
+		if bars[i].Source == "" {
+			bars[i].Source = source
+		}
+	}
+	return bars, nil
+}
+
+func (fs *FailoverStockService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	var symbols []string
+	_, err := fs.try(ctx, func(provider StockServiceInterface) error {
+		var err error
+		symbols, err = provider.GetSP500Symbols(ctx)
+		return err
+	})
+	return symbols, err
+}
+
+// try calls the healthy providers in order until one succeeds and returns its
+// name, or the errors of all of them. It stops with the error of ctx once ctx
+// is done, which counts against no provider.
+func (fs *FailoverStockService) try(ctx context.Context, call func(provider StockServiceInterface) error) (string, error) {
+	var errs []error
+	for _, provider := range fs.Providers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if retryAt, healthy := fs.available(provider.Name); !healthy {
+			errs = append(errs, fmt.Errorf("%s: unhealthy until %s", provider.Name, retryAt.Format(time.RFC3339)))
+			continue
+		}
+
+		err := call(provider.Service)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		fs.record(provider.Name, err)
+		if err == nil {
+			return provider.Name, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+	}
+
+	if len(errs) == 0 {
+		return "", fmt.Errorf("no price provider configured")
+	}
+	return "", errors.Join(errs...)
+}
+
+// available reports whether the provider can be called, and otherwise when.
+func (fs *FailoverStockService) available(name string) (time.Time, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	health, ok := fs.health[name]
+	if !ok || !fs.Now().Before(health.retryAt) {
+		return time.Time{}, true
+	}
+	return health.retryAt, false
+}
+
+// record updates the health of the provider with the result of a call.
+func (fs *FailoverStockService) record(name string, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.health == nil {
+		fs.health = make(map[string]*providerHealth)
+	}
+	health, ok := fs.health[name]
+	if !ok {
+		health = &providerHealth{}
+		fs.health[name] = health
+	}
+
+	switch {
+	case err == nil:
+		*health = providerHealth{}
+	case errors.Is(err, ErrNoPriceData):
+		// The provider answered, it just does not have the price
+	default:
+		// A provider retried after its backoff becomes unhealthy again on its first failure
+		health.failures++
+		if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrProviderConfig) || health.trips > 0 || health.failures >= fs.FailureThreshold {
+			health.trips++
+			health.failures = 0
+			health.retryAt = fs.Now().Add(fs.backoff(health.trips))
+
+			// Retrying a misconfigured provider fails the same way
+			if errors.Is(err, ErrProviderConfig) {
+				health.retryAt = fs.Now().Add(fs.MaxBackoff)
+			}
+
+			// The provider may ask for a longer pause
+			var limited *RateLimitError
+			if errors.As(err, &limited) && fs.Now().Add(limited.RetryAfter).After(health.retryAt) {
+				health.retryAt = fs.Now().Add(limited.RetryAfter)
+			}
+		}
+	}
+}
+
+// backoff doubles the base backoff for every trip after the first.
+func (fs *FailoverStockService) backoff(trips int) time.Duration {
+	backoff := fs.BaseBackoff
+	for i := 1; i < trips && backoff < fs.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > fs.MaxBackoff {
+		backoff = fs.MaxBackoff
+	}
+	return backoff
+}
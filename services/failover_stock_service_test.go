@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFailoverStockService(now *time.Time) (*FailoverStockService, *MockStockService, *MockStockService) {
+	primary := new(MockStockService)
+	secondary := new(MockStockService)
+	fs := NewFailoverStockService(
+		NamedProvider{Name: "fmp", Service: primary},
+		NamedProvider{Name: "csv", Service: secondary},
+	)
+	fs.Now = func() time.Time { return *now }
+	return fs, primary, secondary
+}
+
+// TestFailoverStockService_Failover test that the next provider serves the price and is recorded as its source
+func TestFailoverStockService_Failover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	day := date(2021, 1, 15)
+	primary.On("GetResolvedPrice", "AAPL", day).Return((*models.ResolvedPrice)(nil), errors.New("API request failed with status code 500"))
+	secondary.On("GetResolvedPrice", "AAPL", day).Return(&models.ResolvedPrice{RequestedDate: day, PriceBar: models.PriceBar{Date: day, Close: 127.14}}, nil)
+
+	price, err := fs.GetResolvedPrice(ctx, "AAPL", day)
+	require.NoError(t, err)
+	require.Equal(t, 127.14, price.Close)
+	require.Equal(t, "csv", price.Source)
+
+	// Bars of the history are stamped with the provider too
+	primary.On("GetPriceHistory", "MSFT", day, day).Return([]models.PriceBar{{Date: day, Close: 212.65}}, nil)
+	bars, err := fs.GetPriceHistory(ctx, "MSFT", day, day)
+	require.NoError(t, err)
+	require.Equal(t, "fmp", bars[0].Source)
+}
+
+// TestFailoverStockService_EmptyHistory test that a provider without bars for the period passes the history to the next one
+func TestFailoverStockService_EmptyHistory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	from, to := date(2021, 1, 14), date(2021, 1, 15)
+	primary.On("GetPriceHistory", "AAPL", from, to).Return([]models.PriceBar{}, nil)
+	secondary.On("GetPriceHistory", "AAPL", from, to).Return([]models.PriceBar{{Date: from, Close: 128.91}, {Date: to, Close: 127.14}}, nil)
+
+	bars, err := fs.GetPriceHistory(ctx, "AAPL", from, to)
+	require.NoError(t, err)
+	require.Len(t, bars, 2)
+	require.Equal(t, "csv", bars[0].Source)
+
+	// No provider with bars is a missing price, which keeps the providers healthy
+	secondary.On("GetPriceHistory", "MSFT", from, to).Return([]models.PriceBar(nil), nil)
+	primary.On("GetPriceHistory", "MSFT", from, to).Return([]models.PriceBar{}, nil)
+	_, err = fs.GetPriceHistory(ctx, "MSFT", from, to)
+	require.ErrorIs(t, err, ErrNoPriceData)
+	require.Zero(t, fs.health["fmp"].failures)
+}
+
+// TestFailoverStockService_Backoff test that a failing provider is skipped with an exponential backoff
+func TestFailoverStockService_Backoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	day := date(2021, 1, 15)
+	primary.On("GetResolvedPrice", "AAPL", day).Return((*models.ResolvedPrice)(nil), errors.New("timeout"))
+	secondary.On("GetResolvedPrice", "AAPL", day).Return(&models.ResolvedPrice{RequestedDate: day, PriceBar: models.PriceBar{Date: day, Close: 127.14}}, nil)
+
+	for i := 0; i < DefaultFailureThreshold+2; i++ {
+		_, err := fs.GetPriceClose(ctx, "AAPL", day)
+		require.NoError(t, err)
+	}
+	primary.AssertNumberOfCalls(t, "GetResolvedPrice", DefaultFailureThreshold)
+
+	// Once the backoff expires the provider is tried again, and failing doubles the backoff
+	now = now.Add(DefaultBaseBackoff)
+	_, err := fs.GetPriceClose(ctx, "AAPL", day)
+	require.NoError(t, err)
+	primary.AssertNumberOfCalls(t, "GetResolvedPrice", DefaultFailureThreshold+1)
+	require.Equal(t, now.Add(2*DefaultBaseBackoff), fs.health["fmp"].retryAt)
+
+	require.Equal(t, DefaultMaxBackoff, fs.backoff(20))
+}
+
+// TestFailoverStockService_RateLimit test that a rate limited provider is skipped at once
+func TestFailoverStockService_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	day := date(2021, 1, 15)
+	primary.On("GetResolvedPrice", "AAPL", day).Return((*models.ResolvedPrice)(nil), &RateLimitError{Provider: "Financial Modeling Prep", RetryAfter: time.Hour})
+	secondary.On("GetResolvedPrice", "AAPL", day).Return(&models.ResolvedPrice{RequestedDate: day, PriceBar: models.PriceBar{Date: day, Close: 127.14}}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := fs.GetResolvedPrice(ctx, "AAPL", day)
+		require.NoError(t, err)
+	}
+	primary.AssertNumberOfCalls(t, "GetResolvedPrice", 1)
+
+	// The Retry-After of the provider is longer than the backoff
+	require.Equal(t, now.Add(time.Hour), fs.health["fmp"].retryAt)
+}
+
+// TestFailoverStockService_ProviderConfig test that a misconfigured provider is skipped at once for the longest backoff
+func TestFailoverStockService_ProviderConfig(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	day := date(2021, 1, 15)
+	primary.On("GetResolvedPrice", "AAPL", day).Return((*models.ResolvedPrice)(nil), fmt.Errorf("%w: status code 401", ErrProviderConfig))
+	secondary.On("GetResolvedPrice", "AAPL", day).Return(&models.ResolvedPrice{RequestedDate: day, PriceBar: models.PriceBar{Date: day, Close: 127.14}}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := fs.GetResolvedPrice(ctx, "AAPL", day)
+		require.NoError(t, err)
+	}
+	primary.AssertNumberOfCalls(t, "GetResolvedPrice", 1)
+	require.Equal(t, now.Add(DefaultMaxBackoff), fs.health["fmp"].retryAt)
+}
+
+// TestFailoverStockService_Fallback test that the fallback is asked once when every provider fails
+func TestFailoverStockService_Fallback(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	fs, primary, secondary := newTestFailoverStockService(&now)
+
+	day := date(2021, 1, 15)
+	primary.On("GetResolvedPrice", "GOOG", day).Return((*models.ResolvedPrice)(nil), errors.New("timeout"))
+	secondary.On("GetResolvedPrice", "GOOG", day).Return((*models.ResolvedPrice)(nil), fmt.Errorf("%w for GOOG", ErrNoPriceData))
+
+	_, err := fs.GetResolvedPrice(ctx, "GOOG", day)
+	require.ErrorIs(t, err, ErrNoPriceData)
+	require.Contains(t, err.Error(), "fmp: timeout")
+
+	fs.Fallback = fallbackFunc(func(symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+		return enteredPrice(date, 1500, "manual"), nil
+	})
+	price, err := fs.GetResolvedPrice(ctx, "GOOG", day)
+	require.NoError(t, err)
+	require.Equal(t, "manual", price.Source)
+
+	// Missing prices do not make the provider unhealthy
+	for i := 0; i < DefaultFailureThreshold; i++ {
+		fs.GetResolvedPrice(ctx, "GOOG", day)
+	}
+	secondary.AssertNumberOfCalls(t, "GetResolvedPrice", DefaultFailureThreshold+2)
+}
+
+type fallbackFunc func(symbol string, date time.Time, cause error) (*models.ResolvedPrice, error)
+
+func (f fallbackFunc) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	return f(symbol, date, cause)
+}
@@ -1,121 +1,151 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"net/http"
+	"sort"
 	"time"
 
 	"github.com/fcopulgar/stock-manager-go/api"
+	"github.com/fcopulgar/stock-manager-go/models"
 	"github.com/go-resty/resty/v2"
 )
 
+// FMPSource is the source of the prices of Financial Modeling Prep.
+const FMPSource = "fmp"
+
+// The request limits of the free plan of Financial Modeling Prep.
+const (
+	DefaultFMPRequestsPerMinute = 300
+	DefaultFMPRequestsPerDay    = 250
+)
+
+// FinancialModelingPrepService fetches prices from Financial Modeling Prep.
+// Dates without trading are resolved to another trading day according to
+// Resolution, the previous one by default. Prices that cannot be fetched are
+// asked to Fallback, and a nil Fallback returns the error.
 type FinancialModelingPrepService struct {
-	APIKey  string
-	Client  *resty.Client
-	BaseURL string
+	APIKey     string
+	Client     *resty.Client
+	BaseURL    string
+	Resolution models.DateResolution
+	Fallback   PriceFallback
 }
 
-type StockPrices struct {
-	Open  float64
-	Close float64
+func NewFinancialModelingPrepService(apiKey string) *FinancialModelingPrepService {
+	return NewFinancialModelingPrepServiceWithOptions(apiKey, DefaultClientOptions())
 }
 
-func NewFinancialModelingPrepService(apiKey string) *FinancialModelingPrepService {
-	client := resty.New()
-	client.SetBaseURL("https://financialmodelingprep.com")
+// NewFinancialModelingPrepServiceWithOptions configures the timeout, retries
+// and rate limit of the client.
+func NewFinancialModelingPrepServiceWithOptions(apiKey string, options ClientOptions) *FinancialModelingPrepService {
 	return &FinancialModelingPrepService{
-		APIKey: apiKey,
-		Client: client,
+		APIKey:     apiKey,
+		Client:     newClient("https://financialmodelingprep.com", options),
+		Resolution: models.ResolvePreviousTradingDay,
+		Fallback:   FailFastFallback{},
 	}
 }
 
-func (fmp *FinancialModelingPrepService) GetPriceOpen(symbol string, date time.Time) (float64, error) {
-	dateStr := date.Format("2006-01-02")
-
-	stockPrices, err := fmp.fetchStockPrices(symbol, date)
+func (fmp *FinancialModelingPrepService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := fmp.GetResolvedPrice(ctx, symbol, date)
 	if err != nil {
-		fmt.Printf("Failed to retrieve open price for %s on %s.\n", symbol, dateStr)
-		return fmp.promptUserForPrice(symbol, date, "open")
+		return 0, err
 	}
-
-	return stockPrices.Open, nil
+	return price.Open, nil
 }
 
-func (fmp *FinancialModelingPrepService) GetPriceClose(symbol string, date time.Time) (float64, error) {
-	dateStr := date.Format("2006-01-02")
-
-	stockPrices, err := fmp.fetchStockPrices(symbol, date)
+func (fmp *FinancialModelingPrepService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	price, err := fmp.GetResolvedPrice(ctx, symbol, date)
 	if err != nil {
-		fmt.Printf("Failed to retrieve close price for %s on %s. %s\n", symbol, dateStr, err)
-		return fmp.promptUserForPrice(symbol, date, "close")
+		return 0, err
 	}
+	return price.Close, nil
+}
 
-	return stockPrices.Close, nil
+// GetResolvedPrice returns the bar of the date, or of the trading day chosen
+// by the resolution when the date had no trading.
+func (fmp *FinancialModelingPrepService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	return resolvePrice(ctx, fmp.fetchPriceHistory, symbol, date, fmp.Resolution, fmp.Fallback)
 }
 
-func (fmp *FinancialModelingPrepService) GetSP500Symbols() ([]string, error) {
-	return api.GetSP500Symbols(&api.DefaultHTTPClient{})
+// GetPriceHistory returns the daily bars of the symbol between from and to
+// with a single ranged request.
+func (fmp *FinancialModelingPrepService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: the end date %s is before the start date %s", models.ErrValidation, to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	return fmp.fetchPriceHistory(ctx, symbol, from, to)
 }
 
-func (fmp *FinancialModelingPrepService) fetchStockPrices(symbol string, date time.Time) (StockPrices, error) {
-	dateStr := date.Format("2006-01-02")
-	endDateStr := date.AddDate(0, 0, 1).Format("2006-01-02")
+func (fmp *FinancialModelingPrepService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	return api.GetSP500Symbols(ctx, &api.DefaultHTTPClient{})
+}
 
+func (fmp *FinancialModelingPrepService) fetchPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
 	path := fmt.Sprintf("/api/v3/historical-price-full/%s?from=%s&to=%s&apikey=%s",
-		symbol, dateStr, endDateStr, fmp.APIKey)
+		symbol, from.Format("2006-01-02"), to.Format("2006-01-02"), fmp.APIKey)
 
-	resp, err := fmp.Client.R().Get(path)
+	resp, err := fmp.Client.R().SetContext(ctx).Get(path)
 	if err != nil {
-		return StockPrices{}, err
+		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return nil, &RateLimitError{Provider: "Financial Modeling Prep", RetryAfter: retryAfter(resp, time.Now())}
+	}
+	// A bad or missing API key, or an endpoint outside the plan, fails until the configuration changes
+	if resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == http.StatusForbidden {
+		return nil, fmt.Errorf("%w: Financial Modeling Prep refused the API key with status code %d", ErrProviderConfig, resp.StatusCode())
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w for %s", ErrNoPriceData, symbol)
+	}
 	if resp.IsError() {
-		return StockPrices{}, fmt.Errorf("API request failed with status code %d", resp.StatusCode())
+		return nil, fmt.Errorf("API request failed with status code %d", resp.StatusCode())
 	}
 
 	var result struct {
 		Symbol     string `json:"symbol"`
 		Historical []struct {
-			Date  string  `json:"date"`
-			Open  float64 `json:"open"`
-			Close float64 `json:"close"`
+			Date   string  `json:"date"`
+			Open   float64 `json:"open"`
+			High   float64 `json:"high"`
+			Low    float64 `json:"low"`
+			Close  float64 `json:"close"`
+			Volume float64 `json:"volume"`
 		} `json:"historical"`
 	}
 
 	err = json.Unmarshal(resp.Body(), &result)
 	if err != nil {
-		return StockPrices{}, err
+		return nil, err
 	}
-
+	// A symbol or period without prices comes back with status 200 and no bars
 	if len(result.Historical) == 0 {
-		return StockPrices{}, fmt.Errorf("no price data available for %s on %s", symbol, dateStr)
-	}
-
-	historicalData := result.Historical[0]
-	stockPrices := StockPrices{
-		Open:  historicalData.Open,
-		Close: historicalData.Close,
+		return nil, fmt.Errorf("%w for %s between %s and %s", ErrNoPriceData, symbol, from.Format("2006-01-02"), to.Format("2006-01-02"))
 	}
 
-	return stockPrices, nil
-}
-
-func (fmp *FinancialModelingPrepService) promptUserForPrice(symbol string, date time.Time, priceType string) (float64, error) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("Please enter the %s price for %s on %s: ", priceType, symbol, date.Format("2006-01-02"))
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		return 0, err
-	}
-	input = strings.TrimSpace(input)
-	price, err := strconv.ParseFloat(input, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid price input")
+	bars := make([]models.PriceBar, 0, len(result.Historical))
+	for _, historical := range result.Historical {
+		date, err := time.Parse("2006-01-02", historical.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in the price history of %s", historical.Date, symbol)
+		}
+		bars = append(bars, models.PriceBar{
+			Date:   date,
+			Open:   historical.Open,
+			High:   historical.High,
+			Low:    historical.Low,
+			Close:  historical.Close,
+			Volume: int64(historical.Volume),
+			Source: FMPSource,
+		})
 	}
 
-	return price, nil
+	// The API returns the most recent bar first
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	return bars, nil
 }
@@ -1,12 +1,20 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/repositories"
 	"github.com/go-resty/resty/v2"
 )
 
@@ -21,6 +29,7 @@ type fmpHistoricalResponse struct {
 }
 
 func TestFinancialModelingPrepService_GetPriceOpen(t *testing.T) {
+	ctx := context.Background()
 	// Create a test server that simulates the Financial Modeling Prep response.
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// We simulate a response with a history with one day
@@ -50,7 +59,7 @@ func TestFinancialModelingPrepService_GetPriceOpen(t *testing.T) {
 
 	// Test GetPriceOpen
 	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
-	price, err := fmp.GetPriceOpen("AAPL", date)
+	price, err := fmp.GetPriceOpen(ctx, "AAPL", date)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -60,6 +69,7 @@ func TestFinancialModelingPrepService_GetPriceOpen(t *testing.T) {
 }
 
 func TestFinancialModelingPrepService_GetPriceClose(t *testing.T) {
+	ctx := context.Background()
 	// Create a test server that simulates the response for GetPriceClose
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := fmpHistoricalResponse{
@@ -86,7 +96,7 @@ func TestFinancialModelingPrepService_GetPriceClose(t *testing.T) {
 	fmp.Client.SetBaseURL(ts.URL)
 
 	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
-	price, err := fmp.GetPriceClose("AAPL", date)
+	price, err := fmp.GetPriceClose(ctx, "AAPL", date)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -96,6 +106,7 @@ func TestFinancialModelingPrepService_GetPriceClose(t *testing.T) {
 }
 
 func TestFinancialModelingPrepService_GetPriceClose_APIError(t *testing.T) {
+	ctx := context.Background()
 	// Simulate an error in the API (e.g. return 500)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -110,8 +121,358 @@ func TestFinancialModelingPrepService_GetPriceClose_APIError(t *testing.T) {
 	fmp.Client.SetBaseURL(ts.URL)
 
 	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
-	_, err := fmp.GetPriceClose("AAPL", date)
+	_, err := fmp.GetPriceClose(ctx, "AAPL", date)
 	if err == nil {
 		t.Fatalf("Expected an error due to API error, got none")
 	}
 }
+
+func TestFinancialModelingPrepService_PriceUnavailable(t *testing.T) {
+	ctx := context.Background()
+	status := http.StatusNotFound
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", status)
+	}))
+	defer ts.Close()
+
+	fmp := NewFinancialModelingPrepServiceWithOptions("dummykey", ClientOptions{Timeout: time.Second})
+	fmp.Client.SetBaseURL(ts.URL)
+	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	// An unknown symbol has no price data
+	_, err := fmp.GetPriceClose(ctx, "NOPE", date)
+	if !errors.Is(err, ErrNoPriceData) || !errors.Is(err, models.ErrPriceUnavailable) {
+		t.Fatalf("Expected no price data, got %v", err)
+	}
+
+	// Any other failure is reported as an unavailable price too
+	status = http.StatusInternalServerError
+	_, err = fmp.GetPriceClose(ctx, "AAPL", date)
+	if !errors.Is(err, models.ErrPriceUnavailable) || errors.Is(err, ErrNoPriceData) {
+		t.Fatalf("Expected an unavailable price, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "status code 500") {
+		t.Errorf("Expected the error of the API to be kept, got %v", err)
+	}
+}
+
+func TestFinancialModelingPrepService_EmptyHistory(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[]}`))
+	}))
+	defer ts.Close()
+
+	fmp := NewFinancialModelingPrepServiceWithOptions("dummykey", ClientOptions{Timeout: time.Second})
+	fmp.Client.SetBaseURL(ts.URL)
+
+	// A period without bars is missing data, so that failover asks the next provider
+	_, err := fmp.GetPriceHistory(ctx, "AAPL", time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrNoPriceData) {
+		t.Fatalf("Expected no price data, got %v", err)
+	}
+}
+
+func TestFinancialModelingPrepService_GetPriceHistory(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/v3/historical-price-full/AAPL" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("from") != "2020-01-13" || r.URL.Query().Get("to") != "2020-01-15" {
+			t.Errorf("Unexpected range %s", r.URL.RawQuery)
+		}
+
+		// The API returns the most recent bar first
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[
+			{"date":"2020-01-15","open":311.85,"high":315.5,"low":309.55,"close":311.34,"volume":3.0480900E7},
+			{"date":"2020-01-14","open":316.7,"high":317.57,"low":312.17,"close":312.68,"volume":40653457},
+			{"date":"2020-01-13","open":311.64,"high":317.07,"low":311.15,"close":316.96,"volume":30028742}
+		]}`))
+	}))
+	defer ts.Close()
+
+	fmp := &FinancialModelingPrepService{
+		APIKey: "dummykey",
+		Client: resty.New(),
+	}
+
+	fmp.Client.SetBaseURL(ts.URL)
+
+	from := time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	bars, err := fmp.GetPriceHistory(ctx, "AAPL", from, to)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request, got %d", requests)
+	}
+	if len(bars) != 3 {
+		t.Fatalf("Expected 3 bars, got %d", len(bars))
+	}
+	if !bars[0].Date.Equal(from) || !bars[2].Date.Equal(to) {
+		t.Errorf("Expected bars in chronological order, got %v to %v", bars[0].Date, bars[2].Date)
+	}
+	if bars[0].High != 317.07 || bars[0].Low != 311.15 || bars[0].Volume != 30028742 {
+		t.Errorf("Unexpected bar %+v", bars[0])
+	}
+	if bars[2].Volume != 30480900 {
+		t.Errorf("Expected volume 30480900, got %d", bars[2].Volume)
+	}
+}
+
+func TestFinancialModelingPrepService_GetPriceClose_RequestedDate(t *testing.T) {
+	ctx := context.Background()
+	// The bar of the requested date wins over the bars around it
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[
+			{"date":"2020-01-16","open":313.59,"close":315.24},
+			{"date":"2020-01-15","open":311.85,"close":311.34}
+		]}`))
+	}))
+	defer ts.Close()
+
+	fmp := &FinancialModelingPrepService{
+		APIKey: "dummykey",
+		Client: resty.New(),
+	}
+
+	fmp.Client.SetBaseURL(ts.URL)
+
+	price, err := fmp.GetPriceClose(ctx, "AAPL", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if price != 311.34 {
+		t.Errorf("Expected close price 311.34, got %f", price)
+	}
+}
+
+func TestFinancialModelingPrepService_GetResolvedPrice(t *testing.T) {
+	ctx := context.Background()
+	var from, to string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, to = r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[
+			{"date":"2020-01-21","open":317.19,"close":316.57},
+			{"date":"2020-01-17","open":316.27,"close":318.73},
+			{"date":"2020-01-16","open":313.59,"close":315.24}
+		]}`))
+	}))
+	defer ts.Close()
+
+	fmp := &FinancialModelingPrepService{
+		APIKey: "dummykey",
+		Client: resty.New(),
+	}
+
+	fmp.Client.SetBaseURL(ts.URL)
+
+	// Saturday, priced with the Friday close by default
+	saturday := time.Date(2020, 1, 18, 0, 0, 0, 0, time.UTC)
+	price, err := fmp.GetResolvedPrice(ctx, "AAPL", saturday)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if from != "2020-01-08" || to != "2020-01-18" {
+		t.Errorf("Expected the range 2020-01-08 to 2020-01-18, got %s to %s", from, to)
+	}
+	if !price.Adjusted() || price.Date.Format("2006-01-02") != "2020-01-17" || price.Close != 318.73 {
+		t.Errorf("Expected the close of 2020-01-17, got %+v", price)
+	}
+
+	// The next trading day is the Tuesday after the holiday
+	fmp.Resolution = models.ResolveNextTradingDay
+	price, err = fmp.GetResolvedPrice(ctx, "AAPL", saturday)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if price.Date.Format("2006-01-02") != "2020-01-21" || price.Open != 317.19 {
+		t.Errorf("Expected the bar of 2020-01-21, got %+v", price)
+	}
+
+	fmp.Resolution = models.ResolveStrict
+	if _, err := fmp.GetResolvedPrice(ctx, "AAPL", saturday); err == nil {
+		t.Errorf("Expected an error for a day without trading in strict mode")
+	}
+}
+
+func TestFinancialModelingPrepService_Fallback(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	fmp := NewFinancialModelingPrepServiceWithOptions("dummykey", ClientOptions{})
+	fmp.Client.SetBaseURL(ts.URL)
+
+	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	if _, err := fmp.GetPriceClose(ctx, "AAPL", date); err == nil {
+		t.Fatalf("Expected the fail-fast fallback to return the API error")
+	}
+
+	fmp.Fallback = NewPromptFallback(bufio.NewReader(strings.NewReader("311.34\n")), &out)
+	price, err := fmp.GetPriceClose(ctx, "AAPL", date)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if price != 311.34 {
+		t.Errorf("Expected the entered price 311.34, got %f", price)
+	}
+	if !strings.Contains(out.String(), "status code 500") {
+		t.Errorf("Expected the prompt to show the API error, got %q", out.String())
+	}
+}
+
+func TestFinancialModelingPrepService_InvalidAPIKey(t *testing.T) {
+	ctx := context.Background()
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"Error Message": "Invalid API KEY."}`, status)
+		}))
+
+		fmp := NewFinancialModelingPrepServiceWithOptions("badkey", ClientOptions{Timeout: time.Second})
+		fmp.Client.SetBaseURL(ts.URL)
+
+		_, err := fmp.GetPriceHistory(ctx, "AAPL", time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+		ts.Close()
+		if !errors.Is(err, ErrProviderConfig) || errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected a configuration error for status %d, got %v", status, err)
+		}
+	}
+}
+
+func TestFinancialModelingPrepService_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, `{"Error Message": "Limit Reach"}`, http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	fmp := NewFinancialModelingPrepService("dummykey")
+	fmp.Client.SetBaseURL(ts.URL)
+
+	_, err := fmp.GetPriceClose(ctx, "AAPL", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected a rate limit error, got %v", err)
+	}
+	if limited.RetryAfter != 2*time.Minute {
+		t.Errorf("Expected to retry after 2m, got %s", limited.RetryAfter)
+	}
+	if requests != 1 {
+		t.Errorf("Expected a 429 not to be retried, got %d requests", requests)
+	}
+}
+
+func TestFinancialModelingPrepService_Retry(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[{"date":"2020-01-15","open":311.85,"close":311.34}]}`))
+	}))
+	defer ts.Close()
+
+	options := DefaultClientOptions()
+	options.RetryWaitTime = time.Millisecond
+	options.RetryMaxWaitTime = 5 * time.Millisecond
+	fmp := NewFinancialModelingPrepServiceWithOptions("dummykey", options)
+	fmp.Client.SetBaseURL(ts.URL)
+
+	price, err := fmp.GetPriceClose(ctx, "AAPL", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected the request to succeed after retrying, got %v", err)
+	}
+	if price != 311.34 || requests != 3 {
+		t.Errorf("Expected close price 311.34 after 3 requests, got %f after %d", price, requests)
+	}
+}
+
+func TestFinancialModelingPrepService_DailyQuota(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"symbol":"AAPL","historical":[{"date":"2020-01-15","open":311.85,"close":311.34}]}`))
+	}))
+	defer ts.Close()
+
+	repo := repositories.NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "usage.db"))
+	defer repo.DB.Close()
+	options := ClientOptions{Limiter: NewRateLimiter("fmp", 0, 1, repo)}
+	fmp := NewFinancialModelingPrepServiceWithOptions("dummykey", options)
+	fmp.Client.SetBaseURL(ts.URL)
+
+	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	if _, err := fmp.GetPriceClose(ctx, "AAPL", date); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, err := fmp.GetPriceClose(ctx, "AAPL", date)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the daily quota to stop the request, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request to reach the API, got %d", requests)
+	}
+}
+
+func TestFinancialModelingPrepService_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	fmp := NewFinancialModelingPrepService("dummykey")
+	fmp.Client.SetBaseURL(ts.URL)
+	fallback := &countingFallback{}
+	fmp.Fallback = fallback
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fmp.GetPriceClose(ctx, "AAPL", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the request to be canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to stop with the context, took %s", elapsed)
+	}
+	if fallback.calls != 0 {
+		t.Errorf("Expected no fallback once the context is done, got %d calls", fallback.calls)
+	}
+}
+
+// countingFallback counts the prices it is asked for and has none.
+type countingFallback struct {
+	calls int
+}
+
+func (f *countingFallback) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	f.calls++
+	return nil, cause
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ClientOptions configures the HTTP client of a price provider. Requests that
+// time out or fail with a 5xx status are retried RetryCount times, waiting an
+// exponential backoff with jitter between RetryWaitTime and RetryMaxWaitTime.
+// Every attempt waits for Limiter, when there is one.
+type ClientOptions struct {
+	Timeout          time.Duration
+	RetryCount       int
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	Limiter          *RateLimiter
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:          30 * time.Second,
+		RetryCount:       3,
+		RetryWaitTime:    time.Second,
+		RetryMaxWaitTime: 10 * time.Second,
+	}
+}
+
+func newClient(baseURL string, options ClientOptions) *resty.Client {
+	client := resty.New()
+	client.SetBaseURL(baseURL)
+	client.SetTimeout(options.Timeout)
+	client.SetRetryCount(options.RetryCount)
+	client.SetRetryWaitTime(options.RetryWaitTime)
+	client.SetRetryMaxWaitTime(options.RetryMaxWaitTime)
+	client.AddRetryCondition(func(resp *resty.Response, err error) bool {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return err == nil && resp != nil && resp.StatusCode() >= http.StatusInternalServerError
+	})
+
+	if options.Limiter != nil {
+		limiter := options.Limiter
+		client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+			return limiter.Wait(req.Context())
+		})
+	}
+	return client
+}
+
+// retryAfter parses the Retry-After header, given in seconds or as a date.
+func retryAfter(resp *resty.Response, now time.Time) time.Duration {
+	value := resp.Header().Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// quantityEpsilon absorbs floating point noise when comparing share quantities.
+const quantityEpsilon = 1e-9
+
+// buildLedger merges the stored stocks, which are the opening buys of the
+// portfolio, with its recorded transactions in chronological order.
+func buildLedger(portfolio *models.Portfolio, transactions []models.Transaction) []models.Transaction {
+	ledger := make([]models.Transaction, 0, len(portfolio.Stocks)+len(transactions))
+	for _, stock := range portfolio.Stocks {
+		ledger = append(ledger, models.Transaction{
+			PortfolioID: portfolio.ID,
+			Symbol:      stock.Symbol,
+			Type:        models.TransactionBuy,
+			Date:        stock.BuyDate,
+			Quantity:    float64(stock.Quantity),
+			Price:       stock.BuyPrice,
+			Contributed: true,
+			StockID:     stock.ID,
+		})
+	}
+	ledger = append(ledger, transactions...)
+
+	sort.SliceStable(ledger, func(i, j int) bool {
+		return ledger[i].Date.Before(ledger[j].Date)
+	})
+	return ledger
+}
+
+// ledgerState is the result of replaying a ledger up to a date.
+type ledgerState struct {
+	Positions     []models.Position
+	Sales         []models.RealizedSale
+	Lots          []models.Lot
+	PortfolioFees float64 // fees that are not tied to a symbol
+}
+
+// replayLedger applies the ledger up to asOf, consuming lots with the given
+// cost basis method. Positions and lots are returned in order of first
+// appearance of their symbol.
+func replayLedger(ledger []models.Transaction, asOf time.Time, method models.CostBasisMethod) (*ledgerState, error) {
+	var order []string
+	positions := make(map[string]*models.Position)
+	books := make(map[string]*lotBook)
+	state := &ledgerState{}
+
+	position := func(symbol string) *models.Position {
+		p, ok := positions[symbol]
+		if !ok {
+			p = &models.Position{Symbol: symbol}
+			positions[symbol] = p
+			books[symbol] = &lotBook{}
+			order = append(order, symbol)
+		}
+		return p
+	}
+
+	for _, t := range ledger {
+		if t.Date.After(asOf) {
+			break
+		}
+
+		if t.Symbol == "" {
+			if t.Type != models.TransactionFee {
+				return nil, fmt.Errorf("%s transaction on %s has no symbol", t.Type, t.Date.Format("2006-01-02"))
+			}
+			state.PortfolioFees += t.Price + t.Commission
+			continue
+		}
+
+		p := position(t.Symbol)
+		book := books[t.Symbol]
+		switch t.Type {
+		case models.TransactionBuy:
+			book.buy(t)
+		case models.TransactionSell:
+			sales, err := book.sell(t, method)
+			if err != nil {
+				return nil, err
+			}
+			for _, sale := range sales {
+				p.RealizedGain += sale.Gain()
+			}
+			state.Sales = append(state.Sales, sales...)
+		case models.TransactionDividend:
+			p.Dividends += t.Price - t.Commission
+		case models.TransactionFee:
+			p.Fees += t.Price + t.Commission
+		case models.TransactionSplit:
+			book.split(t.Quantity)
+		default:
+			return nil, fmt.Errorf("unknown transaction type %q", t.Type)
+		}
+	}
+
+	state.Positions = make([]models.Position, 0, len(order))
+	for _, symbol := range order {
+		p := positions[symbol]
+		book := books[symbol]
+		p.Quantity = book.quantity()
+		p.CostBasis = book.costBasis()
+		if math.Abs(p.Quantity) < quantityEpsilon {
+			p.Quantity = 0
+			p.CostBasis = 0
+		}
+		state.Positions = append(state.Positions, *p)
+		for _, lot := range book.lots {
+			state.Lots = append(state.Lots, *lot)
+		}
+	}
+	return state, nil
+}
+
+func validateTransaction(t *models.Transaction) error {
+	switch t.Type {
+	case models.TransactionBuy, models.TransactionSell, models.TransactionSplit:
+		if t.Symbol == "" {
+			return fmt.Errorf("a %s transaction requires a symbol", t.Type)
+		}
+		if t.Quantity <= 0 {
+			return fmt.Errorf("the quantity of a %s transaction must be greater than zero", t.Type)
+		}
+	case models.TransactionDividend:
+		if t.Symbol == "" {
+			return fmt.Errorf("a dividend transaction requires a symbol")
+		}
+	case models.TransactionFee:
+	default:
+		return fmt.Errorf("unknown transaction type %q", t.Type)
+	}
+
+	if t.Price < 0 {
+		return fmt.Errorf("the price of a transaction cannot be negative")
+	}
+	if t.Commission < 0 {
+		return fmt.Errorf("the commission of a transaction cannot be negative")
+	}
+	if t.Date.IsZero() {
+		return fmt.Errorf("a transaction requires a date")
+	}
+	if len(t.Lots) > 0 && t.Type != models.TransactionSell {
+		return fmt.Errorf("only sell transactions can select lots")
+	}
+	return nil
+}
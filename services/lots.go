@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// lotID identifies the lot opened by a buy: stocks of the portfolio use an
+// "S" prefix and recorded buy transactions a "T" prefix.
+func lotID(t models.Transaction) string {
+	if t.Contributed {
+		return fmt.Sprintf("S%d", t.StockID)
+	}
+	return fmt.Sprintf("T%d", t.ID)
+}
+
+// isLongTerm reports whether shares held between the two dates qualify for
+// long-term treatment, which requires holding them for more than one year.
+func isLongTerm(buyDate, sellDate time.Time) bool {
+	return sellDate.After(buyDate.AddDate(1, 0, 0))
+}
+
+// lotBook keeps the open lots of a single symbol in purchase order.
+type lotBook struct {
+	lots []*models.Lot
+}
+
+func (b *lotBook) quantity() float64 {
+	total := 0.0
+	for _, lot := range b.lots {
+		total += lot.Quantity
+	}
+	return total
+}
+
+func (b *lotBook) costBasis() float64 {
+	total := 0.0
+	for _, lot := range b.lots {
+		total += lot.Quantity * lot.CostPerShare
+	}
+	return total
+}
+
+func (b *lotBook) buy(t models.Transaction) {
+	b.lots = append(b.lots, &models.Lot{
+		ID:           lotID(t),
+		Symbol:       t.Symbol,
+		BuyDate:      t.Date,
+		Quantity:     t.Quantity,
+		CostPerShare: (t.Quantity*t.Price + t.Commission) / t.Quantity,
+	})
+}
+
+func (b *lotBook) split(ratio float64) {
+	for _, lot := range b.lots {
+		lot.Quantity *= ratio
+		lot.CostPerShare /= ratio
+	}
+}
+
+// sell consumes lots according to the method and returns one realized sale per
+// consumed lot. Sells that list their lots always use specific identification.
+func (b *lotBook) sell(t models.Transaction, method models.CostBasisMethod) ([]models.RealizedSale, error) {
+	held := b.quantity()
+	if t.Quantity > held+quantityEpsilon {
+		return nil, fmt.Errorf("cannot sell %g shares of %s on %s, only %g held",
+			t.Quantity, t.Symbol, t.Date.Format("2006-01-02"), held)
+	}
+
+	var selections []models.LotSelection
+	var err error
+	switch {
+	case len(t.Lots) > 0:
+		selections, err = b.specificSelections(t)
+	case method == models.CostBasisSpecific:
+		err = fmt.Errorf("the sell of %s on %s must identify the lots to sell", t.Symbol, t.Date.Format("2006-01-02"))
+	default:
+		selections, err = b.orderedSelections(t.Quantity, method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	averageCost := 0.0
+	if held > 0 {
+		averageCost = b.costBasis() / held
+	}
+
+	// Proceeds and commission are allocated to each lot proportionally.
+	netProceeds := t.Quantity*t.Price - t.Commission
+	sales := make([]models.RealizedSale, 0, len(selections))
+	for _, selection := range selections {
+		lot := b.find(selection.LotID)
+		costPerShare := lot.CostPerShare
+		if method == models.CostBasisAverage && len(t.Lots) == 0 {
+			costPerShare = averageCost
+		}
+
+		sales = append(sales, models.RealizedSale{
+			Symbol:    t.Symbol,
+			LotID:     lot.ID,
+			BuyDate:   lot.BuyDate,
+			SellDate:  t.Date,
+			Quantity:  selection.Quantity,
+			Proceeds:  netProceeds * selection.Quantity / t.Quantity,
+			CostBasis: costPerShare * selection.Quantity,
+			LongTerm:  isLongTerm(lot.BuyDate, t.Date),
+		})
+		lot.Quantity -= selection.Quantity
+	}
+
+	b.removeEmptyLots()
+	if method == models.CostBasisAverage && len(t.Lots) == 0 {
+		for _, lot := range b.lots {
+			lot.CostPerShare = averageCost
+		}
+	}
+	return sales, nil
+}
+
+// orderedSelections consumes lots in the order given by the method. Average
+// cost consumes lots first in, first out to determine holding periods.
+func (b *lotBook) orderedSelections(quantity float64, method models.CostBasisMethod) ([]models.LotSelection, error) {
+	ordered := make([]*models.Lot, len(b.lots))
+	copy(ordered, b.lots)
+
+	switch method {
+	case models.CostBasisFIFO, models.CostBasisAverage:
+	case models.CostBasisLIFO:
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	case models.CostBasisHIFO:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].CostPerShare > ordered[j].CostPerShare
+		})
+	default:
+		return nil, fmt.Errorf("unknown cost basis method %q", method)
+	}
+
+	var selections []models.LotSelection
+	remaining := quantity
+	for _, lot := range ordered {
+		if remaining <= quantityEpsilon {
+			break
+		}
+		take := math.Min(lot.Quantity, remaining)
+		selections = append(selections, models.LotSelection{LotID: lot.ID, Quantity: take})
+		remaining -= take
+	}
+	return selections, nil
+}
+
+func (b *lotBook) specificSelections(t models.Transaction) ([]models.LotSelection, error) {
+	total := 0.0
+	for _, selection := range t.Lots {
+		lot := b.find(selection.LotID)
+		if lot == nil {
+			return nil, fmt.Errorf("lot %s of %s is not held on %s", selection.LotID, t.Symbol, t.Date.Format("2006-01-02"))
+		}
+		if selection.Quantity <= 0 || selection.Quantity > lot.Quantity+quantityEpsilon {
+			return nil, fmt.Errorf("cannot sell %g shares from lot %s, which holds %g", selection.Quantity, lot.ID, lot.Quantity)
+		}
+		total += selection.Quantity
+	}
+	if math.Abs(total-t.Quantity) > quantityEpsilon {
+		return nil, fmt.Errorf("the selected lots add up to %g shares but %g are sold", total, t.Quantity)
+	}
+	return t.Lots, nil
+}
+
+func (b *lotBook) find(id string) *models.Lot {
+	for _, lot := range b.lots {
+		if lot.ID == id {
+			return lot
+		}
+	}
+	return nil
+}
+
+func (b *lotBook) removeEmptyLots() {
+	open := b.lots[:0]
+	for _, lot := range b.lots {
+		if lot.Quantity > quantityEpsilon {
+			open = append(open, lot)
+		}
+	}
+	b.lots = open
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// lotsLedger buys 10 shares at 100, 10 at 150 and 10 at 120, then sells 15 at 200.
+func lotsLedger() []models.Transaction {
+	return []models.Transaction{
+		{ID: 1, Symbol: "AAPL", Type: models.TransactionBuy, Date: date(2020, 1, 10), Quantity: 10, Price: 100},
+		{ID: 2, Symbol: "AAPL", Type: models.TransactionBuy, Date: date(2020, 6, 10), Quantity: 10, Price: 150},
+		{ID: 3, Symbol: "AAPL", Type: models.TransactionBuy, Date: date(2020, 9, 10), Quantity: 10, Price: 120},
+		{ID: 4, Symbol: "AAPL", Type: models.TransactionSell, Date: date(2021, 3, 1), Quantity: 15, Price: 200},
+	}
+}
+
+func TestReplayLedger_CostBasisMethods(t *testing.T) {
+	tests := []struct {
+		method        models.CostBasisMethod
+		expectedLots  []string
+		realized      float64
+		remainingCost float64
+	}{
+		// Consumes 10 @100 and 5 @150.
+		{models.CostBasisFIFO, []string{"T1", "T2"}, 3000 - 1000 - 750, 750 + 1200},
+		// Consumes 10 @120 and 5 @150.
+		{models.CostBasisLIFO, []string{"T3", "T2"}, 3000 - 1200 - 750, 1000 + 750},
+		// Consumes 10 @150 and 5 @120.
+		{models.CostBasisHIFO, []string{"T2", "T3"}, 3000 - 1500 - 600, 1000 + 600},
+		// Consumes 15 shares at the average cost of 123.33 in FIFO order.
+		{models.CostBasisAverage, []string{"T1", "T2"}, 3000 - 1850, 3700 - 1850},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			state, err := replayLedger(lotsLedger(), date(2021, 12, 31), tt.method)
+			require.NoError(t, err)
+
+			var lots []string
+			for _, sale := range state.Sales {
+				lots = append(lots, sale.LotID)
+			}
+			require.Equal(t, tt.expectedLots, lots)
+			require.InDelta(t, tt.realized, state.Positions[0].RealizedGain, 1e-9)
+			require.InDelta(t, 15.0, state.Positions[0].Quantity, 1e-9)
+			require.InDelta(t, tt.remainingCost, state.Positions[0].CostBasis, 1e-9)
+		})
+	}
+}
+
+func TestReplayLedger_SpecificLots(t *testing.T) {
+	ledger := lotsLedger()
+	ledger[3].Lots = []models.LotSelection{{LotID: "T3", Quantity: 10}, {LotID: "T1", Quantity: 5}}
+
+	state, err := replayLedger(ledger, date(2021, 12, 31), models.CostBasisSpecific)
+	require.NoError(t, err)
+	require.Len(t, state.Sales, 2)
+	require.InDelta(t, 3000-1200-500, state.Positions[0].RealizedGain, 1e-9)
+
+	// Specific identification requires the lots to be selected.
+	_, err = replayLedger(lotsLedger(), date(2021, 12, 31), models.CostBasisSpecific)
+	require.Error(t, err)
+
+	// The selected lots must add up to the quantity sold.
+	ledger[3].Lots = []models.LotSelection{{LotID: "T3", Quantity: 10}}
+	_, err = replayLedger(ledger, date(2021, 12, 31), models.CostBasisSpecific)
+	require.Error(t, err)
+}
+
+func TestReplayLedger_HoldingPeriods(t *testing.T) {
+	state, err := replayLedger(lotsLedger(), date(2021, 12, 31), models.CostBasisFIFO)
+	require.NoError(t, err)
+
+	// The first lot was held for more than a year, the second was not.
+	require.True(t, state.Sales[0].LongTerm)
+	require.False(t, state.Sales[1].LongTerm)
+	require.False(t, isLongTerm(date(2020, 1, 10), date(2021, 1, 10)))
+	require.True(t, isLongTerm(date(2020, 1, 10), date(2021, 1, 11)))
+}
+
+func TestReplayLedger_CommissionAndSplit(t *testing.T) {
+	ledger := []models.Transaction{
+		{ID: 1, Symbol: "AAPL", Type: models.TransactionBuy, Date: date(2020, 1, 10), Quantity: 10, Price: 100, Commission: 10},
+		{Symbol: "AAPL", Type: models.TransactionSplit, Date: date(2020, 8, 31), Quantity: 4},
+		{ID: 2, Symbol: "AAPL", Type: models.TransactionSell, Date: date(2020, 9, 1), Quantity: 20, Price: 30, Commission: 6},
+	}
+
+	state, err := replayLedger(ledger, date(2020, 12, 31), models.CostBasisFIFO)
+	require.NoError(t, err)
+
+	// The lot costs 1010 for 40 shares after the split, 25.25 per share.
+	require.Len(t, state.Lots, 1)
+	require.InDelta(t, 20.0, state.Lots[0].Quantity, 1e-9)
+	require.InDelta(t, 25.25, state.Lots[0].CostPerShare, 1e-9)
+	require.InDelta(t, 600-6-505, state.Sales[0].Gain(), 1e-9)
+}
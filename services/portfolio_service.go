@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"time"
 
@@ -11,58 +13,390 @@ import (
 type PortfolioService struct {
 	Repo         repositories.PortfolioRepository
 	StockService StockServiceInterface
+	Fetcher      *PriceFetcher
 }
 
 func NewPortfolioService(repo repositories.PortfolioRepository, stockService StockServiceInterface) *PortfolioService {
 	return &PortfolioService{
 		Repo:         repo,
 		StockService: stockService,
+		Fetcher:      NewPriceFetcher(stockService),
 	}
 }
 
-func (ps *PortfolioService) GetAllPortfolios() ([]models.Portfolio, error) {
-	return ps.Repo.GetAll()
+func (ps *PortfolioService) GetAllPortfolios(ctx context.Context) ([]models.Portfolio, error) {
+	return ps.Repo.GetAll(ctx)
 }
 
-func (ps *PortfolioService) GetPortfolioByID(id int) (*models.Portfolio, error) {
-	return ps.Repo.GetByID(id)
+// ListPortfolios returns the page of portfolios selected by the query.
+func (ps *PortfolioService) ListPortfolios(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error) {
+	return ps.Repo.List(ctx, query)
 }
 
-func (ps *PortfolioService) CreatePortfolioManual(portfolio *models.Portfolio) error {
-	return ps.Repo.Save(portfolio)
+func (ps *PortfolioService) GetPortfolioByID(ctx context.Context, id int) (*models.Portfolio, error) {
+	return ps.Repo.GetByID(ctx, id)
 }
 
-func (ps *PortfolioService) DeletePortfolio(id int) error {
-	return ps.Repo.Delete(id)
+func (ps *PortfolioService) CreatePortfolioManual(ctx context.Context, portfolio *models.Portfolio) error {
+	return ps.Repo.Save(ctx, portfolio)
 }
 
-func (ps *PortfolioService) CalculateAPR(portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
-	initialValue := 0.0
-	finalValue := 0.0
+// UpdatePortfolio validates the edited stocks against the recorded
+// transactions and stores the portfolio.
+func (ps *PortfolioService) UpdatePortfolio(ctx context.Context, portfolio *models.Portfolio) error {
+	transactions, err := ps.Repo.GetTransactions(ctx, portfolio.ID)
+	if err != nil {
+		return err
+	}
 
-	for _, stock := range portfolio.Stocks {
-		initialPrice := stock.BuyPrice
-		finalPrice, err := ps.StockService.GetPriceClose(stock.Symbol, endDate)
-		if err != nil {
-			return 0, err
+	// The stocks are the opening buys of the ledger, so replaying it with the edited ones
+	// rejects edits that leave a recorded sell without the shares or the lots it sold.
+	if len(transactions) > 0 {
+		ledger := buildLedger(portfolio, transactions)
+		if _, err := replayLedger(ledger, ledger[len(ledger)-1].Date, portfolio.Method()); err != nil {
+			return fmt.Errorf("%w: %w", models.ErrValidation, err)
 		}
-		initialValue += initialPrice * float64(stock.Quantity)
-		finalValue += finalPrice * float64(stock.Quantity)
 	}
 
+	return ps.Repo.Update(ctx, portfolio)
+}
+
+func (ps *PortfolioService) DeletePortfolio(ctx context.Context, id int) error {
+	return ps.Repo.Delete(ctx, id)
+}
+
+// CalculateAPR annualizes the growth of the total value of the portfolio, cash
+// plus holdings on endDate, over the money contributed to it.
+func (ps *PortfolioService) CalculateAPR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return 0, err
+	}
+
+	gains, err := ps.calculateGains(ctx, portfolio, ledger, entries, endDate, ps.GetPriceCloses)
+	if err != nil {
+		return 0, err
+	}
+	initialValue := netContributions(ledger, entries, endDate)
+	finalValue := gains.TotalValue()
+
 	years := endDate.Sub(startDate).Hours() / (24 * 365)
 	if years == 0 {
 		return 0, nil
 	}
+	if initialValue <= 0 {
+		return 0, fmt.Errorf("no capital was contributed to the portfolio")
+	}
 
 	apr := math.Pow(finalValue/initialValue, 1/years) - 1
 	return apr, nil
 }
 
-func (ps *PortfolioService) GetPriceClose(symbol string, date time.Time) (float64, error) {
-	return ps.StockService.GetPriceClose(symbol, date)
+// CalculateXIRR returns the money-weighted return of the portfolio between the
+// two dates. Its value on startDate, the contributions, deposits and
+// withdrawals on their actual dates and its value on endDate are treated as
+// dated cash flows.
+func (ps *PortfolioService) CalculateXIRR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return 0, err
+	}
+
+	flows := externalCashFlows(ledger, entries, startDate, endDate)
+	if heldBefore(ledger, entries, startDate) {
+		start, err := ps.calculateGains(ctx, portfolio, ledger, entries, startDate, ps.GetPriceCloses)
+		if err != nil {
+			return 0, err
+		}
+		flows = append(flows, cashFlow{Date: startDate, Amount: -start.TotalValue()})
+	}
+
+	end, err := ps.calculateGains(ctx, portfolio, ledger, entries, endDate, ps.GetPriceCloses)
+	if err != nil {
+		return 0, err
+	}
+	flows = append(flows, cashFlow{Date: endDate, Amount: end.TotalValue()})
+
+	return xirr(flows)
+}
+
+// CalculateTWR values the portfolio on every trading day between the two dates
+// and chains the daily returns around contributions, deposits and withdrawals
+// into the cumulative and annualized time-weighted return.
+func (ps *PortfolioService) CalculateTWR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (*models.TWRReport, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	histories, err := ps.fetchValuationHistories(ctx, portfolio, ledger, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	points, err := ps.valuationSeries(ctx, portfolio, ledger, entries, histories, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TWRReport{From: startDate, To: endDate, Points: points}
+	if len(points) > 0 {
+		report.Cumulative = points[len(points)-1].CumulativeReturn
+		report.Annualized = annualizeReturn(report.Cumulative, startDate, endDate)
+	}
+	return report, nil
+}
+
+// CompareToBenchmark simulates putting the same cash flows as the portfolio
+// into the benchmark symbol on the same dates and compares both daily value
+// series between the two dates.
+func (ps *PortfolioService) CompareToBenchmark(ctx context.Context, portfolio *models.Portfolio, symbol string, startDate, endDate time.Time) (*models.BenchmarkComparison, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	histories, err := ps.fetchValuationHistories(ctx, portfolio, ledger, startDate, endDate, symbol)
+	if err != nil {
+		return nil, err
+	}
+	points, err := ps.valuationSeries(ctx, portfolio, ledger, entries, histories, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	return ps.compareToBenchmark(ctx, points, histories, symbol, startDate, endDate)
+}
+
+// CalculateRisk computes the volatility, Sharpe and Sortino ratios, maximum
+// drawdown and beta and alpha against the benchmark symbol from the daily
+// valuations of the portfolio between the two dates. The risk-free rate is an
+// annual fraction.
+func (ps *PortfolioService) CalculateRisk(ctx context.Context, portfolio *models.Portfolio, benchmark string, riskFreeRate float64, startDate, endDate time.Time) (*models.RiskReport, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	histories, err := ps.fetchValuationHistories(ctx, portfolio, ledger, startDate, endDate, benchmark)
+	if err != nil {
+		return nil, err
+	}
+	points, err := ps.valuationSeries(ctx, portfolio, ledger, entries, histories, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	benchmarkReturns, err := priceReturns(ctx, histories, benchmark, points)
+	if err != nil {
+		return nil, err
+	}
+
+	report := calculateRisk(points, benchmarkReturns, riskFreeRate)
+	report.From = startDate
+	report.To = endDate
+	report.Benchmark = benchmark
+	return report, nil
+}
+
+// GetTransactions returns the ledger of the portfolio: its stocks as opening
+// buys followed by the recorded transactions, in chronological order.
+func (ps *PortfolioService) GetTransactions(ctx context.Context, portfolio *models.Portfolio) ([]models.Transaction, error) {
+	transactions, err := ps.Repo.GetTransactions(ctx, portfolio.ID)
+	if err != nil {
+		return nil, err
+	}
+	return buildLedger(portfolio, transactions), nil
+}
+
+// RecordTransaction validates a transaction against the portfolio ledger and stores it.
+func (ps *PortfolioService) RecordTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := validateTransaction(transaction); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrValidation, err)
+	}
+
+	portfolio, err := ps.Repo.GetByID(ctx, transaction.PortfolioID)
+	if err != nil {
+		return err
+	}
+
+	ledger, err := ps.GetTransactions(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+
+	// Replaying the whole ledger with the new entry rejects sells of shares that are not held.
+	ledger = buildLedger(&models.Portfolio{}, append(ledger, *transaction))
+	if _, err := replayLedger(ledger, ledger[len(ledger)-1].Date, portfolio.Method()); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrValidation, err)
+	}
+
+	return ps.Repo.SaveTransaction(ctx, transaction)
+}
+
+// CalculateGains derives the positions held on date from the ledger and splits
+// the result into realized gains, from sells, and unrealized gains, valued at
+// the close price of that date. It also reports the cash balance on date.
+func (ps *PortfolioService) CalculateGains(ctx context.Context, portfolio *models.Portfolio, date time.Time) (*models.Gains, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	return ps.calculateGains(ctx, portfolio, ledger, entries, date, ps.GetPriceCloses)
+}
+
+// calculateGains values the positions held on date at the close prices
+// returned by prices.
+func (ps *PortfolioService) calculateGains(ctx context.Context, portfolio *models.Portfolio, ledger []models.Transaction, entries []models.CashEntry, date time.Time, prices closePricesFunc) (*models.Gains, error) {
+	state, err := replayLedger(ledger, date, portfolio.Method())
+	if err != nil {
+		return nil, err
+	}
+
+	positions := state.Positions
+	var requests []PriceRequest
+	for _, p := range positions {
+		if p.Quantity > 0 {
+			requests = append(requests, NewPriceRequest(p.Symbol, date))
+		}
+	}
+	closes, err := prices(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	gains := &models.Gains{
+		Fees: state.PortfolioFees,
+		Cash: cashBalance(ledger, entries, date),
+	}
+	for i := range positions {
+		p := &positions[i]
+		if p.Quantity > 0 {
+			price := closes[NewPriceRequest(p.Symbol, date)]
+			p.MarketPrice = price
+			p.MarketValue = price * p.Quantity
+			p.UnrealizedGain = p.MarketValue - p.CostBasis
+		}
+
+		gains.Realized += p.RealizedGain
+		gains.Unrealized += p.UnrealizedGain
+		gains.Dividends += p.Dividends
+		gains.Fees += p.Fees
+		gains.HoldingsValue += p.MarketValue
+	}
+	gains.Positions = positions
+
+	for _, entry := range entries {
+		if entry.Date.After(date) {
+			continue
+		}
+		switch entry.Type {
+		case models.CashInterest:
+			gains.Interest += entry.Amount
+		case models.CashFee:
+			gains.Fees += entry.Amount
+		}
+	}
+
+	return gains, nil
+}
+
+// loadLedger returns the ledger and the cash entries of the portfolio.
+func (ps *PortfolioService) loadLedger(ctx context.Context, portfolio *models.Portfolio) ([]models.Transaction, []models.CashEntry, error) {
+	ledger, err := ps.GetTransactions(ctx, portfolio)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := ps.Repo.GetCashEntries(ctx, portfolio.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ledger, entries, nil
+}
+
+// GetCashEntries returns the deposits, withdrawals, interest and fees of the portfolio.
+func (ps *PortfolioService) GetCashEntries(ctx context.Context, portfolio *models.Portfolio) ([]models.CashEntry, error) {
+	return ps.Repo.GetCashEntries(ctx, portfolio.ID)
+}
+
+// RecordCashEntry validates and stores a movement of the cash account.
+func (ps *PortfolioService) RecordCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	if err := validateCashEntry(entry); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrValidation, err)
+	}
+	return ps.Repo.SaveCashEntry(ctx, entry)
+}
+
+// GetCashBalance returns the cash of the portfolio on date: cash entries plus
+// the cash moved by recorded buys, sells, dividends and fees.
+func (ps *PortfolioService) GetCashBalance(ctx context.Context, portfolio *models.Portfolio, date time.Time) (float64, error) {
+	ledger, entries, err := ps.loadLedger(ctx, portfolio)
+	if err != nil {
+		return 0, err
+	}
+	return cashBalance(ledger, entries, date), nil
+}
+
+// GetOpenLots returns the lots held on date, which specific-lot sells refer to.
+func (ps *PortfolioService) GetOpenLots(ctx context.Context, portfolio *models.Portfolio, date time.Time) ([]models.Lot, error) {
+	ledger, err := ps.GetTransactions(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := replayLedger(ledger, date, portfolio.Method())
+	if err != nil {
+		return nil, err
+	}
+	return state.Lots, nil
+}
+
+// GetRealizedGains reports every lot consumed by the sells between from and to,
+// split into short-term and long-term holding periods.
+func (ps *PortfolioService) GetRealizedGains(ctx context.Context, portfolio *models.Portfolio, from, to time.Time) (*models.RealizedGainsReport, error) {
+	ledger, err := ps.GetTransactions(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := replayLedger(ledger, to, portfolio.Method())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.RealizedGainsReport{From: from, To: to, Sales: []models.RealizedSale{}}
+	for _, sale := range state.Sales {
+		if sale.SellDate.Before(from) {
+			continue
+		}
+		report.Sales = append(report.Sales, sale)
+		if sale.LongTerm {
+			report.LongTerm += sale.Gain()
+		} else {
+			report.ShortTerm += sale.Gain()
+		}
+	}
+	return report, nil
+}
+
+func (ps *PortfolioService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	return ps.StockService.GetPriceClose(ctx, symbol, date)
+}
+
+// GetPriceCloses fetches the close prices of the requests in parallel, keyed by
+// the request with its date at midnight UTC. Failed symbols are reported
+// together in a PriceErrors next to the prices that could be fetched.
+func (ps *PortfolioService) GetPriceCloses(ctx context.Context, requests []PriceRequest) (map[PriceRequest]float64, error) {
+	return ps.Fetcher.FetchPrices(ctx, requests)
+}
+
+// GetResolvedPrice returns the price of the symbol on date together with the
+// trading day it comes from.
+func (ps *PortfolioService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	return ps.StockService.GetResolvedPrice(ctx, symbol, date)
+}
+
+func (ps *PortfolioService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	return ps.StockService.GetPriceHistory(ctx, symbol, from, to)
 }
 
-func (ps *PortfolioService) GetSP500Symbols() ([]string, error) {
-	return ps.StockService.GetSP500Symbols()
+func (ps *PortfolioService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	return ps.StockService.GetSP500Symbols(ctx)
 }
@@ -1,17 +1,35 @@
 package services
 
 import (
+	"context"
 	"time"
 
 	"github.com/fcopulgar/stock-manager-go/models"
 )
 
 type PortfolioServiceInterface interface {
-	GetAllPortfolios() ([]models.Portfolio, error)
-	GetPortfolioByID(id int) (*models.Portfolio, error)
-	CreatePortfolioManual(portfolio *models.Portfolio) error
-	DeletePortfolio(id int) error
-	CalculateAPR(portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error)
-	GetPriceClose(symbol string, date time.Time) (float64, error)
-	GetSP500Symbols() ([]string, error)
+	GetAllPortfolios(ctx context.Context) ([]models.Portfolio, error)
+	ListPortfolios(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error)
+	GetPortfolioByID(ctx context.Context, id int) (*models.Portfolio, error)
+	CreatePortfolioManual(ctx context.Context, portfolio *models.Portfolio) error
+	UpdatePortfolio(ctx context.Context, portfolio *models.Portfolio) error
+	DeletePortfolio(ctx context.Context, id int) error
+	CalculateAPR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error)
+	CalculateXIRR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (float64, error)
+	CalculateTWR(ctx context.Context, portfolio *models.Portfolio, startDate, endDate time.Time) (*models.TWRReport, error)
+	CompareToBenchmark(ctx context.Context, portfolio *models.Portfolio, symbol string, startDate, endDate time.Time) (*models.BenchmarkComparison, error)
+	CalculateRisk(ctx context.Context, portfolio *models.Portfolio, benchmark string, riskFreeRate float64, startDate, endDate time.Time) (*models.RiskReport, error)
+	GetTransactions(ctx context.Context, portfolio *models.Portfolio) ([]models.Transaction, error)
+	RecordTransaction(ctx context.Context, transaction *models.Transaction) error
+	CalculateGains(ctx context.Context, portfolio *models.Portfolio, date time.Time) (*models.Gains, error)
+	GetOpenLots(ctx context.Context, portfolio *models.Portfolio, date time.Time) ([]models.Lot, error)
+	GetRealizedGains(ctx context.Context, portfolio *models.Portfolio, from, to time.Time) (*models.RealizedGainsReport, error)
+	GetCashEntries(ctx context.Context, portfolio *models.Portfolio) ([]models.CashEntry, error)
+	RecordCashEntry(ctx context.Context, entry *models.CashEntry) error
+	GetCashBalance(ctx context.Context, portfolio *models.Portfolio, date time.Time) (float64, error)
+	GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error)
+	GetPriceCloses(ctx context.Context, requests []PriceRequest) (map[PriceRequest]float64, error)
+	GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error)
+	GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error)
+	GetSP500Symbols(ctx context.Context) ([]string, error)
 }
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -14,27 +16,62 @@ type MockPortfolioRepository struct {
 	mock.Mock
 }
 
-func (m *MockPortfolioRepository) GetAll() ([]models.Portfolio, error) {
+func (m *MockPortfolioRepository) GetAll(ctx context.Context) ([]models.Portfolio, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Portfolio), args.Error(1)
 }
 
-func (m *MockPortfolioRepository) GetByID(id int) (*models.Portfolio, error) {
+func (m *MockPortfolioRepository) List(ctx context.Context, query models.PortfolioQuery) ([]models.Portfolio, error) {
+	args := m.Called(query)
+	return args.Get(0).([]models.Portfolio), args.Error(1)
+}
+
+func (m *MockPortfolioRepository) GetByID(ctx context.Context, id int) (*models.Portfolio, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Portfolio), args.Error(1)
 }
 
-func (m *MockPortfolioRepository) Save(portfolio *models.Portfolio) error {
+func (m *MockPortfolioRepository) Save(ctx context.Context, portfolio *models.Portfolio) error {
 	args := m.Called(portfolio)
 	return args.Error(0)
 }
 
-func (m *MockPortfolioRepository) Update(portfolio *models.Portfolio) error {
+func (m *MockPortfolioRepository) Update(ctx context.Context, portfolio *models.Portfolio) error {
 	args := m.Called(portfolio)
 	return args.Error(0)
 }
 
-func (m *MockPortfolioRepository) Delete(id int) error {
+func (m *MockPortfolioRepository) Delete(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioRepository) GetTransactions(ctx context.Context, portfolioID int) ([]models.Transaction, error) {
+	args := m.Called(portfolioID)
+	return args.Get(0).([]models.Transaction), args.Error(1)
+}
+
+func (m *MockPortfolioRepository) SaveTransaction(ctx context.Context, transaction *models.Transaction) error {
+	args := m.Called(transaction)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioRepository) DeleteTransaction(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioRepository) GetCashEntries(ctx context.Context, portfolioID int) ([]models.CashEntry, error) {
+	args := m.Called(portfolioID)
+	return args.Get(0).([]models.CashEntry), args.Error(1)
+}
+
+func (m *MockPortfolioRepository) SaveCashEntry(ctx context.Context, entry *models.CashEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioRepository) DeleteCashEntry(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
@@ -44,23 +81,34 @@ type MockStockService struct {
 	mock.Mock
 }
 
-func (m *MockStockService) GetPriceOpen(symbol string, date time.Time) (float64, error) {
+func (m *MockStockService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
 	args := m.Called(symbol, date)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockStockService) GetPriceClose(symbol string, date time.Time) (float64, error) {
+func (m *MockStockService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
 	args := m.Called(symbol, date)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockStockService) GetSP500Symbols() ([]string, error) {
+func (m *MockStockService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	args := m.Called(symbol, date)
+	return args.Get(0).(*models.ResolvedPrice), args.Error(1)
+}
+
+func (m *MockStockService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	args := m.Called(symbol, from, to)
+	return args.Get(0).([]models.PriceBar), args.Error(1)
+}
+
+func (m *MockStockService) GetSP500Symbols(ctx context.Context) ([]string, error) {
 	args := m.Called()
 	return args.Get(0).([]string), args.Error(1)
 }
 
 // TestGetAllPortfolios test TestGetAllPortfolios()
 func TestGetAllPortfolios(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
@@ -69,7 +117,7 @@ func TestGetAllPortfolios(t *testing.T) {
 		{Name: "Test Portfolio"},
 	}, nil)
 
-	portfolios, err := service.GetAllPortfolios()
+	portfolios, err := service.GetAllPortfolios(ctx)
 	require.NoError(t, err)
 	require.Len(t, portfolios, 1)
 	require.Equal(t, "Test Portfolio", portfolios[0].Name)
@@ -79,13 +127,14 @@ func TestGetAllPortfolios(t *testing.T) {
 
 // TestGetPortfolioByID test TestGetPortfolioByID()
 func TestGetPortfolioByID(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
 
 	mockRepo.On("GetByID", 1).Return(&models.Portfolio{Name: "ID Portfolio"}, nil)
 
-	p, err := service.GetPortfolioByID(1)
+	p, err := service.GetPortfolioByID(ctx, 1)
 	require.NoError(t, err)
 	require.NotNil(t, p)
 	require.Equal(t, "ID Portfolio", p.Name)
@@ -95,6 +144,7 @@ func TestGetPortfolioByID(t *testing.T) {
 
 // TestCreatePortfolioManual test TestCreatePortfolioManual()
 func TestCreatePortfolioManual(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
@@ -102,21 +152,74 @@ func TestCreatePortfolioManual(t *testing.T) {
 	portfolio := &models.Portfolio{Name: "New Portfolio"}
 	mockRepo.On("Save", portfolio).Return(nil)
 
-	err := service.CreatePortfolioManual(portfolio)
+	err := service.CreatePortfolioManual(ctx, portfolio)
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+// TestUpdatePortfolio test UpdatePortfolio()
+func TestUpdatePortfolio(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	portfolio := &models.Portfolio{ID: 1, Name: "Renamed Portfolio"}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("Update", portfolio).Return(nil)
+
+	err := service.UpdatePortfolio(ctx, portfolio)
 	require.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
 }
 
+// TestUpdatePortfolio_Ledger test that UpdatePortfolio() rejects stocks that no longer cover the recorded sells
+func TestUpdatePortfolio_Ledger(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: date(2020, 6, 1), Quantity: 8, Price: 120.0},
+	}, nil)
+	stock := models.Stock{ID: 3, Symbol: "AAPL", Quantity: 10, BuyDate: date(2020, 1, 15), BuyPrice: 100.0}
+
+	// Shrinking the stock below the shares sold
+	shrunk := stock
+	shrunk.Quantity = 5
+	portfolio := &models.Portfolio{ID: 1, Name: "Savings", Stocks: []models.Stock{shrunk}}
+	require.ErrorIs(t, service.UpdatePortfolio(ctx, portfolio), models.ErrValidation)
+
+	// Moving the buy after the sell
+	late := stock
+	late.BuyDate = date(2020, 7, 1)
+	portfolio = &models.Portfolio{ID: 1, Name: "Savings", Stocks: []models.Stock{late}}
+	require.ErrorIs(t, service.UpdatePortfolio(ctx, portfolio), models.ErrValidation)
+
+	// Removing it
+	portfolio = &models.Portfolio{ID: 1, Name: "Savings"}
+	require.ErrorIs(t, service.UpdatePortfolio(ctx, portfolio), models.ErrValidation)
+
+	// An edit that still covers the sell is saved
+	portfolio = &models.Portfolio{ID: 1, Name: "Savings", Stocks: []models.Stock{stock}}
+	mockRepo.On("Update", portfolio).Return(nil)
+	require.NoError(t, service.UpdatePortfolio(ctx, portfolio))
+	mockRepo.AssertNumberOfCalls(t, "Update", 1)
+}
+
 // TestDeletePortfolio test DeletePortfolio()
 func TestDeletePortfolio(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
 
 	mockRepo.On("Delete", 1).Return(nil)
 
-	err := service.DeletePortfolio(1)
+	err := service.DeletePortfolio(ctx, 1)
 	require.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
@@ -124,6 +227,7 @@ func TestDeletePortfolio(t *testing.T) {
 
 // TestCalculateAPR test CalculateAPR()
 func TestCalculateAPR(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
@@ -144,9 +248,11 @@ func TestCalculateAPR(t *testing.T) {
 		},
 	}
 
+	mockRepo.On("GetTransactions", 0).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 0).Return([]models.CashEntry{}, nil)
 	mockStock.On("GetPriceClose", "AAPL", endDate).Return(110.0, nil)
 
-	apr, err := service.CalculateAPR(portfolio, startDate, endDate)
+	apr, err := service.CalculateAPR(ctx, portfolio, startDate, endDate)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -163,6 +269,7 @@ func TestCalculateAPR(t *testing.T) {
 
 // TestGetPriceClose test GetPriceClose()
 func TestGetPriceClose(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
@@ -170,7 +277,7 @@ func TestGetPriceClose(t *testing.T) {
 	date := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
 	mockStock.On("GetPriceClose", "AAPL", date).Return(300.0, nil)
 
-	price, err := service.GetPriceClose("AAPL", date)
+	price, err := service.GetPriceClose(ctx, "AAPL", date)
 	require.NoError(t, err)
 	require.Equal(t, 300.0, price)
 
@@ -179,13 +286,14 @@ func TestGetPriceClose(t *testing.T) {
 
 // TestGetSP500Symbols test GetSP500Symbols()
 func TestGetSP500Symbols(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockPortfolioRepository)
 	mockStock := new(MockStockService)
 	service := NewPortfolioService(mockRepo, mockStock)
 
 	mockStock.On("GetSP500Symbols").Return([]string{"AAPL", "MSFT"}, nil)
 
-	symbols, err := service.GetSP500Symbols()
+	symbols, err := service.GetSP500Symbols(ctx)
 	require.NoError(t, err)
 	require.Len(t, symbols, 2)
 	require.Equal(t, "AAPL", symbols[0])
@@ -193,3 +301,369 @@ func TestGetSP500Symbols(t *testing.T) {
 
 	mockStock.AssertExpectations(t)
 }
+
+// TestCalculateGains test CalculateGains()
+func TestCalculateGains(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	date := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID:   1,
+		Name: "Trading Portfolio",
+		Stocks: []models.Stock{
+			{Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 100.0},
+		},
+	}
+
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionBuy, Date: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, Price: 80.0, Commission: 10.0},
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 5, Price: 120.0, Commission: 5.0},
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionDividend, Date: time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC), Price: 12.0},
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSplit, Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Quantity: 4},
+		{PortfolioID: 1, Type: models.TransactionFee, Date: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Price: 3.0},
+		{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, Price: 50.0},
+	}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{}, nil)
+	mockStock.On("GetPriceClose", "AAPL", date).Return(40.0, nil)
+
+	gains, err := service.CalculateGains(ctx, portfolio, date)
+	require.NoError(t, err)
+
+	// Average cost after both buys is (1000 + 810) / 20 = 90.5 per share.
+	// Selling 5 at 120 with 5 of commission realizes 600 - 5 - 452.5 = 142.5.
+	// The remaining 15 shares become 60 after the split, with a cost basis of 1357.5.
+	// The sell of 2022 is after the valuation date and is ignored.
+	require.Len(t, gains.Positions, 1)
+	require.InDelta(t, 60.0, gains.Positions[0].Quantity, 1e-9)
+	require.InDelta(t, 1357.5, gains.Positions[0].CostBasis, 1e-9)
+	require.InDelta(t, 142.5, gains.Realized, 1e-9)
+	require.InDelta(t, 2400.0-1357.5, gains.Unrealized, 1e-9)
+	require.InDelta(t, 12.0, gains.Dividends, 1e-9)
+	require.InDelta(t, 3.0, gains.Fees, 1e-9)
+
+	mockRepo.AssertExpectations(t)
+	mockStock.AssertExpectations(t)
+}
+
+// TestRecordTransaction test RecordTransaction()
+func TestRecordTransaction(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 100.0},
+		},
+	}
+	mockRepo.On("GetByID", 1).Return(portfolio, nil)
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+
+	sell := &models.Transaction{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell,
+		Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 4, Price: 120.0}
+	mockRepo.On("SaveTransaction", sell).Return(nil)
+
+	require.NoError(t, service.RecordTransaction(ctx, sell))
+
+	// Selling more shares than held is rejected.
+	tooMany := &models.Transaction{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell,
+		Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 11, Price: 120.0}
+	require.ErrorIs(t, service.RecordTransaction(ctx, tooMany), models.ErrValidation)
+
+	// Selling before the shares were bought is rejected.
+	early := &models.Transaction{PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell,
+		Date: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 1, Price: 120.0}
+	require.Error(t, service.RecordTransaction(ctx, early))
+
+	invalid := &models.Transaction{PortfolioID: 1, Symbol: "AAPL", Type: "gift", Date: time.Now(), Quantity: 1}
+	require.ErrorIs(t, service.RecordTransaction(ctx, invalid), models.ErrValidation)
+
+	// The portfolio must exist.
+	mockRepo.On("GetByID", 2).Return((*models.Portfolio)(nil), fmt.Errorf("portfolio 2 %w", models.ErrNotFound))
+	missing := &models.Transaction{PortfolioID: 2, Symbol: "AAPL", Type: models.TransactionBuy,
+		Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 1, Price: 120.0}
+	require.ErrorIs(t, service.RecordTransaction(ctx, missing), models.ErrNotFound)
+
+	mockRepo.AssertNumberOfCalls(t, "SaveTransaction", 1)
+}
+
+// TestGetRealizedGains test GetRealizedGains()
+func TestGetRealizedGains(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	portfolio := &models.Portfolio{
+		ID:              1,
+		CostBasisMethod: models.CostBasisFIFO,
+		Stocks: []models.Stock{
+			{ID: 7, Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 100.0},
+			{ID: 8, Symbol: "AAPL", Quantity: 10, BuyDate: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), BuyPrice: 150.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{
+		{ID: 1, PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 2, Price: 110.0},
+		{ID: 2, PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 12, Price: 200.0},
+	}, nil)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	report, err := service.GetRealizedGains(ctx, portfolio, from, to)
+	require.NoError(t, err)
+
+	// The sell of 2019 is outside the period. The sell of 2020 consumes the
+	// remaining 8 shares of the 2019 lot and 4 shares of the 2020 lot.
+	require.Len(t, report.Sales, 2)
+	require.Equal(t, "S7", report.Sales[0].LotID)
+	require.True(t, report.Sales[0].LongTerm)
+	require.Equal(t, "S8", report.Sales[1].LotID)
+	require.False(t, report.Sales[1].LongTerm)
+	require.InDelta(t, 8*(200.0-100.0), report.LongTerm, 1e-9)
+	require.InDelta(t, 4*(200.0-150.0), report.ShortTerm, 1e-9)
+	require.InDelta(t, 1000.0, report.Total(), 1e-9)
+}
+
+// TestCalculateAPR_WithCash test CalculateAPR() on a portfolio with a cash account
+func TestCalculateAPR_WithCash(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	startDate := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{ID: 1, Name: "Cash Portfolio"}
+
+	// 10000 deposited, 5000 spent on AAPL and 100 of interest earned.
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{
+		{ID: 1, PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionBuy, Date: startDate, Quantity: 50, Price: 100.0},
+	}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{
+		{PortfolioID: 1, Type: models.CashDeposit, Date: startDate, Amount: 10000},
+		{PortfolioID: 1, Type: models.CashInterest, Date: time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), Amount: 100},
+	}, nil)
+	mockStock.On("GetPriceClose", "AAPL", endDate).Return(110.0, nil)
+
+	apr, err := service.CalculateAPR(ctx, portfolio, startDate, endDate)
+	require.NoError(t, err)
+
+	// The total value is 5100 of cash plus 5500 of AAPL over 10000 contributed.
+	require.InDelta(t, 0.06, apr, 0.001)
+}
+
+// TestCalculateGains_Cash test the cash balance reported by CalculateGains()
+func TestCalculateGains_Cash(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	date := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{ID: 3, Symbol: "MSFT", Quantity: 10, BuyDate: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), BuyPrice: 150.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{
+		{ID: 1, PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionBuy, Date: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, Price: 100.0, Commission: 5},
+		{ID: 2, PortfolioID: 1, Symbol: "AAPL", Type: models.TransactionSell, Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, Price: 120.0, Commission: 5},
+		{ID: 3, PortfolioID: 1, Symbol: "MSFT", Type: models.TransactionDividend, Date: time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC), Price: 20.0},
+	}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{
+		{PortfolioID: 1, Type: models.CashDeposit, Date: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Amount: 2000},
+		{PortfolioID: 1, Type: models.CashWithdrawal, Date: time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), Amount: 500},
+		{PortfolioID: 1, Type: models.CashFee, Date: time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), Amount: 10},
+	}, nil)
+	mockStock.On("GetPriceClose", "MSFT", date).Return(200.0, nil)
+
+	gains, err := service.CalculateGains(ctx, portfolio, date)
+	require.NoError(t, err)
+
+	// The contributed MSFT stock does not move cash, the AAPL round trip does.
+	expectedCash := 2000.0 - 1005 + 1195 + 20 - 500 - 10
+	require.InDelta(t, expectedCash, gains.Cash, 1e-9)
+	require.InDelta(t, 2000.0, gains.HoldingsValue, 1e-9)
+	require.InDelta(t, expectedCash+2000, gains.TotalValue(), 1e-9)
+	require.InDelta(t, 10.0, gains.Fees, 1e-9)
+
+	balance, err := service.GetCashBalance(ctx, portfolio, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.InDelta(t, 2000.0-1005, balance, 1e-9)
+}
+
+// TestRecordCashEntry test RecordCashEntry()
+func TestRecordCashEntry(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	deposit := &models.CashEntry{PortfolioID: 1, Type: models.CashDeposit, Date: time.Now(), Amount: 1000}
+	mockRepo.On("SaveCashEntry", deposit).Return(nil)
+
+	require.NoError(t, service.RecordCashEntry(ctx, deposit))
+	require.ErrorIs(t, service.RecordCashEntry(ctx, &models.CashEntry{PortfolioID: 1, Type: models.CashDeposit, Date: time.Now(), Amount: -5}), models.ErrValidation)
+	require.Error(t, service.RecordCashEntry(ctx, &models.CashEntry{PortfolioID: 1, Type: "loan", Date: time.Now(), Amount: 5}))
+
+	mockRepo.AssertNumberOfCalls(t, "SaveCashEntry", 1)
+}
+
+// TestCalculateXIRR test CalculateXIRR()
+func TestCalculateXIRR(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	startDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{ID: 1, Symbol: "AAPL", Quantity: 10, BuyDate: startDate, BuyPrice: 100.0},
+			{ID: 2, Symbol: "MSFT", Quantity: 10, BuyDate: time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), BuyPrice: 100.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{}, nil)
+	mockStock.On("GetPriceClose", "AAPL", startDate).Return(100.0, nil)
+	mockStock.On("GetPriceClose", "AAPL", endDate).Return(110.0, nil)
+	mockStock.On("GetPriceClose", "MSFT", endDate).Return(105.0, nil)
+
+	xirr, err := service.CalculateXIRR(ctx, portfolio, startDate, endDate)
+	require.NoError(t, err)
+
+	// MSFT was held for half of the year only, so its 5% counts as about 10% a year.
+	require.InDelta(t, 0.1009, xirr, 0.0005)
+}
+
+// TestCalculateTWR test CalculateTWR()
+func TestCalculateTWR(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	// From Monday to Friday, with a deposit on Wednesday.
+	startDate := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{ID: 1, Symbol: "AAPL", Quantity: 10, BuyDate: startDate, BuyPrice: 100.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{
+		{PortfolioID: 1, Type: models.CashDeposit, Date: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC), Amount: 1000},
+	}, nil)
+	historyStart := startDate.AddDate(0, 0, -resolutionWindowDays)
+	mockStock.On("GetPriceHistory", "AAPL", historyStart, endDate).Return(dailyBars(startDate, 100, 110, 110, 121, 121), nil).Once()
+
+	report, err := service.CalculateTWR(ctx, portfolio, startDate, endDate)
+	require.NoError(t, err)
+	require.Len(t, report.Points, 5)
+
+	// The deposit raises the value on Wednesday but is not a return.
+	require.InDelta(t, 2100.0, report.Points[2].Value, 1e-9)
+	require.InDelta(t, 1000.0, report.Points[2].NetFlow, 1e-9)
+	require.InDelta(t, 0.0, report.Points[2].Return, 1e-9)
+	require.InDelta(t, 0.1, report.Points[1].Return, 1e-9)
+	require.InDelta(t, 1.1*(2210.0/2100.0)-1, report.Cumulative, 1e-9)
+	require.Greater(t, report.Annualized, report.Cumulative)
+
+	// The history of every symbol is fetched once instead of a price per day.
+	mockStock.AssertNumberOfCalls(t, "GetPriceHistory", 1)
+	mockStock.AssertNotCalled(t, "GetPriceClose", mock.Anything, mock.Anything)
+}
+
+// TestCalculateTWR_Holiday test that the days without prices, such as market holidays, are not valued
+func TestCalculateTWR_Holiday(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	// Friday, the Martin Luther King Jr. Day holiday and Tuesday.
+	startDate := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2021, 1, 19, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{ID: 1, Symbol: "AAPL", Quantity: 10, BuyDate: startDate, BuyPrice: 100.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{}, nil)
+	historyStart := startDate.AddDate(0, 0, -resolutionWindowDays)
+	mockStock.On("GetPriceHistory", "AAPL", historyStart, endDate).Return([]models.PriceBar{
+		{Date: startDate, Close: 100},
+		{Date: endDate, Close: 110},
+	}, nil).Once()
+
+	report, err := service.CalculateTWR(ctx, portfolio, startDate, endDate)
+	require.NoError(t, err)
+	require.Len(t, report.Points, 2)
+	require.Equal(t, endDate, report.Points[1].Date)
+	require.InDelta(t, 0.1, report.Points[1].Return, 1e-9)
+	mockStock.AssertNotCalled(t, "GetPriceClose", mock.Anything, mock.Anything)
+}
+
+// dailyBars returns a bar per day from start with the close prices.
+func dailyBars(start time.Time, closes ...float64) []models.PriceBar {
+	bars := make([]models.PriceBar, 0, len(closes))
+	for i, price := range closes {
+		bars = append(bars, models.PriceBar{Date: start.AddDate(0, 0, i), Close: price})
+	}
+	return bars
+}
+
+func TestTradingDays(t *testing.T) {
+	// From a Saturday to the next Saturday.
+	days := tradingDays(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 9, 0, 0, 0, 0, time.UTC))
+	require.Len(t, days, 5)
+	require.Equal(t, time.Monday, days[0].Weekday())
+	require.Equal(t, time.Friday, days[4].Weekday())
+}
+
+// TestCompareToBenchmark test CompareToBenchmark()
+func TestCompareToBenchmark(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockPortfolioRepository)
+	mockStock := new(MockStockService)
+	service := NewPortfolioService(mockRepo, mockStock)
+
+	startDate := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)
+	portfolio := &models.Portfolio{
+		ID: 1,
+		Stocks: []models.Stock{
+			{ID: 1, Symbol: "AAPL", Quantity: 10, BuyDate: startDate, BuyPrice: 100.0},
+		},
+	}
+	mockRepo.On("GetTransactions", 1).Return([]models.Transaction{}, nil)
+	mockRepo.On("GetCashEntries", 1).Return([]models.CashEntry{
+		{PortfolioID: 1, Type: models.CashDeposit, Date: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC), Amount: 1000},
+	}, nil)
+	historyStart := startDate.AddDate(0, 0, -resolutionWindowDays)
+	mockStock.On("GetPriceHistory", "AAPL", historyStart, endDate).Return(dailyBars(startDate, 100, 110, 110, 121, 121), nil).Once()
+	mockStock.On("GetPriceHistory", "SPY", historyStart, endDate).Return(dailyBars(startDate, 400, 404, 408, 412, 416), nil).Once()
+
+	comparison, err := service.CompareToBenchmark(ctx, portfolio, "SPY", startDate, endDate)
+	require.NoError(t, err)
+	require.Len(t, comparison.Points, 5)
+
+	// The opening 1000 buys 2.5 SPY and the deposit buys more at the previous close.
+	require.InDelta(t, (2.5+1000.0/404)*416, comparison.Points[4].BenchmarkValue, 1e-9)
+	require.InDelta(t, 0.04, comparison.BenchmarkReturn, 1e-9)
+	require.InDelta(t, 1.1*(2210.0/2100.0)-1-0.04, comparison.ExcessReturn(), 1e-9)
+	require.Greater(t, comparison.TrackingError, 0.0)
+	require.Greater(t, comparison.InformationRatio, 0.0)
+}
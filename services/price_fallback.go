@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/repositories"
+)
+
+// PriceFallback provides the price of a symbol on a date when the provider
+// could not, cause being the error of the provider.
+type PriceFallback interface {
+	FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error)
+}
+
+// ManualPriceInterface manages the prices entered by hand.
+type ManualPriceInterface interface {
+	SetManualPrice(ctx context.Context, symbol string, date time.Time, price float64) error
+	GetManualPrices(ctx context.Context, symbol string) ([]models.ManualPrice, error)
+	DeleteManualPrice(ctx context.Context, symbol string, date time.Time) error
+}
+
+// FailFastFallback returns the error of the provider.
+type FailFastFallback struct{}
+
+func (FailFastFallback) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	return nil, cause
+}
+
+// PromptFallback asks the user for the price, one price at a time when they
+// are fetched in parallel.
+type PromptFallback struct {
+	Reader *bufio.Reader
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func NewPromptFallback(reader *bufio.Reader, writer io.Writer) *PromptFallback {
+	return &PromptFallback{Reader: reader, Writer: writer}
+}
+
+func (pf *PromptFallback) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dateStr := date.Format("2006-01-02")
+	fmt.Fprintf(pf.Writer, "Failed to retrieve the price for %s on %s. %s\n", symbol, dateStr, cause)
+	fmt.Fprintf(pf.Writer, "Please enter the price for %s on %s: ", symbol, dateStr)
+
+	input, err := pf.Reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w, and no price was entered", cause)
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+	if err != nil || price <= 0 {
+		return nil, fmt.Errorf("%w: the price must be a positive number", models.ErrValidation)
+	}
+
+	return enteredPrice(date, price, "prompt"), nil
+}
+
+// ManualPriceFallback uses the prices entered by hand, which it also manages.
+type ManualPriceFallback struct {
+	Repo repositories.ManualPriceRepository
+}
+
+func NewManualPriceFallback(repo repositories.ManualPriceRepository) *ManualPriceFallback {
+	return &ManualPriceFallback{Repo: repo}
+}
+
+func (mf *ManualPriceFallback) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	price, err := mf.Repo.GetManualPrice(ctx, symbol, dateOnly(date))
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		return nil, fmt.Errorf("%w, and no manual price is set for %s on %s", cause, symbol, date.Format("2006-01-02"))
+	}
+	return enteredPrice(date, price.Price, "manual"), nil
+}
+
+func (mf *ManualPriceFallback) SetManualPrice(ctx context.Context, symbol string, date time.Time, price float64) error {
+	if price <= 0 {
+		return fmt.Errorf("%w: the price must be positive", models.ErrValidation)
+	}
+	return mf.Repo.SaveManualPrice(ctx, models.ManualPrice{Symbol: symbol, Date: dateOnly(date), Price: price})
+}
+
+func (mf *ManualPriceFallback) GetManualPrices(ctx context.Context, symbol string) ([]models.ManualPrice, error) {
+	return mf.Repo.GetManualPrices(ctx, symbol)
+}
+
+func (mf *ManualPriceFallback) DeleteManualPrice(ctx context.Context, symbol string, date time.Time) error {
+	return mf.Repo.DeleteManualPrice(ctx, symbol, dateOnly(date))
+}
+
+// LastKnownPriceFallback uses the most recent cached price on or before the
+// date, which comes back as a price of that earlier trading day.
+type LastKnownPriceFallback struct {
+	Repo repositories.PriceRepository
+}
+
+func NewLastKnownPriceFallback(repo repositories.PriceRepository) *LastKnownPriceFallback {
+	return &LastKnownPriceFallback{Repo: repo}
+}
+
+func (lf *LastKnownPriceFallback) FallbackPrice(ctx context.Context, symbol string, date time.Time, cause error) (*models.ResolvedPrice, error) {
+	bar, err := lf.Repo.GetLastCachedPrice(ctx, symbol, dateOnly(date))
+	if err != nil {
+		return nil, err
+	}
+	if bar == nil {
+		return nil, fmt.Errorf("%w, and no earlier price of %s is known", cause, symbol)
+	}
+	price := &models.ResolvedPrice{RequestedDate: dateOnly(date), PriceBar: bar.PriceBar}
+	price.Source = "last-known"
+	return price, nil
+}
+
+// enteredPrice is a single price used as the whole bar of the date.
+func enteredPrice(date time.Time, price float64, source string) *models.ResolvedPrice {
+	date = dateOnly(date)
+	return &models.ResolvedPrice{
+		RequestedDate: date,
+		PriceBar:      models.PriceBar{Date: date, Open: price, High: price, Low: price, Close: price, Source: source},
+	}
+}
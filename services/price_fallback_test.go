@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/fcopulgar/stock-manager-go/repositories"
+	"github.com/stretchr/testify/require"
+)
+
+var errNoPrice = errors.New("API request failed with status code 500")
+
+// TestPromptFallback test that the price is read from the given reader
+func TestPromptFallback(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	fallback := NewPromptFallback(bufio.NewReader(strings.NewReader("127.14\nabc\n")), &out)
+
+	price, err := fallback.FallbackPrice(ctx, "AAPL", time.Date(2021, 1, 15, 10, 0, 0, 0, time.UTC), errNoPrice)
+	require.NoError(t, err)
+	require.Equal(t, 127.14, price.Open)
+	require.Equal(t, 127.14, price.Close)
+	require.False(t, price.Adjusted())
+	require.Contains(t, out.String(), "Please enter the price for AAPL on 2021-01-15: ")
+
+	_, err = fallback.FallbackPrice(ctx, "AAPL", date(2021, 1, 15), errNoPrice)
+	require.Error(t, err)
+
+	// Without input left the error of the provider is kept
+	_, err = fallback.FallbackPrice(ctx, "AAPL", date(2021, 1, 15), errNoPrice)
+	require.ErrorIs(t, err, errNoPrice)
+}
+
+// TestManualPriceFallback test that the prices entered by hand are used
+func TestManualPriceFallback(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "prices.db"))
+	t.Cleanup(func() { repo.DB.Close() })
+	fallback := NewManualPriceFallback(repo)
+
+	require.Error(t, fallback.SetManualPrice(ctx, "AAPL", date(2021, 1, 15), 0))
+	require.NoError(t, fallback.SetManualPrice(ctx, "AAPL", date(2021, 1, 15), 127.14))
+
+	price, err := fallback.FallbackPrice(ctx, "AAPL", date(2021, 1, 15), errNoPrice)
+	require.NoError(t, err)
+	require.Equal(t, 127.14, price.Close)
+
+	_, err = fallback.FallbackPrice(ctx, "AAPL", date(2021, 1, 19), errNoPrice)
+	require.ErrorIs(t, err, errNoPrice)
+
+	require.NoError(t, fallback.DeleteManualPrice(ctx, "AAPL", date(2021, 1, 15)))
+	prices, err := fallback.GetManualPrices(ctx, "AAPL")
+	require.NoError(t, err)
+	require.Empty(t, prices)
+}
+
+// TestLastKnownPriceFallback test that the last cached price is used
+func TestLastKnownPriceFallback(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewSQLitePortfolioRepository(filepath.Join(t.TempDir(), "prices.db"))
+	t.Cleanup(func() { repo.DB.Close() })
+	require.NoError(t, repo.SaveCachedPrices(ctx, []models.CachedPriceBar{
+		{Symbol: "AAPL", PriceBar: models.PriceBar{Date: date(2021, 1, 14), Close: 128.91}},
+		{Symbol: "AAPL", PriceBar: models.PriceBar{Date: date(2021, 1, 15), Close: 127.14}},
+	}))
+	fallback := NewLastKnownPriceFallback(repo)
+
+	price, err := fallback.FallbackPrice(ctx, "AAPL", date(2021, 1, 19), errNoPrice)
+	require.NoError(t, err)
+	require.True(t, price.Adjusted())
+	require.Equal(t, date(2021, 1, 15), price.Date)
+	require.Equal(t, 127.14, price.Close)
+
+	_, err = fallback.FallbackPrice(ctx, "MSFT", date(2021, 1, 19), errNoPrice)
+	require.ErrorIs(t, err, errNoPrice)
+}
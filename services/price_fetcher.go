@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPriceWorkers is how many prices are fetched at the same time.
+const DefaultPriceWorkers = 8
+
+// PriceRequest is the close price of a symbol on a date.
+type PriceRequest struct {
+	Symbol string
+	Date   time.Time
+}
+
+// NewPriceRequest returns the request of the symbol on the date at midnight
+// UTC, which is how the fetched prices are keyed.
+func NewPriceRequest(symbol string, date time.Time) PriceRequest {
+	return PriceRequest{Symbol: symbol, Date: dateOnly(date)}
+}
+
+// PriceErrors holds the error of every symbol whose prices could not be
+// fetched.
+type PriceErrors map[string]error
+
+func (e PriceErrors) Error() string {
+	symbols := make([]string, 0, len(e))
+	for symbol := range e {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	messages := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		messages = append(messages, fmt.Sprintf("%s: %v", symbol, e[symbol]))
+	}
+	return "failed to fetch prices: " + strings.Join(messages, "; ")
+}
+
+// Unwrap lets errors.Is and errors.As look into the error of every symbol.
+func (e PriceErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// PriceFetcher fetches the close prices of many symbols and dates in parallel
+// with at most Workers requests at a time. Identical requests, within a call
+// or from calls running at the same time, reach the stock service only once,
+// and the shared request is only canceled once every caller waiting for it
+// has left.
+type PriceFetcher struct {
+	Service StockServiceInterface
+	Workers int
+
+	mu       sync.Mutex
+	inFlight map[PriceRequest]*priceCall
+}
+
+// priceCall is a request to the stock service that other callers wait for.
+// Waiters counts the callers still waiting, guarded by the mutex of the
+// fetcher.
+type priceCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	price   float64
+	err     error
+}
+
+func NewPriceFetcher(service StockServiceInterface) *PriceFetcher {
+	return &PriceFetcher{Service: service, Workers: DefaultPriceWorkers}
+}
+
+// FetchPrices returns the close price of every request that could be fetched,
+// keyed by the request with its date at midnight UTC. The requests that failed
+// are reported together in a PriceErrors, and the ones left when ctx is done
+// with the error of ctx.
+func (pf *PriceFetcher) FetchPrices(ctx context.Context, requests []PriceRequest) (map[PriceRequest]float64, error) {
+	pending := make([]PriceRequest, 0, len(requests))
+	seen := make(map[PriceRequest]bool, len(requests))
+	for _, request := range requests {
+		request = NewPriceRequest(request.Symbol, request.Date)
+		if !seen[request] {
+			seen[request] = true
+			pending = append(pending, request)
+		}
+	}
+
+	workers := pf.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	type result struct {
+		request PriceRequest
+		price   float64
+		err     error
+	}
+	jobs := make(chan PriceRequest)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range jobs {
+				price, err := pf.fetch(ctx, request)
+				results <- result{request: request, price: price, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, request := range pending {
+			select {
+			case jobs <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	prices := make(map[PriceRequest]float64, len(pending))
+	errs := PriceErrors{}
+	for r := range results {
+		if r.err != nil {
+			if _, failed := errs[r.request.Symbol]; !failed {
+				errs[r.request.Symbol] = fmt.Errorf("%s: %w", r.request.Date.Format("2006-01-02"), r.err)
+			}
+			continue
+		}
+		prices[r.request] = r.price
+	}
+
+	if err := ctx.Err(); err != nil {
+		return prices, err
+	}
+	if len(errs) > 0 {
+		return prices, errs
+	}
+	return prices, nil
+}
+
+// fetch joins the call already asking for the same price or starts a new one.
+// The call keeps the values of the context of the caller that started it but
+// not its cancellation, so that a caller leaving does not fail the others, and
+// it is canceled when the last caller waiting for it leaves.
+func (pf *PriceFetcher) fetch(ctx context.Context, request PriceRequest) (float64, error) {
+	pf.mu.Lock()
+	call, ok := pf.inFlight[request]
+	if !ok {
+		if pf.inFlight == nil {
+			pf.inFlight = make(map[PriceRequest]*priceCall)
+		}
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &priceCall{done: make(chan struct{}), cancel: cancel}
+		pf.inFlight[request] = call
+		go pf.run(callCtx, request, call)
+	}
+	call.waiters++
+	pf.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.price, call.err
+	case <-ctx.Done():
+		pf.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Later callers start a new call instead of joining a canceled one
+			if pf.inFlight[request] == call {
+				delete(pf.inFlight, request)
+			}
+			call.cancel()
+		}
+		pf.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+func (pf *PriceFetcher) run(ctx context.Context, request PriceRequest, call *priceCall) {
+	call.price, call.err = pf.Service.GetPriceClose(ctx, request.Symbol, request.Date)
+
+	pf.mu.Lock()
+	if pf.inFlight[request] == call {
+		delete(pf.inFlight, request)
+	}
+	pf.mu.Unlock()
+	call.cancel()
+	close(call.done)
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// slowStockService answers every close price after a delay and counts the
+// calls, the most of them running at the same time and the calls per symbol.
+type slowStockService struct {
+	Delay  time.Duration
+	Failed map[string]error
+
+	mu      sync.Mutex
+	calls   map[string]int
+	running int32
+	maxRun  int32
+}
+
+func (s *slowStockService) GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	return s.GetPriceClose(ctx, symbol, date)
+}
+
+func (s *slowStockService) GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	running := atomic.AddInt32(&s.running, 1)
+	defer atomic.AddInt32(&s.running, -1)
+	for {
+		max := atomic.LoadInt32(&s.maxRun)
+		if running <= max || atomic.CompareAndSwapInt32(&s.maxRun, max, running) {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[symbol]++
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(s.Delay):
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	if err := s.Failed[symbol]; err != nil {
+		return 0, err
+	}
+	return float64(len(symbol)) + float64(date.Day()), nil
+}
+
+func (s *slowStockService) GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error) {
+	price, err := s.GetPriceClose(ctx, symbol, date)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ResolvedPrice{RequestedDate: date, PriceBar: models.PriceBar{Date: date, Close: price}}, nil
+}
+
+func (s *slowStockService) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error) {
+	return nil, ErrNoPriceData
+}
+
+func (s *slowStockService) GetSP500Symbols(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (s *slowStockService) callsOf(symbol string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[symbol]
+}
+
+func priceRequests(count int, day time.Time) []PriceRequest {
+	requests := make([]PriceRequest, 0, count)
+	for i := 0; i < count; i++ {
+		requests = append(requests, NewPriceRequest(fmt.Sprintf("SYM%02d", i), day))
+	}
+	return requests
+}
+
+// TestPriceFetcher_FetchPrices test that the prices are fetched in parallel within the limit of workers
+func TestPriceFetcher_FetchPrices(t *testing.T) {
+	service := &slowStockService{Delay: 5 * time.Millisecond}
+	fetcher := NewPriceFetcher(service)
+	fetcher.Workers = 4
+
+	requests := priceRequests(20, date(2021, 1, 15))
+	prices, err := fetcher.FetchPrices(context.Background(), requests)
+	require.NoError(t, err)
+	require.Len(t, prices, 20)
+	require.Equal(t, 20.0, prices[NewPriceRequest("SYM00", date(2021, 1, 15))])
+	require.LessOrEqual(t, atomic.LoadInt32(&service.maxRun), int32(4))
+	require.Greater(t, atomic.LoadInt32(&service.maxRun), int32(1))
+}
+
+// TestPriceFetcher_Deduplicate test that identical requests reach the service once
+func TestPriceFetcher_Deduplicate(t *testing.T) {
+	service := &slowStockService{Delay: 20 * time.Millisecond}
+	fetcher := NewPriceFetcher(service)
+
+	day := date(2021, 1, 15)
+	requests := []PriceRequest{
+		{Symbol: "AAPL", Date: day},
+		{Symbol: "AAPL", Date: day.Add(15 * time.Hour)},
+		{Symbol: "MSFT", Date: day},
+	}
+
+	// Calls running at the same time share the requests in flight too.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prices, err := fetcher.FetchPrices(context.Background(), requests)
+			require.NoError(t, err)
+			require.Len(t, prices, 2)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, service.callsOf("AAPL"))
+	require.Equal(t, 1, service.callsOf("MSFT"))
+}
+
+// TestPriceFetcher_SharedCancel test that a caller leaving does not cancel the request it shares with another one
+func TestPriceFetcher_SharedCancel(t *testing.T) {
+	service := &slowStockService{Delay: 50 * time.Millisecond}
+	fetcher := NewPriceFetcher(service)
+	requests := []PriceRequest{NewPriceRequest("AAPL", date(2021, 1, 15))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	first := make(chan error, 1)
+	go func() {
+		_, err := fetcher.FetchPrices(ctx, requests)
+		first <- err
+	}()
+
+	// The second caller joins the request the first one started
+	time.Sleep(5 * time.Millisecond)
+	prices, err := fetcher.FetchPrices(context.Background(), requests)
+	require.NoError(t, err)
+	require.Equal(t, 19.0, prices[requests[0]])
+
+	require.ErrorIs(t, <-first, context.DeadlineExceeded)
+	require.Equal(t, 1, service.callsOf("AAPL"))
+}
+
+// TestPriceFetcher_PerSymbolErrors test that every failed symbol is reported next to the fetched prices
+func TestPriceFetcher_PerSymbolErrors(t *testing.T) {
+	service := &slowStockService{Failed: map[string]error{
+		"AAPL": ErrNoPriceData,
+		"MSFT": &RateLimitError{Provider: "test"},
+	}}
+	fetcher := NewPriceFetcher(service)
+
+	day := date(2021, 1, 15)
+	prices, err := fetcher.FetchPrices(context.Background(), []PriceRequest{
+		NewPriceRequest("AAPL", day),
+		NewPriceRequest("MSFT", day),
+		NewPriceRequest("GOOG", day),
+	})
+
+	var priceErrors PriceErrors
+	require.True(t, errors.As(err, &priceErrors))
+	require.Len(t, priceErrors, 2)
+	require.ErrorIs(t, priceErrors["AAPL"], ErrNoPriceData)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Equal(t, map[PriceRequest]float64{NewPriceRequest("GOOG", day): 19}, prices)
+}
+
+// TestPriceFetcher_Cancel test that the fetch stops when the context is canceled
+func TestPriceFetcher_Cancel(t *testing.T) {
+	service := &slowStockService{Delay: 50 * time.Millisecond}
+	fetcher := NewPriceFetcher(service)
+	fetcher.Workers = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fetcher.FetchPrices(ctx, priceRequests(10, date(2021, 1, 15)))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+// BenchmarkPriceFetcher compares fetching the prices of a 50 position
+// portfolio one at a time and with the worker pool.
+func BenchmarkPriceFetcher(b *testing.B) {
+	ctx := context.Background()
+	requests := priceRequests(50, date(2021, 1, 15))
+
+	b.Run("Sequential", func(b *testing.B) {
+		service := &slowStockService{Delay: time.Millisecond}
+		for i := 0; i < b.N; i++ {
+			for _, request := range requests {
+				if _, err := service.GetPriceClose(ctx, request.Symbol, request.Date); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		fetcher := NewPriceFetcher(&slowStockService{Delay: time.Millisecond})
+		for i := 0; i < b.N; i++ {
+			if _, err := fetcher.FetchPrices(ctx, requests); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// closePricesFunc returns the close prices of the requests keyed by request.
+type closePricesFunc func(ctx context.Context, requests []PriceRequest) (map[PriceRequest]float64, error)
+
+// priceHistories holds the daily bars of the symbols valued over a period,
+// fetched with one request per symbol, and resolves their close prices in
+// memory. The dates its bars cannot price are asked to the stock service,
+// which applies its resolution and fallback.
+type priceHistories struct {
+	service StockServiceInterface
+	bars    map[string][]models.PriceBar
+}
+
+// fetchPriceHistories fetches the bars of every symbol between from and to,
+// starting resolutionWindowDays earlier so that the first dates can resolve
+// to a previous trading day. A symbol without bars is priced date by date.
+func fetchPriceHistories(ctx context.Context, service StockServiceInterface, symbols []string, from, to time.Time) (*priceHistories, error) {
+	histories := &priceHistories{service: service, bars: make(map[string][]models.PriceBar)}
+	start := dateOnly(from).AddDate(0, 0, -resolutionWindowDays)
+	for _, symbol := range symbols {
+		if _, ok := histories.bars[symbol]; ok {
+			continue
+		}
+		bars, err := service.GetPriceHistory(ctx, symbol, start, dateOnly(to))
+		if err != nil && !errors.Is(err, ErrNoPriceData) {
+			return nil, err
+		}
+
+		sorted := append([]models.PriceBar(nil), bars...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+		histories.bars[symbol] = sorted
+	}
+	return histories, nil
+}
+
+// tradingDays returns the dates between from and to with a bar of any of the
+// symbols, so that market holidays are left out, or the weekdays when there
+// are no bars at all.
+func (h *priceHistories) tradingDays(from, to time.Time) []time.Time {
+	from, to = dateOnly(from), dateOnly(to)
+	seen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, bars := range h.bars {
+		for _, bar := range bars {
+			day := dateOnly(bar.Date)
+			if !day.Before(from) && !day.After(to) && !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+	if len(days) == 0 {
+		return tradingDays(from, to)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// closePrice returns the close of the symbol on the date, or on the previous
+// trading day within resolutionWindowDays when the date has no bar.
+func (h *priceHistories) closePrice(ctx context.Context, symbol string, date time.Time) (float64, error) {
+	date = dateOnly(date)
+	bars := h.bars[symbol]
+	i := sort.Search(len(bars), func(i int) bool { return bars[i].Date.After(date) })
+	if i > 0 && !bars[i-1].Date.Before(date.AddDate(0, 0, -resolutionWindowDays)) {
+		return bars[i-1].Close, nil
+	}
+	return h.service.GetPriceClose(ctx, symbol, date)
+}
+
+// closePrices returns the close price of every request keyed like the ones of
+// a PriceFetcher.
+func (h *priceHistories) closePrices(ctx context.Context, requests []PriceRequest) (map[PriceRequest]float64, error) {
+	prices := make(map[PriceRequest]float64, len(requests))
+	for _, request := range requests {
+		request = NewPriceRequest(request.Symbol, request.Date)
+		price, err := h.closePrice(ctx, request.Symbol, request.Date)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, PriceErrors{request.Symbol: fmt.Errorf("%s: %w", request.Date.Format("2006-01-02"), err)}
+		}
+		prices[request] = price
+	}
+	return prices, nil
+}
+
+// valuedSymbols returns the symbols the portfolio holds at some point between
+// from and to: the ones held on from and the ones bought later.
+func valuedSymbols(ledger []models.Transaction, from, to time.Time, method models.CostBasisMethod) ([]string, error) {
+	state, err := replayLedger(ledger, from, method)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, p := range state.Positions {
+		if p.Quantity > 0 && !seen[p.Symbol] {
+			seen[p.Symbol] = true
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	for _, t := range ledger {
+		if t.Type == models.TransactionBuy && t.Date.After(from) && !t.Date.After(to) && !seen[t.Symbol] {
+			seen[t.Symbol] = true
+			symbols = append(symbols, t.Symbol)
+		}
+	}
+	return symbols, nil
+}
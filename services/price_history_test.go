@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPriceHistories_ClosePrice test that the prices are resolved from the fetched bars and only the missing ones are asked to the service
+func TestPriceHistories_ClosePrice(t *testing.T) {
+	ctx := context.Background()
+	mockStock := new(MockStockService)
+
+	from, to := date(2021, 1, 14), date(2021, 1, 19)
+	historyStart := from.AddDate(0, 0, -resolutionWindowDays)
+	mockStock.On("GetPriceHistory", "AAPL", historyStart, to).Return([]models.PriceBar{
+		{Date: date(2021, 1, 19), Close: 127.83},
+		{Date: date(2021, 1, 14), Close: 128.91},
+		{Date: date(2021, 1, 15), Close: 127.14},
+	}, nil).Once()
+	mockStock.On("GetPriceHistory", "NEW", historyStart, to).Return([]models.PriceBar(nil), ErrNoPriceData).Once()
+	mockStock.On("GetPriceClose", "NEW", date(2021, 1, 15)).Return(10.0, nil).Once()
+
+	histories, err := fetchPriceHistories(ctx, mockStock, []string{"AAPL", "NEW", "AAPL"}, from, to)
+	require.NoError(t, err)
+
+	// The Monday holiday takes the close of Friday.
+	price, err := histories.closePrice(ctx, "AAPL", date(2021, 1, 18))
+	require.NoError(t, err)
+	require.Equal(t, 127.14, price)
+
+	price, err = histories.closePrice(ctx, "AAPL", date(2021, 1, 19))
+	require.NoError(t, err)
+	require.Equal(t, 127.83, price)
+
+	price, err = histories.closePrice(ctx, "NEW", date(2021, 1, 15))
+	require.NoError(t, err)
+	require.Equal(t, 10.0, price)
+
+	mockStock.AssertExpectations(t)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/repositories"
+)
+
+// RateLimitError is returned when a provider refuses a request over its rate
+// limit, or when the client stops before exceeding the daily quota of the
+// plan. RetryAfter is how long to wait before trying again, zero when unknown.
+type RateLimitError struct {
+	Provider   string
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	message := fmt.Sprintf("%s by %s", ErrRateLimited, e.Provider)
+	if e.RetryAfter > 0 {
+		message += fmt.Sprintf(", retry after %s", e.RetryAfter.Round(time.Second))
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// Is makes errors.Is(err, ErrRateLimited) match every rate limit error.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimiter spaces the requests to a provider with a token bucket that
+// allows PerMinute requests a minute, and stops them once PerDay requests were
+// made in the current UTC day. The requests of the day are counted in Usage,
+// so the quota holds across runs. A zero limit is unlimited.
+type RateLimiter struct {
+	Provider  string
+	PerMinute int
+	PerDay    int
+	Usage     repositories.APIUsageRepository
+	Now       func() time.Time
+	Sleep     func(ctx context.Context, d time.Duration) error
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(provider string, perMinute, perDay int, usage repositories.APIUsageRepository) *RateLimiter {
+	return &RateLimiter{
+		Provider:  provider,
+		PerMinute: perMinute,
+		PerDay:    perDay,
+		Usage:     usage,
+		Now:       time.Now,
+		Sleep:     sleep,
+		tokens:    float64(perMinute),
+	}
+}
+
+// Wait blocks until the per-minute limit allows a request, or ctx is done,
+// and counts it in the usage of the day. When the daily quota is used it
+// returns a RateLimitError right away, to be retried the next day.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.Now()
+	day := dateOnly(now.UTC())
+	if rl.PerDay > 0 {
+		used, err := rl.Usage.GetAPIUsage(ctx, rl.Provider, day)
+		if err != nil {
+			return err
+		}
+		if used >= rl.PerDay {
+			return &RateLimitError{
+				Provider:   rl.Provider,
+				RetryAfter: day.AddDate(0, 0, 1).Sub(now),
+				Message:    fmt.Sprintf("the daily quota of %d requests is used", rl.PerDay),
+			}
+		}
+	}
+
+	if rl.PerMinute > 0 {
+		rl.refill(now)
+		if rl.tokens < 1 {
+			if err := rl.Sleep(ctx, time.Duration((1-rl.tokens)/rl.rate()*float64(time.Second))); err != nil {
+				return err
+			}
+			rl.refill(rl.Now())
+		}
+		rl.tokens = math.Max(rl.tokens-1, 0)
+	}
+
+	if rl.PerDay > 0 {
+		if _, err := rl.Usage.IncrementAPIUsage(ctx, rl.Provider, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemainingQuota returns how many requests are left today, or -1 without a daily limit.
+func (rl *RateLimiter) RemainingQuota(ctx context.Context) (int, error) {
+	if rl.PerDay <= 0 {
+		return -1, nil
+	}
+	used, err := rl.Usage.GetAPIUsage(ctx, rl.Provider, dateOnly(rl.Now().UTC()))
+	if err != nil {
+		return 0, err
+	}
+	if used >= rl.PerDay {
+		return 0, nil
+	}
+	return rl.PerDay - used, nil
+}
+
+// sleep waits for d unless ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens earned since the last request, up to a minute's worth.
+func (rl *RateLimiter) refill(now time.Time) {
+	if !rl.last.IsZero() {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate()
+	}
+	rl.tokens = math.Min(rl.tokens, float64(rl.PerMinute))
+	rl.last = now
+}
+
+// rate is the number of tokens earned per second.
+func (rl *RateLimiter) rate() float64 {
+	return float64(rl.PerMinute) / 60
+}
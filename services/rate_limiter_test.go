@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/repositories"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(t *testing.T, path string, perMinute, perDay int, now *time.Time) *RateLimiter {
+	repo := repositories.NewSQLitePortfolioRepository(path)
+	t.Cleanup(func() { repo.DB.Close() })
+
+	limiter := NewRateLimiter("fmp", perMinute, perDay, repo)
+	limiter.Now = func() time.Time { return *now }
+	limiter.Sleep = func(ctx context.Context, d time.Duration) error {
+		*now = now.Add(d)
+		return nil
+	}
+	return limiter
+}
+
+// TestRateLimiter_PerMinute test that requests over the per-minute limit wait for a token
+func TestRateLimiter_PerMinute(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	start := now
+	limiter := newTestRateLimiter(t, filepath.Join(t.TempDir(), "usage.db"), 6, 0, &now)
+
+	// The bucket starts full, then a token is earned every 10 seconds
+	for i := 0; i < 6; i++ {
+		require.NoError(t, limiter.Wait(ctx))
+	}
+	require.Equal(t, start, now)
+
+	require.NoError(t, limiter.Wait(ctx))
+	require.Equal(t, start.Add(10*time.Second), now)
+	require.NoError(t, limiter.Wait(ctx))
+	require.Equal(t, start.Add(20*time.Second), now)
+}
+
+// TestRateLimiter_PerDay test that the daily quota is kept across runs
+func TestRateLimiter_PerDay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "usage.db")
+	now := time.Date(2021, 6, 1, 18, 0, 0, 0, time.UTC)
+
+	limiter := newTestRateLimiter(t, path, 0, 3, &now)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, limiter.Wait(ctx))
+	}
+
+	// A new run continues with the usage of the day
+	limiter = newTestRateLimiter(t, path, 0, 3, &now)
+	remaining, err := limiter.RemainingQuota(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, remaining)
+	require.NoError(t, limiter.Wait(ctx))
+
+	err = limiter.Wait(ctx)
+	var limited *RateLimitError
+	require.True(t, errors.As(err, &limited))
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Equal(t, 6*time.Hour, limited.RetryAfter)
+
+	now = now.Add(6 * time.Hour)
+	require.NoError(t, limiter.Wait(ctx))
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// dailyReturns returns the time-weighted return of every day of the series
+// after the first one, which is its base.
+func dailyReturns(points []models.ValuationPoint) []float64 {
+	if len(points) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(points)-1)
+	for _, point := range points[1:] {
+		returns = append(returns, point.Return)
+	}
+	return returns
+}
+
+// priceReturns returns the daily returns of a symbol on the dates of the
+// series, priced from the histories.
+func priceReturns(ctx context.Context, histories *priceHistories, symbol string, points []models.ValuationPoint) ([]float64, error) {
+	returns := make([]float64, 0, len(points))
+	previous := 0.0
+	for i, point := range points {
+		price, err := histories.closePrice(ctx, symbol, point.Date)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			returns = append(returns, price/previous-1)
+		}
+		previous = price
+	}
+	return returns, nil
+}
+
+// calculateRisk computes the risk metrics of the valuation series against the
+// daily returns of the benchmark.
+func calculateRisk(points []models.ValuationPoint, benchmarkReturns []float64, riskFreeRate float64) *models.RiskReport {
+	report := &models.RiskReport{RiskFreeRate: riskFreeRate}
+	returns := dailyReturns(points)
+	dailyRiskFree := riskFreeRate / tradingDaysPerYear
+
+	report.Volatility = sampleStdDev(returns) * math.Sqrt(tradingDaysPerYear)
+	excessReturn := mean(returns)*tradingDaysPerYear - riskFreeRate
+	if report.Volatility > 0 {
+		report.Sharpe = excessReturn / report.Volatility
+	}
+	if downside := downsideDeviation(returns, dailyRiskFree) * math.Sqrt(tradingDaysPerYear); downside > 0 {
+		report.Sortino = excessReturn / downside
+	}
+
+	report.MaxDrawdown, report.DrawdownPeak, report.DrawdownTrough = maxDrawdown(points)
+
+	if variance := sampleCovariance(benchmarkReturns, benchmarkReturns); variance > 0 {
+		report.Beta = sampleCovariance(returns, benchmarkReturns) / variance
+	}
+	report.Alpha = (mean(returns) - dailyRiskFree - report.Beta*(mean(benchmarkReturns)-dailyRiskFree)) * tradingDaysPerYear
+	return report
+}
+
+// maxDrawdown finds the largest fall of the cumulative time-weighted return
+// from a previous peak, so contributions and withdrawals do not count as gains
+// or losses.
+func maxDrawdown(points []models.ValuationPoint) (float64, time.Time, time.Time) {
+	var drawdown float64
+	var peak, trough time.Time
+	if len(points) == 0 {
+		return 0, peak, trough
+	}
+
+	highest := 1 + points[0].CumulativeReturn
+	highestDate := points[0].Date
+	for _, point := range points {
+		growth := 1 + point.CumulativeReturn
+		if growth > highest {
+			highest, highestDate = growth, point.Date
+			continue
+		}
+		if fall := growth/highest - 1; fall < drawdown {
+			drawdown, peak, trough = fall, highestDate, point.Date
+		}
+	}
+	return drawdown, peak, trough
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// riskPoints values a portfolio without flows at 100, 110, 99, 104.5 and 120.
+func riskPoints() []models.ValuationPoint {
+	values := []float64{100, 110, 99, 104.5, 120}
+	points := make([]models.ValuationPoint, len(values))
+	for i, value := range values {
+		points[i] = models.ValuationPoint{Date: date(2021, 1, 4+i), Value: value, CumulativeReturn: value/values[0] - 1}
+		if i > 0 {
+			points[i].Return = value/values[i-1] - 1
+		}
+	}
+	return points
+}
+
+func TestCalculateRisk(t *testing.T) {
+	points := riskPoints()
+	returns := dailyReturns(points)
+	benchmarkReturns := make([]float64, len(returns))
+	for i, r := range returns {
+		benchmarkReturns[i] = 2 * r
+	}
+
+	report := calculateRisk(points, benchmarkReturns, 0.02)
+
+	require.InDelta(t, 1.70715, report.Volatility, 1e-5)
+	require.InDelta(t, 7.51220, report.Sharpe, 1e-5)
+	require.InDelta(t, 16.14454, report.Sortino, 1e-5)
+
+	// The fall from 110 to 99.
+	require.InDelta(t, -0.1, report.MaxDrawdown, 1e-9)
+	require.Equal(t, date(2021, 1, 5), report.DrawdownPeak)
+	require.Equal(t, date(2021, 1, 6), report.DrawdownTrough)
+
+	// The benchmark moves twice as much, so the portfolio has half its exposure
+	// and only loses half of the risk-free rate it does not earn.
+	require.InDelta(t, 0.5, report.Beta, 1e-9)
+	require.InDelta(t, -0.01, report.Alpha, 1e-9)
+}
+
+func TestCalculateRisk_NoDrawdown(t *testing.T) {
+	points := riskPoints()[2:]
+	report := calculateRisk(points, dailyReturns(points), 0)
+
+	require.Equal(t, 0.0, report.MaxDrawdown)
+	require.True(t, report.DrawdownPeak.IsZero())
+	require.InDelta(t, 1.0, report.Beta, 1e-9)
+	require.InDelta(t, 0.0, report.Alpha, 1e-9)
+}
@@ -1,11 +1,53 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
 )
 
+// ErrRateLimited is returned by the price providers when their API refuses a
+// request because too many were made.
+var ErrRateLimited = models.ErrRateLimited
+
+// ErrProviderConfig is returned by the price providers when their API refuses
+// a request because of the API key or the endpoint it is allowed to call.
+var ErrProviderConfig = models.ErrProviderConfig
+
+// ErrNoPriceData is returned when a provider works but has no price for the
+// symbol or the date. It wraps models.ErrPriceUnavailable.
+var ErrNoPriceData error = noPriceDataError{}
+
+type noPriceDataError struct{}
+
+func (noPriceDataError) Error() string {
+	return "no price data available"
+}
+
+func (noPriceDataError) Unwrap() error {
+	return models.ErrPriceUnavailable
+}
+
+// priceUnavailable marks the error of a price that could not be obtained with
+// models.ErrPriceUnavailable, unless it already is.
+func priceUnavailable(symbol string, date time.Time, err error) error {
+	if errors.Is(err, models.ErrPriceUnavailable) {
+		return err
+	}
+	return fmt.Errorf("%w for %s on %s: %w", models.ErrPriceUnavailable, symbol, date.Format("2006-01-02"), err)
+}
+
 type StockServiceInterface interface {
-	GetPriceOpen(symbol string, date time.Time) (float64, error)
-	GetPriceClose(symbol string, date time.Time) (float64, error)
-	GetSP500Symbols() ([]string, error)
+	GetPriceOpen(ctx context.Context, symbol string, date time.Time) (float64, error)
+	GetPriceClose(ctx context.Context, symbol string, date time.Time) (float64, error)
+	// GetResolvedPrice returns the bar used for the date, which comes from
+	// another trading day when the date had no trading.
+	GetResolvedPrice(ctx context.Context, symbol string, date time.Time) (*models.ResolvedPrice, error)
+	// GetPriceHistory returns the daily bars between from and to, both included,
+	// in chronological order.
+	GetPriceHistory(ctx context.Context, symbol string, from, to time.Time) ([]models.PriceBar, error)
+	GetSP500Symbols(ctx context.Context) ([]string, error)
 }
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+// tradingDays returns the weekdays between from and to, both included, which
+// include the market holidays.
+func tradingDays(from, to time.Time) []time.Time {
+	var days []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// externalFlowOn returns the money contributed to the portfolio on date: the
+// contributed stocks bought that day plus deposits minus withdrawals.
+func externalFlowOn(ledger []models.Transaction, entries []models.CashEntry, date time.Time) float64 {
+	flow := 0.0
+	for _, t := range ledger {
+		if t.Contributed && t.Date.Equal(date) {
+			flow += t.Quantity * t.Price
+		}
+	}
+	for _, entry := range entries {
+		if entry.IsExternalFlow() && entry.Date.Equal(date) {
+			flow += entry.SignedAmount()
+		}
+	}
+	return flow
+}
+
+// fetchValuationHistories fetches the price history of the symbols the
+// portfolio holds between from and to, and of the extra ones such as a
+// benchmark, with one request per symbol.
+func (ps *PortfolioService) fetchValuationHistories(ctx context.Context, portfolio *models.Portfolio, ledger []models.Transaction, from, to time.Time, extra ...string) (*priceHistories, error) {
+	symbols, err := valuedSymbols(ledger, from, to, portfolio.Method())
+	if err != nil {
+		return nil, err
+	}
+	return fetchPriceHistories(ctx, ps.StockService, append(symbols, extra...), from, to)
+}
+
+// valuationSeries values the portfolio at the close of every trading day
+// between from and to, the days with bars in the histories, and chains the
+// daily returns. The first day is the base of the series. Flows are assumed to
+// happen at the start of their day, so the return of a day is its closing
+// value over the previous value plus the flow.
+func (ps *PortfolioService) valuationSeries(ctx context.Context, portfolio *models.Portfolio, ledger []models.Transaction, entries []models.CashEntry, histories *priceHistories, from, to time.Time) ([]models.ValuationPoint, error) {
+	days := histories.tradingDays(from, to)
+	points := make([]models.ValuationPoint, 0, len(days))
+
+	growth := 1.0
+	previousValue := 0.0
+	for i, day := range days {
+		gains, err := ps.calculateGains(ctx, portfolio, ledger, entries, day, histories.closePrices)
+		if err != nil {
+			return nil, err
+		}
+
+		point := models.ValuationPoint{
+			Date:          day,
+			Cash:          gains.Cash,
+			HoldingsValue: gains.HoldingsValue,
+			Value:         gains.TotalValue(),
+		}
+		if i > 0 {
+			point.NetFlow = externalFlowOn(ledger, entries, day)
+			if invested := previousValue + point.NetFlow; invested > 0 {
+				point.Return = point.Value/invested - 1
+			}
+		}
+		growth *= 1 + point.Return
+		point.CumulativeReturn = growth - 1
+
+		points = append(points, point)
+		previousValue = point.Value
+	}
+	return points, nil
+}
+
+// annualizeReturn converts the return of a period into a yearly rate.
+func annualizeReturn(cumulative float64, from, to time.Time) float64 {
+	years := to.Sub(from).Hours() / (24 * 365)
+	if years <= 0 {
+		return 0
+	}
+	return math.Pow(1+cumulative, 1/years) - 1
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fcopulgar/stock-manager-go/models"
+)
+
+const (
+	xirrTolerance     = 1e-9
+	xirrMaxIterations = 100
+	// xirrMinRate keeps the discount factor (1 + rate) positive.
+	xirrMinRate = -0.999999
+	xirrMaxRate = 1e6
+)
+
+// cashFlow is money put into the portfolio, negative, or taken out of it,
+// positive, from the point of view of the investor.
+type cashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// externalCashFlows returns the flows between the investor and the portfolio
+// after startDate and up to endDate: the contributed stocks on their buy date,
+// deposits and withdrawals.
+func externalCashFlows(ledger []models.Transaction, entries []models.CashEntry, startDate, endDate time.Time) []cashFlow {
+	var flows []cashFlow
+	inPeriod := func(date time.Time) bool {
+		return date.After(startDate) && !date.After(endDate)
+	}
+	for _, t := range ledger {
+		if t.Contributed && inPeriod(t.Date) {
+			flows = append(flows, cashFlow{Date: t.Date, Amount: -t.Quantity * t.Price})
+		}
+	}
+	for _, entry := range entries {
+		if entry.IsExternalFlow() && inPeriod(entry.Date) {
+			flows = append(flows, cashFlow{Date: entry.Date, Amount: -entry.SignedAmount()})
+		}
+	}
+	return flows
+}
+
+// heldBefore reports whether the portfolio had any activity on or before date,
+// in which case its value on that date is the opening flow.
+func heldBefore(ledger []models.Transaction, entries []models.CashEntry, date time.Time) bool {
+	for _, t := range ledger {
+		if !t.Date.After(date) {
+			return true
+		}
+	}
+	for _, entry := range entries {
+		if !entry.Date.After(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// xirr returns the annual rate that makes the net present value of the flows
+// zero. It uses Newton's method and falls back to bisection when Newton does
+// not converge, which happens with poor initial guesses or flat regions.
+func xirr(flows []cashFlow) (float64, error) {
+	hasInflow, hasOutflow := false, false
+	for _, flow := range flows {
+		hasInflow = hasInflow || flow.Amount > 0
+		hasOutflow = hasOutflow || flow.Amount < 0
+	}
+	if !hasInflow || !hasOutflow {
+		return 0, fmt.Errorf("the money-weighted return needs money both put into and taken out of the portfolio")
+	}
+
+	sorted := make([]cashFlow, len(flows))
+	copy(sorted, flows)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	first := sorted[0].Date
+	years := make([]float64, len(sorted))
+	for i, flow := range sorted {
+		years[i] = flow.Date.Sub(first).Hours() / (24 * 365)
+	}
+
+	npv := func(rate float64) (value, derivative float64) {
+		for i, flow := range sorted {
+			discount := math.Pow(1+rate, years[i])
+			value += flow.Amount / discount
+			derivative -= years[i] * flow.Amount / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	if rate, ok := xirrNewton(npv, 0.1); ok {
+		return rate, nil
+	}
+	if rate, ok := xirrBisection(npv); ok {
+		return rate, nil
+	}
+	return 0, fmt.Errorf("the money-weighted return did not converge")
+}
+
+func xirrNewton(npv func(float64) (float64, float64), guess float64) (float64, bool) {
+	rate := guess
+	for i := 0; i < xirrMaxIterations; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < xirrTolerance {
+			return rate, true
+		}
+		if derivative == 0 || math.IsNaN(derivative) || math.IsInf(derivative, 0) {
+			return 0, false
+		}
+
+		next := rate - value/derivative
+		if math.IsNaN(next) || next <= xirrMinRate || next > xirrMaxRate {
+			return 0, false
+		}
+		if math.Abs(next-rate) < xirrTolerance {
+			return next, true
+		}
+		rate = next
+	}
+	return 0, false
+}
+
+// xirrBisection brackets a root by widening the upper bound and then halves the
+// bracket until it is small enough.
+func xirrBisection(npv func(float64) (float64, float64)) (float64, bool) {
+	low, high := xirrMinRate, 1.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	for lowValue*highValue > 0 {
+		if high >= xirrMaxRate {
+			return 0, false
+		}
+		high *= 2
+		highValue, _ = npv(high)
+	}
+
+	for i := 0; i < 10*xirrMaxIterations; i++ {
+		mid := (low + high) / 2
+		midValue, _ := npv(mid)
+		if math.Abs(midValue) < xirrTolerance || (high-low)/2 < xirrTolerance {
+			return mid, true
+		}
+		if lowValue*midValue < 0 {
+			high = mid
+		} else {
+			low, lowValue = mid, midValue
+		}
+	}
+	return 0, false
+}
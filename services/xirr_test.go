@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name     string
+		flows    []cashFlow
+		expected float64
+	}{
+		{
+			name:     "single period",
+			flows:    []cashFlow{{date(2020, 1, 1), -1000}, {date(2020, 12, 31), 1100}},
+			expected: 0.1,
+		},
+		{
+			// The second contribution is invested for half of the period only.
+			name: "staggered contributions",
+			flows: []cashFlow{
+				{date(2020, 1, 1), -1000},
+				{date(2020, 7, 1), -1000},
+				{date(2020, 12, 31), 2150},
+			},
+			expected: 0.1009,
+		},
+		{
+			// Newton's method leaves the valid range from the default guess.
+			name:     "almost total loss",
+			flows:    []cashFlow{{date(2020, 1, 1), -1000}, {date(2020, 12, 31), 1}},
+			expected: -0.999,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := xirr(tt.flows)
+			require.NoError(t, err)
+			require.InDelta(t, tt.expected, rate, 0.0005)
+		})
+	}
+}
+
+func TestXIRR_NoSolution(t *testing.T) {
+	_, err := xirr([]cashFlow{{date(2020, 1, 1), -1000}, {date(2021, 1, 1), -100}})
+	require.Error(t, err)
+
+	_, err = xirr(nil)
+	require.Error(t, err)
+}